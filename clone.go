@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Restore types of a point-in-time restore, selected with restoreType
+const (
+	restoreTypeFullCopy    = "full-copy"
+	restoreTypeCopyOnWrite = "copy-on-write"
+
+	defaultRestoreType = restoreTypeFullCopy
+)
+
+// Aurora allows at most 15 copy-on-write clones of a single source cluster
+const maxClonesPerSource = 15
+
+func (c *RestoreConfig) cloneProblems() []string {
+	var problems []string
+
+	switch c.RestoreType {
+	case restoreTypeFullCopy:
+	case restoreTypeCopyOnWrite:
+		if c.RestoreSource != sourcePointInTime {
+			problems = append(problems, fmt.Sprintf("restoreType %v can only be used with restoreSource %v", restoreTypeCopyOnWrite, sourcePointInTime))
+		}
+		if !c.RestoreTime.IsZero() {
+			problems = append(problems, fmt.Sprintf("restoreType %v clones the current state of sourceRDS, restoreDate can't be used with it", restoreTypeCopyOnWrite))
+		}
+		if c.crossRegion() || c.crossAccount() {
+			problems = append(problems, fmt.Sprintf("restoreType %v clones sourceRDS in its own account and region, sourceRegion and sourceRoleArn can't be used with it", restoreTypeCopyOnWrite))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("restoreType [%v] is not supported, expected %v or %v", c.RestoreType, restoreTypeFullCopy, restoreTypeCopyOnWrite))
+	}
+	return problems
+}
+
+// Checks that have to pass before a copy-on-write clone is attempted, run before
+// the previous restore is deleted so that a clone that can't be created doesn't
+// leave the job without a target
+func checkClonePreflight(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	sourceCluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.SourceRDS)
+	if describeErr != nil {
+		return describeErr
+	}
+	if sourceCluster == nil {
+		return fmt.Errorf("Source RDS cluster [%v] not found, cannot clone it", restoreConfig.SourceRDS)
+	}
+
+	// Other regions are refused with the config, an ARN of another account means
+	// the source is shared with the account of the credentials
+	sourceARN, parseErr := arn.Parse(aws.StringValue(sourceCluster.DBClusterArn))
+	if parseErr == nil && restoreConfig.TargetAccountID != "" && sourceARN.AccountID != restoreConfig.TargetAccountID {
+		return fmt.Errorf("Cannot clone [%v]: source is in AWS account [%v], copy-on-write clones must be in the same account [%v]",
+			restoreConfig.SourceRDS, sourceARN.AccountID, restoreConfig.TargetAccountID)
+	}
+
+	cloneGroupID := aws.StringValue(sourceCluster.CloneGroupId)
+	if cloneGroupID == "" {
+		logf("Source RDS cluster [%v] has no clones yet\n", restoreConfig.SourceRDS)
+		return nil
+	}
+
+	// restoreRDS is deleted before the new clone is created, it doesn't count
+	var clones []string
+	input := &rds.DescribeDBClustersInput{}
+	describeErr = rdsClientSess.DescribeDBClustersPages(input, func(page *rds.DescribeDBClustersOutput, lastPage bool) bool {
+		for _, cluster := range page.DBClusters {
+			name := aws.StringValue(cluster.DBClusterIdentifier)
+			if aws.StringValue(cluster.CloneGroupId) == cloneGroupID && name != restoreConfig.SourceRDS && name != restoreConfig.RestoreRDS {
+				clones = append(clones, name)
+			}
+		}
+		return true
+	})
+	if describeErr != nil {
+		return fmt.Errorf("Describe clones of RDS cluster [%v] err: %v", restoreConfig.SourceRDS, describeErr)
+	}
+
+	if len(clones) >= maxClonesPerSource {
+		return fmt.Errorf("Cannot clone [%v]: it already has %d clones (%v), the maximum per source is %d - delete one or use restoreType %v",
+			restoreConfig.SourceRDS, len(clones), strings.Join(clones, ", "), maxClonesPerSource, restoreTypeFullCopy)
+	}
+	logf("Source RDS cluster [%v] has %d of %d clones\n", restoreConfig.SourceRDS, len(clones), maxClonesPerSource)
+	return nil
+}
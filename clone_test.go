@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func cloneRestoreConfig() *RestoreConfig {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RestoreType = restoreTypeCopyOnWrite
+	return restoreConfig
+}
+
+// Add clones of test-db to the fake, including the previous restore
+func addClones(fake *fakeRDS, count int) {
+	fake.clusters["test-db"].cluster.DBClusterArn = aws.String("arn:aws:rds:us-east-1:123456789012:cluster:test-db")
+	fake.clusters["test-db"].cluster.CloneGroupId = aws.String("clone-group-1")
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("test-db-clone-%d", i)
+		fake.addCluster(name, "available")
+		fake.clusters[name].cluster.CloneGroupId = aws.String("clone-group-1")
+	}
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.clusters["test-db-restore"].cluster.CloneGroupId = aws.String("clone-group-1")
+}
+
+func TestRestoreRDSClusterCopyOnWriteClone(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	addClones(fake, maxClonesPerSource-1)
+
+	if err := restoreRDSCluster(context.Background(), fake, cloneRestoreConfig()); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	input := fake.restoreInputs[0]
+	if got := aws.StringValue(input.RestoreType); got != restoreTypeCopyOnWrite {
+		t.Errorf("RestoreType = %v, want %v", got, restoreTypeCopyOnWrite)
+	}
+	if !aws.BoolValue(input.UseLatestRestorableTime) || input.RestoreToTime != nil {
+		t.Errorf("expected clone of the latest state without RestoreToTime")
+	}
+}
+
+func TestClonePreflightRefusesAtMaxClones(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	addClones(fake, maxClonesPerSource)
+
+	err := restoreRDSCluster(context.Background(), fake, cloneRestoreConfig())
+	if err == nil || !strings.Contains(err.Error(), "the maximum per source is 15") {
+		t.Fatalf("expected max clones preflight error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("preflight failure sent mutating calls: %v", calls)
+	}
+}
+
+func TestClonePreflightRefusesOtherAccount(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.clusters["test-db"].cluster.DBClusterArn = aws.String("arn:aws:rds:us-east-1:123456789012:cluster:test-db")
+
+	restoreConfig := cloneRestoreConfig()
+	restoreConfig.TargetAccountID = "210987654321"
+	err := restoreRDSCluster(context.Background(), fake, restoreConfig)
+	if err == nil || !strings.Contains(err.Error(), "same account") {
+		t.Fatalf("expected same account preflight error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("preflight failure sent mutating calls: %v", calls)
+	}
+}
+
+func TestCloneProblems(t *testing.T) {
+	restoreConfig := cloneRestoreConfig()
+	restoreConfig.RestoreSource = sourceLatestAutomatedSnapshot
+	if problems := restoreConfig.cloneProblems(); len(problems) != 1 {
+		t.Errorf("clone of a snapshot source: problems = %q, want 1", problems)
+	}
+
+	restoreConfig = cloneRestoreConfig()
+	restoreConfig.SourceRegion = "eu-west-1"
+	if problems := restoreConfig.cloneProblems(); len(problems) != 1 {
+		t.Errorf("clone of a source in another region: problems = %q, want 1", problems)
+	}
+
+	restoreConfig = cloneRestoreConfig()
+	restoreConfig.SourceRoleARN = "arn:aws:iam::111111111111:role/rds-restore-source"
+	if problems := restoreConfig.cloneProblems(); len(problems) != 1 {
+		t.Errorf("clone of a source in another account: problems = %q, want 1", problems)
+	}
+
+	restoreConfig.RestoreType = "snapshot-copy"
+	if problems := restoreConfig.cloneProblems(); len(problems) != 1 {
+		t.Errorf("unknown restore type: problems = %q, want 1", problems)
+	}
+}
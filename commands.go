@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Subcommand of the CLI, run once per restore job
+type command struct {
+	name        string
+	description string
+	run         func(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error
+
+	// Command changes AWS resources and supports --dry-run
+	mutating bool
+	// Command always runs as a dry-run
+	dryRun bool
+}
+
+// Command used when none is given, keeps the env var only invocation working
+const defaultCommand = "restore"
+
+var commands = []command{
+	{
+		name:        "restore",
+		description: "delete the previous restore and restore sourceRDS into restoreRDS",
+		run:         runRestore,
+		mutating:    true,
+	},
+	{
+		name:        "plan",
+		description: "show the exact API calls restore would send, without changing anything",
+		run:         runRestore,
+		mutating:    true,
+		dryRun:      true,
+	},
+	{
+		name:        "status",
+		description: "report the state of the restoreRDS cluster and its instances",
+		run:         statusRDSCluster,
+	},
+	{
+		name:        "cleanup",
+		description: "delete the restoreRDS cluster and its instances (or the restoreRDS instance) only",
+		run:         runCleanup,
+		mutating:    true,
+	},
+	{
+		name:        "list-restore-points",
+		description: "show the earliest and latest restorable time of sourceRDS",
+		run:         listRestorePoints,
+	},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// Restore command - current behaviour of the tool
+func runRestore(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	switch {
+	case restoreConfig.RestoreSource != sourcePointInTime:
+		logf("Restore source set to %v\n", restoreConfig.RestoreSource)
+	case !restoreConfig.RestoreTime.IsZero():
+		logf("Restore time set to %v\n", restoreConfig.RestoreTime.Format(time.RFC3339))
+	default:
+		logf("Restore time set to latest available\n")
+	}
+
+	// Aurora clusters and standalone instances are restored differently
+	kind, kindErr := rdsSourceKind(rdsClientSess, restoreConfig)
+	if _, notFound := kindErr.(*notFoundError); notFound && restoreConfig.RestoreSource != sourcePointInTime {
+		// Snapshots outlive their source, restore them as clusters
+		kind, kindErr = kindCluster, nil
+	}
+	if kindErr != nil {
+		return fmt.Errorf("Inspect sourceRDS Err: %v", kindErr)
+	}
+
+	if kind == kindInstance {
+		logf("Source [%v] is a standalone RDS instance\n", restoreConfig.SourceRDS)
+		return restoreRDSInstance(ctx, rdsClientSess, restoreConfig)
+	}
+
+	// Delete previous restore and restore RDS into a new cluster
+	return restoreRDSCluster(ctx, rdsClientSess, restoreConfig)
+}
+
+// Cleanup command - deletes whatever kind of resource restoreRDS is
+func runCleanup(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	kind, kindErr := rdsTargetKind(rdsClientSess, restoreConfig)
+	if kindErr != nil {
+		return fmt.Errorf("Inspect restoreRDS Err: %v", kindErr)
+	}
+
+	if kind == kindInstance {
+		return cleanupRDSInstance(ctx, rdsClientSess, restoreConfig)
+	}
+	return cleanupRDSCluster(ctx, rdsClientSess, restoreConfig)
+}
+
+// Print status of restoreRDS cluster and each of its instances
+func statusRDSCluster(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	cluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.RestoreRDS)
+	if describeErr != nil {
+		return describeErr
+	}
+	if cluster == nil {
+		return statusRDSInstance(rdsClientSess, restoreConfig)
+	}
+
+	logf("RDS cluster [%v] status: [%v] engine: [%v %v]\n",
+		restoreConfig.RestoreRDS, aws.StringValue(cluster.Status), aws.StringValue(cluster.Engine), aws.StringValue(cluster.EngineVersion))
+	if cluster.ClusterCreateTime != nil {
+		logf("  created: %v (%v ago)\n", cluster.ClusterCreateTime.Format(time.RFC3339), fmtDuration(time.Since(*cluster.ClusterCreateTime)))
+	}
+	if scaling := cluster.ServerlessV2ScalingConfiguration; scaling != nil {
+		logf("  serverless-v2 capacity: %v-%v ACUs\n", aws.Float64Value(scaling.MinCapacity), aws.Float64Value(scaling.MaxCapacity))
+	}
+	if aws.StringValue(cluster.EngineMode) == "serverless" {
+		logf("  serverless-v1 capacity: %v", aws.Int64Value(cluster.Capacity))
+		if scaling := cluster.ScalingConfigurationInfo; scaling != nil {
+			logf(" (%v-%v, auto-pause: %v)", aws.Int64Value(scaling.MinCapacity), aws.Int64Value(scaling.MaxCapacity), aws.BoolValue(scaling.AutoPause))
+		}
+		logf("\n")
+	}
+	if len(cluster.DBClusterMembers) == 0 {
+		logf("  no instances\n")
+	}
+
+	for _, member := range cluster.DBClusterMembers {
+		rdsInstanceName := aws.StringValue(member.DBInstanceIdentifier)
+		role := "reader"
+		if aws.BoolValue(member.IsClusterWriter) {
+			role = "writer"
+		}
+
+		resp, err := rdsClientSess.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+			DBInstanceIdentifier: aws.String(rdsInstanceName),
+		})
+		if err != nil || len(resp.DBInstances) == 0 {
+			logf("  - instance [%v] (%v) status: [unknown] %v\n", rdsInstanceName, role, err)
+			continue
+		}
+		instance := resp.DBInstances[0]
+		logf("  - instance [%v] (%v, %v) status: [%v]\n",
+			rdsInstanceName, role, aws.StringValue(instance.DBInstanceClass), aws.StringValue(instance.DBInstanceStatus))
+	}
+	return nil
+}
+
+// Status of a standalone restoreRDS instance
+func statusRDSInstance(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	instance, describeErr := describeRDSInstance(rdsClientSess, restoreConfig.RestoreRDS)
+	if describeErr != nil {
+		return describeErr
+	}
+	if instance == nil || aws.StringValue(instance.DBClusterIdentifier) != "" {
+		logf("RDS cluster [%v] doesnt exist\n", restoreConfig.RestoreRDS)
+		return nil
+	}
+
+	logf("RDS instance [%v] (%v) status: [%v] engine: [%v %v]\n", restoreConfig.RestoreRDS, aws.StringValue(instance.DBInstanceClass),
+		aws.StringValue(instance.DBInstanceStatus), aws.StringValue(instance.Engine), aws.StringValue(instance.EngineVersion))
+	if instance.InstanceCreateTime != nil {
+		logf("  created: %v (%v ago)\n", instance.InstanceCreateTime.Format(time.RFC3339), fmtDuration(time.Since(*instance.InstanceCreateTime)))
+	}
+	return nil
+}
+
+// Print earliest and latest restorable time of sourceRDS
+func listRestorePoints(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	cluster, describeErr := describeRDSCluster(sourceClient(rdsClientSess), restoreConfig.SourceRDS)
+	if describeErr != nil {
+		return describeErr
+	}
+	if cluster == nil {
+		return fmt.Errorf("Source RDS cluster [%v] doesnt exist", restoreConfig.SourceRDS)
+	}
+
+	logf("RDS cluster [%v] restore points:\n", restoreConfig.SourceRDS)
+	logf("  earliest restorable time: %v\n", formatRestorePoint(cluster.EarliestRestorableTime))
+	logf("  latest restorable time:   %v\n", formatRestorePoint(cluster.LatestRestorableTime))
+	return nil
+}
+
+func formatRestorePoint(restorePoint *time.Time) string {
+	if restorePoint == nil {
+		return "none"
+	}
+	return restorePoint.UTC().Format(time.RFC3339)
+}
+
+// Describe a single RDS cluster, returns nil cluster if it doesn't exist
+func describeRDSCluster(rdsClientSess rdsiface.RDSAPI, rdsClusterName string) (*rds.DBCluster, error) {
+	resp, err := rdsClientSess.DescribeDBClusters(&rds.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(rdsClusterName),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBClusterNotFoundFault {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Describe Err on cluster [%v]: %v", rdsClusterName, err)
+	}
+	if len(resp.DBClusters) == 0 {
+		return nil, nil
+	}
+	return resp.DBClusters[0], nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestReadOnlyCommandsDontMutate(t *testing.T) {
+	for _, name := range []string{"status", "list-restore-points"} {
+		fake := newFakeRDS()
+		fake.addCluster("test-db", "available")
+		fake.addRestoredCluster("test-db-restore", "test-db")
+		fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+		cmd, ok := findCommand(name)
+		if !ok {
+			t.Fatalf("command [%v] not found", name)
+		}
+		if err := cmd.run(context.Background(), fake, testRestoreConfig()); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+		if calls := mutatingCalls(fake); len(calls) != 0 {
+			t.Errorf("%v sent mutating calls %v", name, calls)
+		}
+	}
+}
+
+func TestCleanupCommandOnlyDeletes(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+	cmd, _ := findCommand("cleanup")
+	if err := cmd.run(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+
+	want := []string{"DeleteDBInstance", "DeleteDBCluster"}
+	if got := mutatingCalls(fake); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	if _, ok := fake.clusters["test-db-restore"]; ok {
+		t.Errorf("restore cluster still exists after cleanup")
+	}
+}
+
+func TestListRestorePointsMissingSource(t *testing.T) {
+	fake := newFakeRDS()
+
+	if err := listRestorePoints(context.Background(), fake, testRestoreConfig()); err == nil {
+		t.Fatalf("expected error when source cluster doesn't exist")
+	}
+}
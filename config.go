@@ -0,0 +1,697 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RDS engine of the restored cluster
+type RDSEngine string
+
+const (
+	EngineAuroraMySQL      RDSEngine = "aurora-mysql"
+	EngineAuroraPostgreSQL RDSEngine = "aurora-postgresql"
+	EngineAurora           RDSEngine = "aurora" // Aurora MySQL 5.6
+)
+
+// Engines this tool knows how to restore
+var supportedEngines = []RDSEngine{EngineAuroraMySQL, EngineAuroraPostgreSQL, EngineAurora}
+
+// Defaults applied when an optional setting isn't provided, the engine only
+// when it can't be detected from sourceRDS
+const (
+	defaultInstanceType = "db.t3.small"
+	defaultEngine       = EngineAuroraMySQL
+	defaultRestoreTime  = "01:00:00"
+)
+
+// Formats of the restoreDate and restoreTime env vars
+const (
+	restoreDateLayout = "2006-01-02"
+	restoreTimeLayout = "15:04:05"
+)
+
+// RDS identifiers - start with a letter, only letters, digits and single hyphens, max 63 chars
+var rdsIdentifierRegex = regexp.MustCompile(`^[a-zA-Z](?:-?[a-zA-Z0-9])*$`)
+
+var awsAccountIDRegex = regexp.MustCompile(`^[0-9]{12}$`)
+
+// RestoreConfig holds every option of a restore job
+type RestoreConfig struct {
+	// Name of the job in logs - defaults to RestoreRDS
+	Name string
+
+	AWSRegion string
+	// Region of sourceRDS when it isn't awsRegion, snapshots are copied from it
+	SourceRegion string
+	// KMS key in awsRegion snapshots copied from sourceRegion are encrypted with
+	KMSKeyID string
+
+	// Roles assumed for the account of sourceRDS and the account of restoreRDS,
+	// the default credentials are used for a side without a role
+	SourceRoleARN string
+	TargetRoleARN string
+	// KMS key in the source account automated snapshots are copied with before they're shared
+	SourceKMSKeyID string
+	// Account restoreRDS is created in, looked up at runtime for cross-account restores and clones
+	TargetAccountID string
+
+	// Cluster to restore from and cluster to (re)create
+	SourceRDS  string
+	RestoreRDS string
+
+	SubnetGroup      string
+	SecurityGroupIDs []string
+
+	// Strategy creating restoreRDS, one of restoreStrategies
+	RestoreSource string
+	// Point in time to restore to - zero value means latest restorable time
+	RestoreTime time.Time
+	// full-copy or copy-on-write clone of the point-in-time source
+	RestoreType string
+	// Snapshot to restore from with the snapshot source
+	SnapshotIdentifier string
+	// key=value tag of the snapshot to restore from with the latest-tagged-snapshot source
+	SnapshotTag string
+
+	InstanceType string
+	// Engine and engine version of restoreRDS - default to the ones of sourceRDS
+	Engine        RDSEngine
+	EngineVersion string
+	// Parameter group family of Engine and EngineVersion, looked up at runtime
+	ParameterGroupFamily string
+
+	// provisioned, serverless-v2 or serverless-v1 - capacity in ACUs for v2 and capacity units for v1
+	CapacityMode string
+	MinCapacity  float64
+	MaxCapacity  float64
+	// Pause a Serverless v1 cluster after AutoPauseSeconds without connections
+	AutoPause        bool
+	AutoPauseSeconds int64
+
+	// Parameter groups restoreRDS and its instances are created with - AWS defaults otherwise
+	ClusterParameterGroup string
+	ParameterGroup        string
+	// Copy the parameter groups of sourceRDS instead, with the overrides applied to the copies
+	CopySourceParameterGroups bool
+	ClusterParameterOverrides map[string]string
+	ParameterOverrides        map[string]string
+
+	// Instances of the restored cluster, the first one is the writer
+	Instances []InstanceSpec
+	// Recreate the instances of sourceRDS instead, with classes mapped through InstanceClassMapping
+	MirrorSourceTopology bool
+	InstanceClassMapping map[string]string
+
+	// ID of the current run, tagged on the restored cluster
+	RunID string
+	// Delete restoreRDS even if it isn't tagged as created by this tool from sourceRDS
+	ForceDelete bool
+
+	// Regexes of cluster identifiers that must never be deleted
+	ProtectedIdentifiers []string
+	// AWS accounts destructive calls are allowed in - empty allows any account
+	AllowedAccountIDs []string
+
+	// Template of the final snapshot taken before restoreRDS is deleted - empty skips the snapshot
+	FinalSnapshotIdentifier string
+	// Number of final snapshots to keep, older ones are pruned - 0 keeps all
+	FinalSnapshotRetention int
+
+	// Timeout of every wait and its poll intervals - zero uses the defaults
+	WaitTimeout         time.Duration
+	WaitPollInterval    time.Duration
+	WaitMaxPollInterval time.Duration
+
+	// Directory the last completed step of a run is saved to, so that a rerun
+	// with the same run ID resumes after it - empty disables checkpoints
+	CheckpointDir string
+}
+
+// ConfigError lists every problem found in a RestoreConfig
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("Invalid restore config:\n  - %v\n", strings.Join(e.Problems, "\n  - "))
+}
+
+// Raw settings of a restore job as they appear in env vars or a config file,
+// field names match the env var names except rdsSecurityGroupIds, a list in the
+// file and the comma separated rdsSecurityGroupId env var
+type restoreSettings struct {
+	Name string `yaml:"name" json:"name"`
+
+	AWSRegion        string   `yaml:"awsRegion" json:"awsRegion"`
+	SourceRegion     string   `yaml:"sourceRegion" json:"sourceRegion"`
+	KMSKeyID         string   `yaml:"rdsKmsKeyId" json:"rdsKmsKeyId"`
+	SourceRoleARN    string   `yaml:"sourceRoleArn" json:"sourceRoleArn"`
+	TargetRoleARN    string   `yaml:"targetRoleArn" json:"targetRoleArn"`
+	SourceKMSKeyID   string   `yaml:"sourceKmsKeyId" json:"sourceKmsKeyId"`
+	SourceRDS        string   `yaml:"sourceRDS" json:"sourceRDS"`
+	RestoreRDS       string   `yaml:"restoreRDS" json:"restoreRDS"`
+	SubnetGroup      string   `yaml:"rdsSubnetGroup" json:"rdsSubnetGroup"`
+	SecurityGroupIDs []string `yaml:"rdsSecurityGroupIds" json:"rdsSecurityGroupIds"`
+	RestoreSource    string   `yaml:"restoreSource" json:"restoreSource"`
+	RestoreDate      string   `yaml:"restoreDate" json:"restoreDate"`
+	RestoreTime      string   `yaml:"restoreTime" json:"restoreTime"`
+	RestoreType      string   `yaml:"restoreType" json:"restoreType"`
+	SnapshotID       string   `yaml:"restoreSnapshotIdentifier" json:"restoreSnapshotIdentifier"`
+	SnapshotTag      string   `yaml:"restoreSnapshotTag" json:"restoreSnapshotTag"`
+	InstanceType     string   `yaml:"rdsInstanceType" json:"rdsInstanceType"`
+	Engine           string   `yaml:"rdsEngine" json:"rdsEngine"`
+	EngineVersion    string   `yaml:"rdsEngineVersion" json:"rdsEngineVersion"`
+
+	CapacityMode     string  `yaml:"rdsCapacityMode" json:"rdsCapacityMode"`
+	MinCapacity      float64 `yaml:"serverlessMinCapacity" json:"serverlessMinCapacity"`
+	MaxCapacity      float64 `yaml:"serverlessMaxCapacity" json:"serverlessMaxCapacity"`
+	AutoPause        *bool   `yaml:"serverlessAutoPause" json:"serverlessAutoPause"`
+	AutoPauseSeconds int64   `yaml:"serverlessAutoPauseSeconds" json:"serverlessAutoPauseSeconds"`
+
+	ClusterParameterGroup     string            `yaml:"rdsClusterParameterGroup" json:"rdsClusterParameterGroup"`
+	ParameterGroup            string            `yaml:"rdsParameterGroup" json:"rdsParameterGroup"`
+	CopySourceParameterGroups *bool             `yaml:"copySourceParameterGroups" json:"copySourceParameterGroups"`
+	ClusterParameterOverrides map[string]string `yaml:"rdsClusterParameterOverrides" json:"rdsClusterParameterOverrides"`
+	ParameterOverrides        map[string]string `yaml:"rdsParameterOverrides" json:"rdsParameterOverrides"`
+
+	Instances            []InstanceSpec    `yaml:"rdsInstances" json:"rdsInstances"`
+	MirrorSourceTopology *bool             `yaml:"mirrorSourceTopology" json:"mirrorSourceTopology"`
+	InstanceClassMapping map[string]string `yaml:"rdsInstanceClassMapping" json:"rdsInstanceClassMapping"`
+
+	ProtectedIdentifiers []string `yaml:"rdsProtectedIdentifiers" json:"rdsProtectedIdentifiers"`
+	AllowedAccountIDs    []string `yaml:"allowedAccountIds" json:"allowedAccountIds"`
+
+	FinalSnapshotIdentifier string `yaml:"finalSnapshotIdentifier" json:"finalSnapshotIdentifier"`
+	FinalSnapshotRetention  int    `yaml:"finalSnapshotRetention" json:"finalSnapshotRetention"`
+
+	// Go durations, e.g. "90m" or "30s"
+	WaitTimeout         string `yaml:"waitTimeout" json:"waitTimeout"`
+	WaitPollInterval    string `yaml:"waitPollInterval" json:"waitPollInterval"`
+	WaitMaxPollInterval string `yaml:"waitMaxPollInterval" json:"waitMaxPollInterval"`
+
+	CheckpointDir string `yaml:"checkpointDir" json:"checkpointDir"`
+}
+
+// Read settings from env vars, unset vars are left empty
+func restoreSettingsFromEnv(getenv func(string) string) (restoreSettings, []string) {
+	var problems []string
+
+	settings := restoreSettings{
+		AWSRegion:        getenv("awsRegion"),
+		SourceRegion:     getenv("sourceRegion"),
+		KMSKeyID:         getenv("rdsKmsKeyId"),
+		SourceRoleARN:    getenv("sourceRoleArn"),
+		TargetRoleARN:    getenv("targetRoleArn"),
+		SourceKMSKeyID:   getenv("sourceKmsKeyId"),
+		SourceRDS:        getenv("sourceRDS"),
+		RestoreRDS:       getenv("restoreRDS"),
+		SubnetGroup:      getenv("rdsSubnetGroup"),
+		SecurityGroupIDs: splitList(getenv("rdsSecurityGroupId")),
+		RestoreSource:    getenv("restoreSource"),
+		RestoreDate:      getenv("restoreDate"),
+		RestoreTime:      getenv("restoreTime"),
+		RestoreType:      getenv("restoreType"),
+		SnapshotID:       getenv("restoreSnapshotIdentifier"),
+		SnapshotTag:      getenv("restoreSnapshotTag"),
+		InstanceType:     getenv("rdsInstanceType"),
+		Engine:           getenv("rdsEngine"),
+		EngineVersion:    getenv("rdsEngineVersion"),
+		CapacityMode:     getenv("rdsCapacityMode"),
+
+		ClusterParameterGroup: getenv("rdsClusterParameterGroup"),
+		ParameterGroup:        getenv("rdsParameterGroup"),
+
+		ProtectedIdentifiers: splitList(getenv("rdsProtectedIdentifiers")),
+		AllowedAccountIDs:    splitList(getenv("allowedAccountIds")),
+
+		FinalSnapshotIdentifier: getenv("finalSnapshotIdentifier"),
+
+		WaitTimeout:         getenv("waitTimeout"),
+		WaitPollInterval:    getenv("waitPollInterval"),
+		WaitMaxPollInterval: getenv("waitMaxPollInterval"),
+
+		CheckpointDir: getenv("checkpointDir"),
+	}
+
+	if retention := getenv("finalSnapshotRetention"); retention != "" {
+		parsedRetention, err := strconv.Atoi(retention)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("finalSnapshotRetention [%v] is not a number", retention))
+		}
+		settings.FinalSnapshotRetention = parsedRetention
+	}
+
+	for _, capacity := range []struct {
+		name  string
+		value *float64
+	}{
+		{"serverlessMinCapacity", &settings.MinCapacity},
+		{"serverlessMaxCapacity", &settings.MaxCapacity},
+	} {
+		if raw := getenv(capacity.name); raw != "" {
+			parsedCapacity, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v [%v] is not a number", capacity.name, raw))
+			}
+			*capacity.value = parsedCapacity
+		}
+	}
+
+	if autoPause := getenv("serverlessAutoPause"); autoPause != "" {
+		parsedAutoPause, err := strconv.ParseBool(autoPause)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("serverlessAutoPause [%v] is not true or false", autoPause))
+		}
+		settings.AutoPause = &parsedAutoPause
+	}
+
+	if seconds := getenv("serverlessAutoPauseSeconds"); seconds != "" {
+		parsedSeconds, err := strconv.ParseInt(seconds, 10, 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("serverlessAutoPauseSeconds [%v] is not a number", seconds))
+		}
+		settings.AutoPauseSeconds = parsedSeconds
+	}
+
+	if mirror := getenv("mirrorSourceTopology"); mirror != "" {
+		parsedMirror, err := strconv.ParseBool(mirror)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("mirrorSourceTopology [%v] is not true or false", mirror))
+		}
+		settings.MirrorSourceTopology = &parsedMirror
+	}
+
+	if copyGroups := getenv("copySourceParameterGroups"); copyGroups != "" {
+		parsedCopyGroups, err := strconv.ParseBool(copyGroups)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("copySourceParameterGroups [%v] is not true or false", copyGroups))
+		}
+		settings.CopySourceParameterGroups = &parsedCopyGroups
+	}
+
+	// Comma separated source=target pairs, e.g. "db.r5.2xlarge=db.r5.large,db.r5.large=db.t3.medium"
+	var pairProblems []string
+	settings.InstanceClassMapping, pairProblems = parsePairs("rdsInstanceClassMapping", getenv("rdsInstanceClassMapping"), "source=target")
+	problems = append(problems, pairProblems...)
+
+	// Comma separated name=value pairs, e.g. "binlog_format=OFF,general_log=1"
+	settings.ClusterParameterOverrides, pairProblems = parsePairs("rdsClusterParameterOverrides", getenv("rdsClusterParameterOverrides"), "name=value")
+	problems = append(problems, pairProblems...)
+	settings.ParameterOverrides, pairProblems = parsePairs("rdsParameterOverrides", getenv("rdsParameterOverrides"), "name=value")
+	problems = append(problems, pairProblems...)
+
+	return settings, problems
+}
+
+// Parse a comma separated list of key=value pairs, nil if there are none
+func parsePairs(name string, value string, format string) (map[string]string, []string) {
+	var pairs map[string]string
+	var problems []string
+
+	for _, pair := range splitList(value) {
+		keyValue := strings.SplitN(pair, "=", 2)
+		if len(keyValue) != 2 {
+			problems = append(problems, fmt.Sprintf("%v [%v] is not a %v pair", name, pair, format))
+			continue
+		}
+		if pairs == nil {
+			pairs = map[string]string{}
+		}
+		pairs[strings.TrimSpace(keyValue[0])] = strings.TrimSpace(keyValue[1])
+	}
+	return pairs, problems
+}
+
+// Merge key=value pairs key-wise, overrides win for the same key
+func mergePairs(pairs map[string]string, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return pairs
+	}
+
+	merged := map[string]string{}
+	for key, value := range pairs {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Return a copy of the settings with every non-empty field of overrides applied
+func (s restoreSettings) merge(overrides restoreSettings) restoreSettings {
+	mergeString := func(value *string, override string) {
+		if override != "" {
+			*value = override
+		}
+	}
+
+	merged := s
+	mergeString(&merged.Name, overrides.Name)
+	mergeString(&merged.AWSRegion, overrides.AWSRegion)
+	mergeString(&merged.SourceRegion, overrides.SourceRegion)
+	mergeString(&merged.KMSKeyID, overrides.KMSKeyID)
+	mergeString(&merged.SourceRoleARN, overrides.SourceRoleARN)
+	mergeString(&merged.TargetRoleARN, overrides.TargetRoleARN)
+	mergeString(&merged.SourceKMSKeyID, overrides.SourceKMSKeyID)
+	mergeString(&merged.SourceRDS, overrides.SourceRDS)
+	mergeString(&merged.RestoreRDS, overrides.RestoreRDS)
+	mergeString(&merged.SubnetGroup, overrides.SubnetGroup)
+	mergeString(&merged.RestoreSource, overrides.RestoreSource)
+	mergeString(&merged.RestoreDate, overrides.RestoreDate)
+	mergeString(&merged.RestoreTime, overrides.RestoreTime)
+	mergeString(&merged.RestoreType, overrides.RestoreType)
+	mergeString(&merged.SnapshotID, overrides.SnapshotID)
+	mergeString(&merged.SnapshotTag, overrides.SnapshotTag)
+	mergeString(&merged.InstanceType, overrides.InstanceType)
+	mergeString(&merged.Engine, overrides.Engine)
+	mergeString(&merged.EngineVersion, overrides.EngineVersion)
+	mergeString(&merged.CapacityMode, overrides.CapacityMode)
+	mergeString(&merged.ClusterParameterGroup, overrides.ClusterParameterGroup)
+	mergeString(&merged.ParameterGroup, overrides.ParameterGroup)
+	mergeString(&merged.FinalSnapshotIdentifier, overrides.FinalSnapshotIdentifier)
+	mergeString(&merged.WaitTimeout, overrides.WaitTimeout)
+	mergeString(&merged.WaitPollInterval, overrides.WaitPollInterval)
+	mergeString(&merged.WaitMaxPollInterval, overrides.WaitMaxPollInterval)
+	mergeString(&merged.CheckpointDir, overrides.CheckpointDir)
+	if overrides.FinalSnapshotRetention != 0 {
+		merged.FinalSnapshotRetention = overrides.FinalSnapshotRetention
+	}
+	if overrides.MinCapacity != 0 {
+		merged.MinCapacity = overrides.MinCapacity
+	}
+	if overrides.MaxCapacity != 0 {
+		merged.MaxCapacity = overrides.MaxCapacity
+	}
+	if overrides.AutoPause != nil {
+		merged.AutoPause = overrides.AutoPause
+	}
+	if overrides.AutoPauseSeconds != 0 {
+		merged.AutoPauseSeconds = overrides.AutoPauseSeconds
+	}
+	if len(overrides.SecurityGroupIDs) > 0 {
+		merged.SecurityGroupIDs = overrides.SecurityGroupIDs
+	}
+	if len(overrides.Instances) > 0 {
+		merged.Instances = overrides.Instances
+	}
+	if overrides.MirrorSourceTopology != nil {
+		merged.MirrorSourceTopology = overrides.MirrorSourceTopology
+	}
+	if overrides.CopySourceParameterGroups != nil {
+		merged.CopySourceParameterGroups = overrides.CopySourceParameterGroups
+	}
+	// Class mappings and parameter overrides add up, overrides win for the same key
+	merged.InstanceClassMapping = mergePairs(s.InstanceClassMapping, overrides.InstanceClassMapping)
+	merged.ClusterParameterOverrides = mergePairs(s.ClusterParameterOverrides, overrides.ClusterParameterOverrides)
+	merged.ParameterOverrides = mergePairs(s.ParameterOverrides, overrides.ParameterOverrides)
+	if len(overrides.AllowedAccountIDs) > 0 {
+		merged.AllowedAccountIDs = overrides.AllowedAccountIDs
+	}
+	// Protected identifiers add up, a job can't drop a protection from the defaults
+	merged.ProtectedIdentifiers = append(append([]string{}, s.ProtectedIdentifiers...), overrides.ProtectedIdentifiers...)
+	return merged
+}
+
+// Name used to refer to the job in logs and errors
+func (s restoreSettings) jobName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.RestoreRDS
+}
+
+// Parse settings into a RestoreConfig, applying defaults for optional ones
+// and reporting every problem at once
+func (s restoreSettings) restoreConfig() (*RestoreConfig, []string) {
+	restoreConfig := &RestoreConfig{
+		Name:             s.jobName(),
+		AWSRegion:        s.AWSRegion,
+		SourceRegion:     s.SourceRegion,
+		KMSKeyID:         s.KMSKeyID,
+		SourceRoleARN:    s.SourceRoleARN,
+		TargetRoleARN:    s.TargetRoleARN,
+		SourceKMSKeyID:   s.SourceKMSKeyID,
+		SourceRDS:        s.SourceRDS,
+		RestoreRDS:       s.RestoreRDS,
+		SubnetGroup:      s.SubnetGroup,
+		SecurityGroupIDs: s.SecurityGroupIDs,
+		RestoreSource:    s.RestoreSource,
+		RestoreType:      s.RestoreType,
+		InstanceType:     s.InstanceType,
+		Engine:           RDSEngine(s.Engine),
+		EngineVersion:    s.EngineVersion,
+		CapacityMode:     s.CapacityMode,
+		MinCapacity:      s.MinCapacity,
+		MaxCapacity:      s.MaxCapacity,
+		AutoPause:        s.AutoPause == nil || *s.AutoPause,
+		AutoPauseSeconds: s.AutoPauseSeconds,
+
+		ClusterParameterGroup:     s.ClusterParameterGroup,
+		ParameterGroup:            s.ParameterGroup,
+		CopySourceParameterGroups: s.CopySourceParameterGroups != nil && *s.CopySourceParameterGroups,
+		ClusterParameterOverrides: s.ClusterParameterOverrides,
+		ParameterOverrides:        s.ParameterOverrides,
+		Instances:                 s.Instances,
+
+		MirrorSourceTopology: s.MirrorSourceTopology != nil && *s.MirrorSourceTopology,
+		InstanceClassMapping: s.InstanceClassMapping,
+
+		ProtectedIdentifiers: s.ProtectedIdentifiers,
+		AllowedAccountIDs:    s.AllowedAccountIDs,
+
+		FinalSnapshotIdentifier: s.FinalSnapshotIdentifier,
+		CheckpointDir:           s.CheckpointDir,
+		FinalSnapshotRetention:  s.FinalSnapshotRetention,
+
+		SnapshotIdentifier: s.SnapshotID,
+		SnapshotTag:        s.SnapshotTag,
+	}
+
+	var problems []string
+
+	// Optional restore date and time - defaults to latest available point in time
+	restoreTime, parseErr := parseRestoreTime(s.RestoreDate, s.RestoreTime)
+	if parseErr != nil {
+		problems = append(problems, parseErr.Error())
+	}
+	restoreConfig.RestoreTime = restoreTime
+
+	for _, duration := range []struct {
+		name  string
+		raw   string
+		value *time.Duration
+	}{
+		{"waitTimeout", s.WaitTimeout, &restoreConfig.WaitTimeout},
+		{"waitPollInterval", s.WaitPollInterval, &restoreConfig.WaitPollInterval},
+		{"waitMaxPollInterval", s.WaitMaxPollInterval, &restoreConfig.WaitMaxPollInterval},
+	} {
+		if duration.raw == "" {
+			continue
+		}
+		parsedDuration, err := time.ParseDuration(duration.raw)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v [%v] is not a duration, e.g. 90m or 30s", duration.name, duration.raw))
+		}
+		*duration.value = parsedDuration
+	}
+
+	restoreConfig.applyDefaults()
+
+	problems = append(problems, restoreConfig.problems()...)
+	return restoreConfig, problems
+}
+
+// Build RestoreConfig from env vars, applying defaults for optional ones
+func restoreConfigFromEnv(getenv func(string) string) (*RestoreConfig, error) {
+	envSettings, problems := restoreSettingsFromEnv(getenv)
+	restoreConfig, configProblems := envSettings.restoreConfig()
+	problems = append(problems, configProblems...)
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return restoreConfig, nil
+}
+
+// Parse restoreDate (YYYY-MM-DD) and optional restoreTime (HH:MM:SS) in UTC
+func parseRestoreTime(restoreDate string, restoreTime string) (time.Time, error) {
+	if restoreDate == "" {
+		if restoreTime != "" {
+			return time.Time{}, fmt.Errorf("restoreTime [%v] set without restoreDate", restoreTime)
+		}
+		return time.Time{}, nil
+	}
+	if restoreTime == "" {
+		restoreTime = defaultRestoreTime
+	}
+
+	parsedTime, err := time.Parse(restoreDateLayout+"T"+restoreTimeLayout, restoreDate+"T"+restoreTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse restore point [%vT%v], expected restoreDate as YYYY-MM-DD and restoreTime as HH:MM:SS", restoreDate, restoreTime)
+	}
+	return parsedTime, nil
+}
+
+// Split a comma separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Fill in defaults for optional settings
+func (c *RestoreConfig) applyDefaults() {
+	if c.RestoreSource == "" {
+		c.RestoreSource = defaultRestoreSource
+	}
+	if c.RestoreType == "" {
+		c.RestoreType = defaultRestoreType
+	}
+	c.applyCapacityDefaults()
+	if c.InstanceType == "" {
+		c.InstanceType = defaultInstanceType
+	}
+}
+
+// Validate reports every problem of the config at once
+func (c *RestoreConfig) Validate() error {
+	if problems := c.problems(); len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+func (c *RestoreConfig) problems() []string {
+	var problems []string
+
+	if c.AWSRegion == "" {
+		problems = append(problems, "awsRegion is required")
+	}
+
+	for _, identifier := range []struct {
+		name  string
+		value string
+	}{
+		{"sourceRDS", c.SourceRDS},
+		{"restoreRDS", c.RestoreRDS},
+	} {
+		switch {
+		case identifier.value == "":
+			problems = append(problems, fmt.Sprintf("%v is required", identifier.name))
+		case len(identifier.value) > 63 || !rdsIdentifierRegex.MatchString(identifier.value):
+			problems = append(problems, fmt.Sprintf("%v [%v] is not a valid RDS cluster identifier", identifier.name, identifier.value))
+		}
+	}
+
+	for _, securityGroupID := range c.SecurityGroupIDs {
+		if !strings.HasPrefix(securityGroupID, "sg-") {
+			problems = append(problems, fmt.Sprintf("rdsSecurityGroupId [%v] is not a security group ID (sg-...)", securityGroupID))
+		}
+	}
+
+	if !c.RestoreTime.IsZero() && c.RestoreTime.After(time.Now()) {
+		problems = append(problems, fmt.Sprintf("restore point [%v] is in the future", c.RestoreTime.Format(time.RFC3339)))
+	}
+
+	problems = append(problems, c.restoreSourceProblems()...)
+	problems = append(problems, c.cloneProblems()...)
+	problems = append(problems, c.crossRegionProblems()...)
+	problems = append(problems, c.crossAccountProblems()...)
+	problems = append(problems, c.capacityProblems()...)
+	problems = append(problems, c.parameterGroupProblems()...)
+	problems = append(problems, c.waitProblems()...)
+
+	if !isInstanceClass(c.InstanceType) {
+		problems = append(problems, fmt.Sprintf("rdsInstanceType [%v] is not an RDS instance class (db.*)", c.InstanceType))
+	}
+
+	problems = append(problems, topologyProblems(c)...)
+
+	if c.Engine != "" && !c.Engine.supported() {
+		problems = append(problems, fmt.Sprintf("rdsEngine [%v] is not supported, expected one of %v", c.Engine, supportedEngines))
+	}
+
+	for _, protectedIdentifier := range c.ProtectedIdentifiers {
+		if _, err := regexp.Compile(protectedIdentifier); err != nil {
+			problems = append(problems, fmt.Sprintf("rdsProtectedIdentifiers [%v] is not a valid regex: %v", protectedIdentifier, err))
+		}
+	}
+
+	if c.FinalSnapshotIdentifier != "" {
+		// Run ID is only set once the config is loaded
+		sampleConfig := *c
+		if sampleConfig.RunID == "" {
+			sampleConfig.RunID = sampleRunID
+		}
+		sampleIdentifier := renderFinalSnapshotIdentifier(&sampleConfig, time.Now())
+		if len(sampleIdentifier) > 255 || !rdsIdentifierRegex.MatchString(sampleIdentifier) {
+			problems = append(problems, fmt.Sprintf("finalSnapshotIdentifier [%v] renders to [%v] which is not a valid snapshot identifier", c.FinalSnapshotIdentifier, sampleIdentifier))
+		}
+		// Same identifier on every run fails the second one with DBClusterSnapshotAlreadyExistsFault
+		if !strings.Contains(c.FinalSnapshotIdentifier, "{timestamp}") && !strings.Contains(c.FinalSnapshotIdentifier, "{runId}") {
+			problems = append(problems, fmt.Sprintf("finalSnapshotIdentifier [%v] needs {timestamp} or {runId} to be unique per run", c.FinalSnapshotIdentifier))
+		}
+	} else if c.FinalSnapshotRetention != 0 {
+		problems = append(problems, "finalSnapshotRetention set without finalSnapshotIdentifier")
+	}
+	if c.FinalSnapshotRetention < 0 {
+		problems = append(problems, fmt.Sprintf("finalSnapshotRetention [%v] can't be negative", c.FinalSnapshotRetention))
+	}
+
+	for _, accountID := range c.AllowedAccountIDs {
+		if !awsAccountIDRegex.MatchString(accountID) {
+			problems = append(problems, fmt.Sprintf("allowedAccountIds [%v] is not a 12 digit AWS account ID", accountID))
+		}
+	}
+
+	return problems
+}
+
+func (c *RestoreConfig) restoreSourceProblems() []string {
+	var problems []string
+
+	if _, ok := findRestoreStrategy(c.RestoreSource); !ok {
+		problems = append(problems, fmt.Sprintf("restoreSource [%v] is not supported, expected one of %v", c.RestoreSource, restoreStrategyNames()))
+	}
+	if c.RestoreSource != sourcePointInTime && !c.RestoreTime.IsZero() {
+		problems = append(problems, fmt.Sprintf("restoreDate can only be used with restoreSource %v", sourcePointInTime))
+	}
+
+	switch {
+	case c.RestoreSource == sourceSnapshot && c.SnapshotIdentifier == "":
+		problems = append(problems, fmt.Sprintf("restoreSnapshotIdentifier is required with restoreSource %v", sourceSnapshot))
+	case c.RestoreSource != sourceSnapshot && c.SnapshotIdentifier != "":
+		problems = append(problems, fmt.Sprintf("restoreSnapshotIdentifier can only be used with restoreSource %v", sourceSnapshot))
+	}
+
+	if c.RestoreSource == sourceLatestTaggedSnapshot {
+		if _, _, ok := parseSnapshotTag(c.SnapshotTag); !ok {
+			problems = append(problems, fmt.Sprintf("restoreSnapshotTag [%v] is required as key=value with restoreSource %v", c.SnapshotTag, sourceLatestTaggedSnapshot))
+		}
+	} else if c.SnapshotTag != "" {
+		problems = append(problems, fmt.Sprintf("restoreSnapshotTag can only be used with restoreSource %v", sourceLatestTaggedSnapshot))
+	}
+
+	return problems
+}
+
+func isInstanceClass(instanceClass string) bool {
+	return strings.HasPrefix(instanceClass, "db.")
+}
+
+func (e RDSEngine) supported() bool {
+	for _, engine := range supportedEngines {
+		if e == engine {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config file describing one or more restore jobs, e.g.
+//
+//	defaults:
+//	  awsRegion: us-east-1
+//	  rdsSubnetGroup: rds-private-subnet
+//	jobs:
+//	  - sourceRDS: test-db
+//	    restoreRDS: test-db-restore
+//	    rdsSecurityGroupIds: [sg-03254e409e0bd8218]
+type restoreConfigFile struct {
+	// Settings shared by every job, each job can override them
+	Defaults restoreSettings `yaml:"defaults" json:"defaults"`
+
+	Jobs []restoreSettings `yaml:"jobs" json:"jobs"`
+}
+
+// Load restore jobs - from the config file if one is given, otherwise a single
+// job from env vars. Env vars that are set override the matching setting of
+// every job in the file.
+func loadRestoreConfigs(configPath string, getenv func(string) string) ([]*RestoreConfig, error) {
+	if configPath == "" {
+		restoreConfig, configErr := restoreConfigFromEnv(getenv)
+		if configErr != nil {
+			return nil, configErr
+		}
+		return []*RestoreConfig{restoreConfig}, nil
+	}
+
+	configFile, readErr := readRestoreConfigFile(configPath)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	if len(configFile.Jobs) == 0 {
+		return nil, &ConfigError{Problems: []string{fmt.Sprintf("config file [%v] doesn't define any jobs", configPath)}}
+	}
+
+	envSettings, problems := restoreSettingsFromEnv(getenv)
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+
+	var restoreConfigs []*RestoreConfig
+	jobNames := map[string]bool{}
+	// Jobs by the restoreRDS they delete and recreate, per region and target account
+	targetJobs := map[string]string{}
+
+	for jobIndex, job := range configFile.Jobs {
+		settings := configFile.Defaults.merge(job).merge(envSettings)
+
+		jobName := settings.jobName()
+		if jobName == "" {
+			jobName = fmt.Sprintf("#%d", jobIndex)
+		}
+		if jobNames[jobName] {
+			problems = append(problems, fmt.Sprintf("job [%v]: defined more than once", jobName))
+		}
+		jobNames[jobName] = true
+
+		restoreConfig, jobProblems := settings.restoreConfig()
+		for _, problem := range jobProblems {
+			problems = append(problems, fmt.Sprintf("job [%v]: %v", jobName, problem))
+		}
+
+		// Env vars apply to every job, a restoreRDS set there would have each job replace the previous one's restore
+		if restoreConfig.RestoreRDS != "" {
+			targetKey := strings.Join([]string{restoreConfig.AWSRegion, restoreConfig.TargetRoleARN, strings.ToLower(restoreConfig.RestoreRDS)}, " ")
+			if otherJob, ok := targetJobs[targetKey]; ok {
+				problems = append(problems, fmt.Sprintf("job [%v]: restoreRDS [%v] is also restored by job [%v]", jobName, restoreConfig.RestoreRDS, otherJob))
+			} else {
+				targetJobs[targetKey] = jobName
+			}
+		}
+		restoreConfigs = append(restoreConfigs, restoreConfig)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return restoreConfigs, nil
+}
+
+// Read a YAML or JSON config file - unknown keys are rejected so that typos don't go unnoticed
+func readRestoreConfigFile(configPath string) (*restoreConfigFile, error) {
+	content, readErr := os.ReadFile(configPath)
+	if readErr != nil {
+		return nil, fmt.Errorf("Cannot read config file: %w", readErr)
+	}
+
+	configFile := &restoreConfigFile{}
+
+	switch filepath.Ext(configPath) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(configFile); err != nil {
+			return nil, fmt.Errorf("Cannot parse JSON config file [%v]: %w", configPath, err)
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(configFile); err != nil {
+			return nil, fmt.Errorf("Cannot parse YAML config file [%v]: %w", configPath, err)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported config file [%v], expected .yaml, .yml or .json", configPath)
+	}
+
+	return configFile, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return configPath
+}
+
+func TestLoadRestoreConfigsYAML(t *testing.T) {
+	configPath := writeConfigFile(t, "restore.yaml", `
+defaults:
+  awsRegion: us-east-1
+  rdsSubnetGroup: rds-private-subnet
+  rdsSecurityGroupIds: [sg-03254e409e0bd8218]
+jobs:
+  - name: staging
+    sourceRDS: test-db
+    restoreRDS: test-db-restore
+    restoreDate: "2021-08-21"
+  - sourceRDS: other-db
+    restoreRDS: other-db-restore
+    rdsInstanceType: db.r5.large
+`)
+
+	restoreConfigs, err := loadRestoreConfigs(configPath, envFromMap(nil))
+	if err != nil {
+		t.Fatalf("loadRestoreConfigs: %v", err)
+	}
+	if len(restoreConfigs) != 2 {
+		t.Fatalf("got %d jobs, want 2", len(restoreConfigs))
+	}
+
+	staging, other := restoreConfigs[0], restoreConfigs[1]
+	if staging.Name != "staging" || other.Name != "other-db-restore" {
+		t.Errorf("job names = [%v %v], want [staging other-db-restore]", staging.Name, other.Name)
+	}
+	if staging.SubnetGroup != "rds-private-subnet" || other.AWSRegion != "us-east-1" {
+		t.Errorf("defaults not applied: %+v %+v", staging, other)
+	}
+	if staging.RestoreTime.IsZero() || !other.RestoreTime.IsZero() {
+		t.Errorf("unexpected restore times: %v %v", staging.RestoreTime, other.RestoreTime)
+	}
+	if staging.InstanceType != defaultInstanceType || other.InstanceType != "db.r5.large" {
+		t.Errorf("instance types = [%v %v]", staging.InstanceType, other.InstanceType)
+	}
+}
+
+func TestLoadRestoreConfigsJSONWithEnvOverrides(t *testing.T) {
+	configPath := writeConfigFile(t, "restore.json", `{
+  "jobs": [
+    {"awsRegion": "us-east-1", "sourceRDS": "test-db", "restoreRDS": "test-db-restore", "rdsSecurityGroupIds": ["sg-1"]}
+  ]
+}`)
+
+	restoreConfigs, err := loadRestoreConfigs(configPath, envFromMap(map[string]string{
+		"awsRegion":          "eu-west-1",
+		"rdsSecurityGroupId": "sg-2,sg-3",
+	}))
+	if err != nil {
+		t.Fatalf("loadRestoreConfigs: %v", err)
+	}
+
+	if restoreConfigs[0].AWSRegion != "eu-west-1" {
+		t.Errorf("AWSRegion = %v, want env override eu-west-1", restoreConfigs[0].AWSRegion)
+	}
+	if want := []string{"sg-2", "sg-3"}; !reflect.DeepEqual(restoreConfigs[0].SecurityGroupIDs, want) {
+		t.Errorf("SecurityGroupIDs = %v, want %v", restoreConfigs[0].SecurityGroupIDs, want)
+	}
+}
+
+func TestLoadRestoreConfigsRejectsUnknownKeys(t *testing.T) {
+	configPath := writeConfigFile(t, "restore.yaml", `
+jobs:
+  - awsRegion: us-east-1
+    sourceRDS: test-db
+    restoreRDS: test-db-restore
+    rdsSubnetGrup: rds-private-subnet
+`)
+
+	if _, err := loadRestoreConfigs(configPath, envFromMap(nil)); err == nil {
+		t.Fatalf("expected error for unknown key rdsSubnetGrup")
+	}
+}
+
+func TestLoadRestoreConfigsReportsProblemsPerJob(t *testing.T) {
+	configPath := writeConfigFile(t, "restore.yaml", `
+defaults:
+  awsRegion: us-east-1
+jobs:
+  - name: one
+    sourceRDS: test-db
+  - name: one
+    sourceRDS: test-db
+    restoreRDS: test-db-restore
+`)
+
+	_, err := loadRestoreConfigs(configPath, envFromMap(nil))
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	want := []string{"job [one]: restoreRDS is required", "job [one]: defined more than once"}
+	if !reflect.DeepEqual(configErr.Problems, want) {
+		t.Errorf("problems = %v, want %v", configErr.Problems, want)
+	}
+}
+
+func TestLoadRestoreConfigsRejectsSharedRestoreRDS(t *testing.T) {
+	configPath := writeConfigFile(t, "restore.yaml", `
+defaults:
+  awsRegion: us-east-1
+jobs:
+  - name: staging
+    sourceRDS: test-db
+    restoreRDS: test-db-restore
+  - name: reporting
+    sourceRDS: other-db
+    restoreRDS: other-db-restore
+`)
+
+	_, err := loadRestoreConfigs(configPath, envFromMap(map[string]string{"restoreRDS": "shared-restore"}))
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	want := []string{"job [reporting]: restoreRDS [shared-restore] is also restored by job [staging]"}
+	if !reflect.DeepEqual(configErr.Problems, want) {
+		t.Errorf("problems = %v, want %v", configErr.Problems, want)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func envFromMap(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestRestoreConfigFromEnvDefaults(t *testing.T) {
+	restoreConfig, err := restoreConfigFromEnv(envFromMap(map[string]string{
+		"awsRegion":          "us-east-1",
+		"sourceRDS":          "test-db",
+		"restoreRDS":         "test-db-restore",
+		"rdsSubnetGroup":     "rds-private-subnet",
+		"rdsSecurityGroupId": "sg-03254e409e0bd8218, sg-0a1b2c3d",
+	}))
+	if err != nil {
+		t.Fatalf("restoreConfigFromEnv: %v", err)
+	}
+
+	if restoreConfig.InstanceType != defaultInstanceType {
+		t.Errorf("InstanceType = %v, want %v", restoreConfig.InstanceType, defaultInstanceType)
+	}
+	if restoreConfig.Engine != "" {
+		t.Errorf("Engine = %v, want empty until detected from sourceRDS", restoreConfig.Engine)
+	}
+	if !restoreConfig.RestoreTime.IsZero() {
+		t.Errorf("RestoreTime = %v, want latest (zero)", restoreConfig.RestoreTime)
+	}
+	wantSecurityGroups := []string{"sg-03254e409e0bd8218", "sg-0a1b2c3d"}
+	if !reflect.DeepEqual(restoreConfig.SecurityGroupIDs, wantSecurityGroups) {
+		t.Errorf("SecurityGroupIDs = %v, want %v", restoreConfig.SecurityGroupIDs, wantSecurityGroups)
+	}
+}
+
+func TestRestoreConfigFromEnvRestoreTime(t *testing.T) {
+	tests := []struct {
+		date string
+		time string
+		want time.Time
+	}{
+		{"2021-08-21", "21:00:00", time.Date(2021, 8, 21, 21, 0, 0, 0, time.UTC)},
+		{"2021-08-21", "", time.Date(2021, 8, 21, 1, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		restoreConfig, err := restoreConfigFromEnv(envFromMap(map[string]string{
+			"awsRegion":   "us-east-1",
+			"sourceRDS":   "test-db",
+			"restoreRDS":  "test-db-restore",
+			"restoreDate": test.date,
+			"restoreTime": test.time,
+		}))
+		if err != nil {
+			t.Fatalf("restoreConfigFromEnv(%v, %v): %v", test.date, test.time, err)
+		}
+		if !restoreConfig.RestoreTime.Equal(test.want) {
+			t.Errorf("RestoreTime(%v, %v) = %v, want %v", test.date, test.time, restoreConfig.RestoreTime, test.want)
+		}
+	}
+}
+
+func TestRestoreConfigFromEnvReportsAllProblems(t *testing.T) {
+	_, err := restoreConfigFromEnv(envFromMap(map[string]string{
+		"sourceRDS":          "test_db",
+		"rdsSecurityGroupId": "03254e409e0bd8218",
+		"restoreDate":        "21-08-2021",
+		"rdsInstanceType":    "t3.small",
+		"rdsEngine":          "mysql",
+	}))
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	// restoreDate, awsRegion, sourceRDS, restoreRDS, security group, instance type, engine
+	if len(configErr.Problems) != 7 {
+		t.Errorf("got %d problems, want 7:\n%v", len(configErr.Problems), configErr)
+	}
+}
+
+func TestRestoreConfigValidate(t *testing.T) {
+	restoreConfig := testRestoreConfig()
+	if err := restoreConfig.Validate(); err != nil {
+		t.Fatalf("Validate valid config: %v", err)
+	}
+
+	restoreConfig.RestoreTime = time.Now().Add(time.Hour)
+	restoreConfig.RestoreRDS = "test-db-restore-"
+	err := restoreConfig.Validate()
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	if len(configErr.Problems) != 2 {
+		t.Errorf("got %d problems, want 2:\n%v", len(configErr.Problems), configErr)
+	}
+}
+
+func TestRestoreConfigValidateFinalSnapshotIdentifier(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{"{restoreRDS}-final-{timestamp}", true},
+		// Run ID is empty while the config is validated
+		{"{restoreRDS}-{runId}-final", true},
+		{"{restoreRDS}-final-{date}", false},
+		{"{restoreRDS}-final", false},
+		{"{restoreRDS}--{timestamp}", false},
+	}
+
+	for _, test := range tests {
+		restoreConfig := testRestoreConfig()
+		restoreConfig.RunID = ""
+		restoreConfig.FinalSnapshotIdentifier = test.template
+		err := restoreConfig.Validate()
+		if test.valid && err != nil {
+			t.Errorf("Validate(%v): %v", test.template, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Validate(%v) = nil, want ConfigError", test.template)
+		}
+	}
+}
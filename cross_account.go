@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// Only manual snapshots can be shared with another account
+const snapshotRestoreAttribute = "restore"
+
+func (c *RestoreConfig) crossAccount() bool {
+	return c.SourceRoleARN != ""
+}
+
+func (c *RestoreConfig) crossAccountProblems() []string {
+	var problems []string
+
+	for _, role := range []struct {
+		name  string
+		value string
+	}{
+		{"sourceRoleArn", c.SourceRoleARN},
+		{"targetRoleArn", c.TargetRoleARN},
+	} {
+		if role.value == "" {
+			continue
+		}
+		if roleARN, err := arn.Parse(role.value); err != nil || roleARN.Service != "iam" || !strings.HasPrefix(roleARN.Resource, "role/") {
+			problems = append(problems, fmt.Sprintf("%v [%v] is not an IAM role ARN", role.name, role.value))
+		}
+	}
+
+	if c.crossAccount() && c.RestoreSource == sourcePointInTime {
+		problems = append(problems, "sourceRoleArn needs a snapshot restoreSource, point-in-time restores can't cross accounts")
+	}
+	if !c.crossAccount() && c.SourceKMSKeyID != "" {
+		problems = append(problems, "sourceKmsKeyId set without sourceRoleArn")
+	}
+	return problems
+}
+
+// Look up the account restoreRDS is created in, the snapshot is shared with it
+// and a clone's source has to be in it
+func resolveTargetAccount(stsClientSess stsiface.STSAPI, restoreConfig *RestoreConfig) error {
+	resp, err := stsClientSess.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("Cannot resolve target AWS account, GetCallerIdentity err: %v", err)
+	}
+	restoreConfig.TargetAccountID = aws.StringValue(resp.Account)
+	logf("Target AWS account [%v]\n", restoreConfig.TargetAccountID)
+	return nil
+}
+
+// Identifier of the manual snapshot shared from the source account for this run
+func sharedSnapshotIdentifier(restoreConfig *RestoreConfig) string {
+	return strings.ToLower(fmt.Sprintf("%v-share-%v", restoreConfig.RestoreRDS, restoreConfig.RunID))
+}
+
+// Share the snapshot with the target account, copy it there with rdsKmsKeyId and
+// restore from the copy, then stop sharing and drop what this run created in
+// the source account
+func restoreFromSharedSnapshot(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshot *rds.DBClusterSnapshot) error {
+	sourceClientSess := sourceClient(rdsClientSess)
+
+	sharedSnapshot, temporary, shareErr := shareSnapshot(ctx, sourceClientSess, restoreConfig, snapshot)
+	if shareErr != nil {
+		return shareErr
+	}
+
+	restoreErr := restoreFromCopiedSnapshot(ctx, rdsClientSess, restoreConfig, sharedSnapshot)
+
+	// The copy in the target account may still be made from the shared snapshot
+	if isInterrupted(restoreErr) {
+		logf("Keeping snapshot [%v] shared with AWS account [%v] until the copy is done - the next run or cleanup deletes it\n", aws.StringValue(sharedSnapshot.DBClusterSnapshotIdentifier), restoreConfig.TargetAccountID)
+		return restoreErr
+	}
+
+	cleanupErr := unshareSnapshot(sourceClientSess, restoreConfig, sharedSnapshot, temporary)
+	return combineErrors([]error{restoreErr, cleanupErr})
+}
+
+// Share a manual snapshot with the target account - automated snapshots can't
+// be shared so they're copied to a manual one first. Returns whether the shared
+// snapshot was created by this run and has to be deleted afterwards.
+func shareSnapshot(ctx context.Context, sourceClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshot *rds.DBClusterSnapshot) (*rds.DBClusterSnapshot, bool, error) {
+	snapshotName := aws.StringValue(snapshot.DBClusterSnapshotIdentifier)
+	if status := aws.StringValue(snapshot.Status); status != "available" {
+		return nil, false, fmt.Errorf("Snapshot [%v] is [%v], not available", snapshotName, status)
+	}
+
+	temporary := createdByRun(snapshot.TagList, restoreConfig)
+	if aws.StringValue(snapshot.SnapshotType) != "manual" {
+		sharedSnapshotName := sharedSnapshotIdentifier(restoreConfig)
+		input := &rds.CopyDBClusterSnapshotInput{
+			SourceDBClusterSnapshotIdentifier: snapshot.DBClusterSnapshotIdentifier,
+			TargetDBClusterSnapshotIdentifier: aws.String(sharedSnapshotName),
+			Tags:                              restoreSnapshotTags(restoreConfig),
+		}
+		// The default aws/rds key can't be used from another account
+		if restoreConfig.SourceKMSKeyID != "" {
+			input.KmsKeyId = aws.String(restoreConfig.SourceKMSKeyID)
+		}
+
+		logf("Copying %v snapshot [%v] to manual snapshot [%v] to share it\n", aws.StringValue(snapshot.SnapshotType), snapshotName, sharedSnapshotName)
+		_, copyErr := sourceClientSess.CopyDBClusterSnapshot(input)
+		if copyErr != nil {
+			return nil, false, fmt.Errorf("Error copying snapshot [%v] to [%v]: %v", snapshotName, sharedSnapshotName, copyErr)
+		}
+
+		var waitErr error
+		snapshot, waitErr = waitUntilSnapshotAvailable(ctx, sourceClientSess, restoreConfig, sharedSnapshotName)
+		if waitErr != nil {
+			return nil, false, waitErr
+		}
+		snapshotName, temporary = sharedSnapshotName, true
+	}
+
+	logf("Sharing snapshot [%v] with AWS account [%v]\n", snapshotName, restoreConfig.TargetAccountID)
+	_, shareErr := sourceClientSess.ModifyDBClusterSnapshotAttribute(&rds.ModifyDBClusterSnapshotAttributeInput{
+		DBClusterSnapshotIdentifier: snapshot.DBClusterSnapshotIdentifier,
+		AttributeName:               aws.String(snapshotRestoreAttribute),
+		ValuesToAdd:                 aws.StringSlice([]string{restoreConfig.TargetAccountID}),
+	})
+	if shareErr != nil {
+		shareErr = fmt.Errorf("Error sharing snapshot [%v] with [%v]: %v", snapshotName, restoreConfig.TargetAccountID, shareErr)
+		if temporary {
+			return nil, false, combineErrors([]error{shareErr, deleteSnapshot(sourceClientSess, snapshot)})
+		}
+		return nil, false, shareErr
+	}
+	return snapshot, temporary, nil
+}
+
+// Stop sharing the snapshot with the target account, deleting it if this run created it
+func unshareSnapshot(sourceClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshot *rds.DBClusterSnapshot, temporary bool) error {
+	if temporary {
+		return deleteSnapshot(sourceClientSess, snapshot)
+	}
+
+	snapshotName := aws.StringValue(snapshot.DBClusterSnapshotIdentifier)
+	logf("Unsharing snapshot [%v] from AWS account [%v]\n", snapshotName, restoreConfig.TargetAccountID)
+	_, unshareErr := sourceClientSess.ModifyDBClusterSnapshotAttribute(&rds.ModifyDBClusterSnapshotAttributeInput{
+		DBClusterSnapshotIdentifier: snapshot.DBClusterSnapshotIdentifier,
+		AttributeName:               aws.String(snapshotRestoreAttribute),
+		ValuesToRemove:              aws.StringSlice([]string{restoreConfig.TargetAccountID}),
+	})
+	if unshareErr != nil {
+		return fmt.Errorf("Error unsharing snapshot [%v] from [%v]: %v", snapshotName, restoreConfig.TargetAccountID, unshareErr)
+	}
+	return nil
+}
+
+func deleteSnapshot(rdsClientSess rdsiface.RDSAPI, snapshot *rds.DBClusterSnapshot) error {
+	snapshotName := aws.StringValue(snapshot.DBClusterSnapshotIdentifier)
+	logf("Deleting snapshot [%v]\n", snapshotName)
+	_, deleteErr := rdsClientSess.DeleteDBClusterSnapshot(&rds.DeleteDBClusterSnapshotInput{
+		DBClusterSnapshotIdentifier: snapshot.DBClusterSnapshotIdentifier,
+	})
+	if deleteErr != nil {
+		return fmt.Errorf("Error deleting snapshot [%v]: %v", snapshotName, deleteErr)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func crossAccountRestoreConfig() *RestoreConfig {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.SourceRoleARN = "arn:aws:iam::111111111111:role/rds-restore-source"
+	restoreConfig.TargetRoleARN = "arn:aws:iam::222222222222:role/rds-restore-target"
+	restoreConfig.TargetAccountID = "222222222222"
+	restoreConfig.KMSKeyID = "arn:aws:kms:us-east-1:222222222222:key/staging"
+	return restoreConfig
+}
+
+func TestRestoreRDSClusterCrossAccount(t *testing.T) {
+	tests := []struct {
+		source      string
+		snapshotTag string
+		wantSource  []string
+	}{
+		{
+			sourceLatestAutomatedSnapshot, "",
+			[]string{"CopyDBClusterSnapshot", "ModifyDBClusterSnapshotAttribute", "DeleteDBClusterSnapshot"},
+		},
+		{
+			sourceNewSnapshot, "",
+			[]string{"CreateDBClusterSnapshot", "ModifyDBClusterSnapshotAttribute", "DeleteDBClusterSnapshot"},
+		},
+		{
+			sourceLatestTaggedSnapshot, "purpose=golden",
+			[]string{"ModifyDBClusterSnapshotAttribute", "ModifyDBClusterSnapshotAttribute"},
+		},
+	}
+
+	for _, test := range tests {
+		source := newFakeRDS()
+		source.addCluster("test-db", "available")
+		source.addClusterSnapshot("test-db", "rds:test-db-2021-08-21", time.Date(2021, 8, 21, 3, 0, 0, 0, time.UTC))
+		source.snapshots["rds:test-db-2021-08-21"].SnapshotType = aws.String("automated")
+		source.addClusterSnapshot("test-db", "test-db-golden", time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC))
+		source.snapshots["test-db-golden"].TagList = []*rds.Tag{{Key: aws.String("purpose"), Value: aws.String("golden")}}
+
+		target := newFakeRDS()
+
+		restoreConfig := crossAccountRestoreConfig()
+		restoreConfig.RestoreSource = test.source
+		restoreConfig.SnapshotTag = test.snapshotTag
+
+		if err := restoreRDSCluster(context.Background(), &jobRDS{RDSAPI: target, source: source}, restoreConfig); err != nil {
+			t.Fatalf("%v: restoreRDSCluster: %v", test.source, err)
+		}
+
+		if got := mutatingCalls(source); !reflect.DeepEqual(got, test.wantSource) {
+			t.Errorf("%v: source calls = %v, want %v", test.source, got, test.wantSource)
+		}
+		wantTarget := []string{"CopyDBClusterSnapshot", "RestoreDBClusterFromSnapshot", "DeleteDBClusterSnapshot", "CreateDBInstance"}
+		if got := mutatingCalls(target); !reflect.DeepEqual(got, wantTarget) {
+			t.Errorf("%v: target calls = %v, want %v", test.source, got, wantTarget)
+		}
+
+		share := source.snapshotAttributeInputs[0]
+		if aws.StringValue(share.AttributeName) != "restore" || !reflect.DeepEqual(aws.StringValueSlice(share.ValuesToAdd), []string{"222222222222"}) {
+			t.Errorf("%v: snapshot shared with %v=%v", test.source, aws.StringValue(share.AttributeName), aws.StringValueSlice(share.ValuesToAdd))
+		}
+		if got := aws.StringValue(target.copySnapshotInputs[0].KmsKeyId); got != restoreConfig.KMSKeyID {
+			t.Errorf("%v: target copy KmsKeyId = %v, want %v", test.source, got, restoreConfig.KMSKeyID)
+		}
+		// Source and target are in the same region, the copy isn't presigned
+		if copyInput := target.copySnapshotInputs[0]; copyInput.SourceRegion != nil || copyInput.PreSignedUrl != nil {
+			t.Errorf("%v: same-region copy has SourceRegion %v and PreSignedUrl %v", test.source, aws.StringValue(copyInput.SourceRegion), aws.StringValue(copyInput.PreSignedUrl))
+		}
+		if len(source.snapshots) != 2 || len(target.snapshots) != 0 {
+			t.Errorf("%v: %d source and %d target snapshots left, want only the original 2 source snapshots", test.source, len(source.snapshots), len(target.snapshots))
+		}
+	}
+}
+
+func TestResolveTargetAccount(t *testing.T) {
+	restoreConfig := crossAccountRestoreConfig()
+	restoreConfig.TargetAccountID = ""
+
+	if err := resolveTargetAccount(&fakeSTS{account: "222222222222"}, restoreConfig); err != nil {
+		t.Fatalf("resolveTargetAccount: %v", err)
+	}
+	if restoreConfig.TargetAccountID != "222222222222" {
+		t.Errorf("TargetAccountID = %v, want 222222222222", restoreConfig.TargetAccountID)
+	}
+}
+
+func TestCrossAccountProblems(t *testing.T) {
+	restoreConfig := crossAccountRestoreConfig()
+	restoreConfig.TargetRoleARN = "rds-restore-target"
+	restoreConfig.SourceKMSKeyID = "alias/share"
+
+	problems := restoreConfig.crossAccountProblems()
+	want := []string{
+		"targetRoleArn [rds-restore-target] is not an IAM role ARN",
+		"sourceRoleArn needs a snapshot restoreSource, point-in-time restores can't cross accounts",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
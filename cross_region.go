@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// RDS client of restoreRDS that also carries the client sourceRDS is reached
+// with when the source lives in another region or account
+type jobRDS struct {
+	rdsiface.RDSAPI
+
+	source rdsiface.RDSAPI
+}
+
+func (j *jobRDS) sourceRDSClient() rdsiface.RDSAPI {
+	return j.source
+}
+
+// Implemented by clients that reach sourceRDS with a separate client
+type sourceAwareRDS interface {
+	sourceRDSClient() rdsiface.RDSAPI
+}
+
+// Client to describe and snapshot sourceRDS with - the job client itself
+// unless the source is in another region or account
+func sourceClient(rdsClientSess rdsiface.RDSAPI) rdsiface.RDSAPI {
+	if sourceAware, ok := rdsClientSess.(sourceAwareRDS); ok {
+		return sourceAware.sourceRDSClient()
+	}
+	return rdsClientSess
+}
+
+// Region sourceRDS lives in
+func (c *RestoreConfig) sourceRegion() string {
+	if c.SourceRegion != "" {
+		return c.SourceRegion
+	}
+	return c.AWSRegion
+}
+
+func (c *RestoreConfig) crossRegion() bool {
+	return c.sourceRegion() != c.AWSRegion
+}
+
+func (c *RestoreConfig) crossRegionProblems() []string {
+	var problems []string
+
+	if c.crossRegion() {
+		if c.RestoreSource == sourcePointInTime {
+			problems = append(problems, fmt.Sprintf("sourceRegion [%v] needs a snapshot restoreSource, point-in-time restores can't cross regions", c.SourceRegion))
+		}
+	} else if c.KMSKeyID != "" && !c.crossAccount() {
+		problems = append(problems, "rdsKmsKeyId set without sourceRoleArn or a sourceRegion different from awsRegion")
+	}
+	return problems
+}
+
+// Identifier of the snapshot copied into awsRegion for this run
+func copiedSnapshotIdentifier(restoreConfig *RestoreConfig) string {
+	return strings.ToLower(fmt.Sprintf("%v-copy-%v", restoreConfig.RestoreRDS, restoreConfig.RunID))
+}
+
+// Copy the snapshot of sourceRDS into awsRegion, restore restoreRDS from the
+// copy and delete the copy once the cluster is created from it
+func restoreFromCopiedSnapshot(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshot *rds.DBClusterSnapshot) error {
+	copiedSnapshot, copyErr := copySnapshotToRegion(ctx, rdsClientSess, restoreConfig, snapshot)
+	if copyErr != nil {
+		return copyErr
+	}
+
+	restoreErr := restoreClusterFromSnapshot(rdsClientSess, restoreConfig, copiedSnapshot)
+	if restoreErr == nil {
+		// The copy is only needed until the cluster is created from it
+		restoreErr = waitUntilRDSClusterCreated(ctx, rdsClientSess, restoreConfig)
+	}
+
+	// Cluster may still be created from the copy, the next run deletes the cluster first
+	if isInterrupted(restoreErr) {
+		logf("Keeping snapshot [%v], RDS cluster [%v] may still be created from it - the next run or cleanup deletes it\n", aws.StringValue(copiedSnapshot.DBClusterSnapshotIdentifier), restoreConfig.RestoreRDS)
+		return restoreErr
+	}
+	return combineErrors([]error{restoreErr, deleteSnapshot(rdsClientSess, copiedSnapshot)})
+}
+
+// Copy a snapshot from sourceRegion into awsRegion, re-encrypted with rdsKmsKeyId,
+// and wait until the copy is available
+func copySnapshotToRegion(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshot *rds.DBClusterSnapshot) (*rds.DBClusterSnapshot, error) {
+	snapshotName := aws.StringValue(snapshot.DBClusterSnapshotIdentifier)
+	if status := aws.StringValue(snapshot.Status); status != "available" {
+		return nil, fmt.Errorf("Snapshot [%v] is [%v], not available", snapshotName, status)
+	}
+	if aws.BoolValue(snapshot.StorageEncrypted) && restoreConfig.KMSKeyID == "" {
+		return nil, fmt.Errorf("Snapshot [%v] is encrypted, rdsKmsKeyId of a key in [%v] is required to copy it", snapshotName, restoreConfig.AWSRegion)
+	}
+
+	copiedSnapshotName := copiedSnapshotIdentifier(restoreConfig)
+	input := &rds.CopyDBClusterSnapshotInput{
+		SourceDBClusterSnapshotIdentifier: snapshot.DBClusterSnapshotArn,  // Required - ARN for a cross-region copy
+		TargetDBClusterSnapshotIdentifier: aws.String(copiedSnapshotName), // Required
+		Tags:                              restoreSnapshotTags(restoreConfig),
+	}
+	if input.SourceDBClusterSnapshotIdentifier == nil {
+		input.SourceDBClusterSnapshotIdentifier = snapshot.DBClusterSnapshotIdentifier
+	}
+	// SDK presigns the copy request in the source region, a copy of a shared snapshot within the region needs none
+	if restoreConfig.crossRegion() {
+		input.SourceRegion = aws.String(restoreConfig.sourceRegion())
+	}
+	if restoreConfig.KMSKeyID != "" {
+		input.KmsKeyId = aws.String(restoreConfig.KMSKeyID)
+	}
+
+	logf("Copying snapshot [%v] from [%v] to [%v] as [%v]\n", snapshotName, restoreConfig.sourceRegion(), restoreConfig.AWSRegion, copiedSnapshotName)
+	_, copyErr := rdsClientSess.CopyDBClusterSnapshot(input)
+	if copyErr != nil {
+		return nil, fmt.Errorf("Error copying snapshot [%v] to [%v]: %v", snapshotName, restoreConfig.AWSRegion, copyErr)
+	}
+
+	return waitUntilSnapshotAvailable(ctx, rdsClientSess, restoreConfig, copiedSnapshotName)
+}
+
+func waitUntilSnapshotAvailable(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshotName string) (*rds.DBClusterSnapshot, error) {
+	var snapshot *rds.DBClusterSnapshot
+
+	logf("Wait until snapshot [%v] is available ...\n", snapshotName)
+	w := &waiter{
+		resource:      fmt.Sprintf("Snapshot [%v]", snapshotName),
+		successStates: []string{"available"},
+		failureStates: snapshotFailureStates,
+		timing:        restoreConfig.waitTiming(defaultSnapshotWaitTimeout),
+		poll: func() (string, error) {
+			resp, err := rdsClientSess.DescribeDBClusterSnapshots(&rds.DescribeDBClusterSnapshotsInput{
+				DBClusterSnapshotIdentifier: aws.String(snapshotName),
+			})
+			if err != nil {
+				return "", err
+			}
+			if len(resp.DBClusterSnapshots) == 0 {
+				return "", fmt.Errorf("Snapshot [%v] not found", snapshotName)
+			}
+			snapshot = resp.DBClusterSnapshots[0]
+			return aws.StringValue(snapshot.Status), nil
+		},
+	}
+	if waitErr := w.wait(ctx); waitErr != nil {
+		return nil, waitErr
+	}
+
+	logf("Snapshot [%v] copied successfully\n", snapshotName)
+	return snapshot, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestRestoreRDSClusterCrossRegion(t *testing.T) {
+	source := newFakeRDS()
+	source.addCluster("test-db", "available")
+	source.addClusterSnapshot("test-db", "rds:test-db-2021-08-21", time.Date(2021, 8, 21, 3, 0, 0, 0, time.UTC))
+	sourceSnapshot := source.snapshots["rds:test-db-2021-08-21"]
+	sourceSnapshot.SnapshotType = aws.String("automated")
+	sourceSnapshot.StorageEncrypted = aws.Bool(true)
+	sourceSnapshot.DBClusterSnapshotArn = aws.String("arn:aws:rds:us-east-1:123456789012:cluster-snapshot:rds:test-db-2021-08-21")
+
+	target := newFakeRDS()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.AWSRegion = "eu-west-1"
+	restoreConfig.SourceRegion = "us-east-1"
+	restoreConfig.KMSKeyID = "arn:aws:kms:eu-west-1:123456789012:key/dr-key"
+	restoreConfig.RestoreSource = sourceLatestAutomatedSnapshot
+
+	if err := restoreRDSCluster(context.Background(), &jobRDS{RDSAPI: target, source: source}, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	if calls := mutatingCalls(source); len(calls) != 0 {
+		t.Errorf("mutating calls sent to the source region: %v", calls)
+	}
+	want := []string{"CopyDBClusterSnapshot", "RestoreDBClusterFromSnapshot", "DeleteDBClusterSnapshot", "CreateDBInstance"}
+	if got := mutatingCalls(target); !reflect.DeepEqual(got, want) {
+		t.Fatalf("target calls = %v, want %v", got, want)
+	}
+
+	copyInput := target.copySnapshotInputs[0]
+	if got := aws.StringValue(copyInput.SourceDBClusterSnapshotIdentifier); got != aws.StringValue(sourceSnapshot.DBClusterSnapshotArn) {
+		t.Errorf("copied snapshot %v, want source snapshot ARN", got)
+	}
+	if aws.StringValue(copyInput.SourceRegion) != "us-east-1" || aws.StringValue(copyInput.KmsKeyId) != restoreConfig.KMSKeyID {
+		t.Errorf("copy SourceRegion = %v, KmsKeyId = %v", aws.StringValue(copyInput.SourceRegion), aws.StringValue(copyInput.KmsKeyId))
+	}
+	if got := aws.StringValue(target.restoreFromSnapshotInputs[0].SnapshotIdentifier); got != "test-db-restore-copy-test-run" {
+		t.Errorf("restored from %v, want the copied snapshot", got)
+	}
+	if len(target.snapshots) != 0 {
+		t.Errorf("copied snapshot was not cleaned up")
+	}
+}
+
+func TestCrossRegionEncryptedSnapshotNeedsKMSKey(t *testing.T) {
+	source := newFakeRDS()
+	source.addCluster("test-db", "available")
+	source.addClusterSnapshot("test-db", "test-db-golden", time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC))
+	source.snapshots["test-db-golden"].StorageEncrypted = aws.Bool(true)
+
+	target := newFakeRDS()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.AWSRegion = "eu-west-1"
+	restoreConfig.SourceRegion = "us-east-1"
+	restoreConfig.RestoreSource = sourceSnapshot
+	restoreConfig.SnapshotIdentifier = "test-db-golden"
+
+	if err := restoreRDSCluster(context.Background(), &jobRDS{RDSAPI: target, source: source}, restoreConfig); err == nil {
+		t.Fatalf("expected error copying an encrypted snapshot without rdsKmsKeyId")
+	}
+	if n := target.callCount("CopyDBClusterSnapshot"); n != 0 {
+		t.Errorf("CopyDBClusterSnapshot called %d times", n)
+	}
+}
+
+func TestCrossRegionProblems(t *testing.T) {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.SourceRegion = "eu-west-1"
+	if problems := restoreConfig.crossRegionProblems(); len(problems) != 1 {
+		t.Errorf("cross-region point-in-time: problems = %q, want 1", problems)
+	}
+
+	restoreConfig.SourceRegion = restoreConfig.AWSRegion
+	restoreConfig.KMSKeyID = "alias/dr"
+	if problems := restoreConfig.crossRegionProblems(); len(problems) != 1 {
+		t.Errorf("kms key without cross-region: problems = %q, want 1", problems)
+	}
+}
+
+func TestDryRunCrossRegionPlansCopy(t *testing.T) {
+	source := newFakeRDS()
+	source.addCluster("test-db", "available")
+	source.addClusterSnapshot("test-db", "test-db-golden", time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC))
+
+	target := newFakeRDS()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.AWSRegion = "eu-west-1"
+	restoreConfig.SourceRegion = "us-east-1"
+	restoreConfig.RestoreSource = sourceSnapshot
+	restoreConfig.SnapshotIdentifier = "test-db-golden"
+
+	dryRunClient := newJobDryRunRDS(&jobRDS{RDSAPI: target, source: source})
+	if err := runRestore(context.Background(), dryRunClient, restoreConfig); err != nil {
+		t.Fatalf("dry-run restore: %v", err)
+	}
+
+	if calls := append(mutatingCalls(source), mutatingCalls(target)...); len(calls) != 0 {
+		t.Errorf("dry-run sent mutating calls %v", calls)
+	}
+	var actions []string
+	for _, action := range dryRunClient.actions {
+		actions = append(actions, action.Action)
+	}
+	want := []string{"CopyDBClusterSnapshot", "RestoreDBClusterFromSnapshot", "DeleteDBClusterSnapshot", "CreateDBInstance"}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("planned actions = %v, want %v", actions, want)
+	}
+}
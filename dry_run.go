@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Output formats of a dry-run plan
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// API call that a dry-run would have sent
+type plannedAction struct {
+	Action string      `json:"action"`
+	Input  interface{} `json:"input"`
+}
+
+// Plan of a single job
+type jobPlan struct {
+	Job     string          `json:"job"`
+	Actions []plannedAction `json:"actions"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// RDS client for dry-runs - Describe calls go to AWS for real, every mutating
+// call is recorded instead of sent and its effect is simulated on top of the
+// real state, so that the exists checks and waitUntil* loops behave as they would.
+// NOTE: every mutating call the restore flow uses must be overridden here,
+// anything not overridden goes straight to the embedded client.
+type dryRunRDS struct {
+	rdsiface.RDSAPI
+
+	mu      sync.Mutex
+	actions []plannedAction
+
+	// Dry-run client of sourceRDS when it is reached with a separate client,
+	// it records its calls into the plan of its parent
+	source *dryRunRDS
+	parent *dryRunRDS
+
+	// Simulated state - resources created by the plan and resources deleted by it,
+	// deleted ones show as "deleting" once before they disappear
+	createdClusters  map[string]*rds.DBCluster
+	createdInstances map[string]*rds.DBInstance
+	deletedClusters  map[string]bool
+	deletedInstances map[string]bool
+	createdSnapshots map[string]*rds.DBClusterSnapshot
+}
+
+func newDryRunRDS(rdsClientSess rdsiface.RDSAPI) *dryRunRDS {
+	return &dryRunRDS{
+		RDSAPI:           rdsClientSess,
+		createdClusters:  map[string]*rds.DBCluster{},
+		createdInstances: map[string]*rds.DBInstance{},
+		deletedClusters:  map[string]bool{},
+		deletedInstances: map[string]bool{},
+		createdSnapshots: map[string]*rds.DBClusterSnapshot{},
+	}
+}
+
+// Dry-run client recording into this plan for a job whose source is reached
+// with a separate client
+func newJobDryRunRDS(rdsClientSess rdsiface.RDSAPI) *dryRunRDS {
+	d := newDryRunRDS(rdsClientSess)
+	if sourceAware, ok := rdsClientSess.(sourceAwareRDS); ok {
+		d.source = newDryRunRDS(sourceAware.sourceRDSClient())
+		d.source.parent = d
+	}
+	return d
+}
+
+func (d *dryRunRDS) sourceRDSClient() rdsiface.RDSAPI {
+	if d.source != nil {
+		return d.source
+	}
+	return d
+}
+
+func (d *dryRunRDS) plan(action string, input interface{}) {
+	if d.parent != nil {
+		d.parent.mu.Lock()
+		defer d.parent.mu.Unlock()
+		d.parent.plan(action, input)
+		return
+	}
+	logf("[dry-run] Skipping %v\n", action)
+	d.actions = append(d.actions, plannedAction{Action: action, Input: input})
+}
+
+func (d *dryRunRDS) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := aws.StringValue(input.DBClusterIdentifier)
+	if cluster, ok := d.createdClusters[name]; ok {
+		return &rds.DescribeDBClustersOutput{DBClusters: []*rds.DBCluster{cluster}}, nil
+	}
+	if deleting, ok := d.deletedClusters[name]; ok {
+		if deleting {
+			d.deletedClusters[name] = false
+			return &rds.DescribeDBClustersOutput{DBClusters: []*rds.DBCluster{
+				{DBClusterIdentifier: aws.String(name), Status: aws.String("deleting")},
+			}}, nil
+		}
+		return nil, awserr.New(rds.ErrCodeDBClusterNotFoundFault, fmt.Sprintf("DBCluster %v not found (dry-run).", name), nil)
+	}
+
+	resp, err := d.RDSAPI.DescribeDBClusters(input)
+	if err != nil || name == "" {
+		return resp, err
+	}
+	// A cluster a previous run left deleting or creating settles after this
+	// describe, so the plan doesn't wait for it
+	for _, cluster := range resp.DBClusters {
+		switch aws.StringValue(cluster.Status) {
+		case "deleting":
+			d.deletedClusters[name] = false
+		case "creating":
+			settled := *cluster
+			settled.Status = aws.String("available")
+			d.createdClusters[name] = &settled
+		}
+	}
+	return resp, nil
+}
+
+func (d *dryRunRDS) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := aws.StringValue(input.DBInstanceIdentifier)
+	if instance, ok := d.createdInstances[name]; ok {
+		return &rds.DescribeDBInstancesOutput{DBInstances: []*rds.DBInstance{instance}}, nil
+	}
+	if deleting, ok := d.deletedInstances[name]; ok {
+		if deleting {
+			d.deletedInstances[name] = false
+			return &rds.DescribeDBInstancesOutput{DBInstances: []*rds.DBInstance{
+				{DBInstanceIdentifier: aws.String(name), DBInstanceStatus: aws.String("deleting")},
+			}}, nil
+		}
+		return nil, awserr.New(rds.ErrCodeDBInstanceNotFoundFault, fmt.Sprintf("DBInstance %v not found (dry-run).", name), nil)
+	}
+
+	resp, err := d.RDSAPI.DescribeDBInstances(input)
+	if err != nil || name == "" {
+		return resp, err
+	}
+	for _, instance := range resp.DBInstances {
+		switch aws.StringValue(instance.DBInstanceStatus) {
+		case "deleting":
+			d.deletedInstances[name] = false
+		case "creating":
+			settled := *instance
+			settled.DBInstanceStatus = aws.String("available")
+			d.createdInstances[name] = &settled
+		}
+	}
+	return resp, nil
+}
+
+func (d *dryRunRDS) RestoreDBClusterToPointInTime(input *rds.RestoreDBClusterToPointInTimeInput) (*rds.RestoreDBClusterToPointInTimeOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("RestoreDBClusterToPointInTime", input)
+
+	cluster := &rds.DBCluster{
+		DBClusterIdentifier: input.DBClusterIdentifier,
+		EngineMode:          input.EngineMode,
+		Status:              aws.String("available"),
+		TagList:             input.Tags,
+	}
+	d.createdClusters[aws.StringValue(input.DBClusterIdentifier)] = cluster
+	return &rds.RestoreDBClusterToPointInTimeOutput{DBCluster: cluster}, nil
+}
+
+func (d *dryRunRDS) RestoreDBClusterFromSnapshot(input *rds.RestoreDBClusterFromSnapshotInput) (*rds.RestoreDBClusterFromSnapshotOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("RestoreDBClusterFromSnapshot", input)
+
+	cluster := &rds.DBCluster{
+		DBClusterIdentifier: input.DBClusterIdentifier,
+		EngineMode:          input.EngineMode,
+		Status:              aws.String("available"),
+		TagList:             input.Tags,
+	}
+	d.createdClusters[aws.StringValue(input.DBClusterIdentifier)] = cluster
+	return &rds.RestoreDBClusterFromSnapshotOutput{DBCluster: cluster}, nil
+}
+
+func (d *dryRunRDS) CreateDBInstance(input *rds.CreateDBInstanceInput) (*rds.CreateDBInstanceOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("CreateDBInstance", input)
+
+	instance := &rds.DBInstance{
+		DBInstanceIdentifier: input.DBInstanceIdentifier,
+		DBClusterIdentifier:  input.DBClusterIdentifier,
+		DBInstanceClass:      input.DBInstanceClass,
+		DBInstanceStatus:     aws.String("available"),
+	}
+	d.createdInstances[aws.StringValue(input.DBInstanceIdentifier)] = instance
+	return &rds.CreateDBInstanceOutput{DBInstance: instance}, nil
+}
+
+func (d *dryRunRDS) RestoreDBInstanceToPointInTime(input *rds.RestoreDBInstanceToPointInTimeInput) (*rds.RestoreDBInstanceToPointInTimeOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("RestoreDBInstanceToPointInTime", input)
+
+	instance := &rds.DBInstance{
+		DBInstanceIdentifier: input.TargetDBInstanceIdentifier,
+		DBInstanceClass:      input.DBInstanceClass,
+		DBInstanceStatus:     aws.String("available"),
+		TagList:              input.Tags,
+	}
+	d.createdInstances[aws.StringValue(input.TargetDBInstanceIdentifier)] = instance
+	return &rds.RestoreDBInstanceToPointInTimeOutput{DBInstance: instance}, nil
+}
+
+func (d *dryRunRDS) RestoreDBInstanceFromDBSnapshot(input *rds.RestoreDBInstanceFromDBSnapshotInput) (*rds.RestoreDBInstanceFromDBSnapshotOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("RestoreDBInstanceFromDBSnapshot", input)
+
+	instance := &rds.DBInstance{
+		DBInstanceIdentifier: input.DBInstanceIdentifier,
+		DBInstanceClass:      input.DBInstanceClass,
+		DBInstanceStatus:     aws.String("available"),
+		TagList:              input.Tags,
+	}
+	d.createdInstances[aws.StringValue(input.DBInstanceIdentifier)] = instance
+	return &rds.RestoreDBInstanceFromDBSnapshotOutput{DBInstance: instance}, nil
+}
+
+func (d *dryRunRDS) DeleteDBInstance(input *rds.DeleteDBInstanceInput) (*rds.DeleteDBInstanceOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("DeleteDBInstance", input)
+
+	d.deletedInstances[aws.StringValue(input.DBInstanceIdentifier)] = true
+	return &rds.DeleteDBInstanceOutput{}, nil
+}
+
+func (d *dryRunRDS) DeleteDBCluster(input *rds.DeleteDBClusterInput) (*rds.DeleteDBClusterOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("DeleteDBCluster", input)
+
+	d.deletedClusters[aws.StringValue(input.DBClusterIdentifier)] = true
+	return &rds.DeleteDBClusterOutput{}, nil
+}
+
+func (d *dryRunRDS) DescribeDBClusterSnapshots(input *rds.DescribeDBClusterSnapshotsInput) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if snapshot, ok := d.createdSnapshots[aws.StringValue(input.DBClusterSnapshotIdentifier)]; ok {
+		return &rds.DescribeDBClusterSnapshotsOutput{DBClusterSnapshots: []*rds.DBClusterSnapshot{snapshot}}, nil
+	}
+	return d.RDSAPI.DescribeDBClusterSnapshots(input)
+}
+
+func (d *dryRunRDS) CopyDBClusterSnapshot(input *rds.CopyDBClusterSnapshotInput) (*rds.CopyDBClusterSnapshotOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("CopyDBClusterSnapshot", input)
+
+	snapshot := &rds.DBClusterSnapshot{
+		DBClusterSnapshotIdentifier: input.TargetDBClusterSnapshotIdentifier,
+		SnapshotType:                aws.String("manual"),
+		Status:                      aws.String("available"),
+		PercentProgress:             aws.Int64(100),
+		TagList:                     input.Tags,
+	}
+	d.createdSnapshots[aws.StringValue(input.TargetDBClusterSnapshotIdentifier)] = snapshot
+	return &rds.CopyDBClusterSnapshotOutput{DBClusterSnapshot: snapshot}, nil
+}
+
+func (d *dryRunRDS) CreateDBClusterSnapshot(input *rds.CreateDBClusterSnapshotInput) (*rds.CreateDBClusterSnapshotOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("CreateDBClusterSnapshot", input)
+
+	snapshot := &rds.DBClusterSnapshot{
+		DBClusterSnapshotIdentifier: input.DBClusterSnapshotIdentifier,
+		DBClusterIdentifier:         input.DBClusterIdentifier,
+		SnapshotType:                aws.String("manual"),
+		Status:                      aws.String("available"),
+		PercentProgress:             aws.Int64(100),
+		TagList:                     input.Tags,
+	}
+	d.createdSnapshots[aws.StringValue(input.DBClusterSnapshotIdentifier)] = snapshot
+	return &rds.CreateDBClusterSnapshotOutput{DBClusterSnapshot: snapshot}, nil
+}
+
+func (d *dryRunRDS) ModifyDBClusterSnapshotAttribute(input *rds.ModifyDBClusterSnapshotAttributeInput) (*rds.ModifyDBClusterSnapshotAttributeOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("ModifyDBClusterSnapshotAttribute", input)
+
+	return &rds.ModifyDBClusterSnapshotAttributeOutput{}, nil
+}
+
+func (d *dryRunRDS) DeleteDBClusterSnapshot(input *rds.DeleteDBClusterSnapshotInput) (*rds.DeleteDBClusterSnapshotOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("DeleteDBClusterSnapshot", input)
+
+	return &rds.DeleteDBClusterSnapshotOutput{}, nil
+}
+
+func (d *dryRunRDS) CopyDBClusterParameterGroup(input *rds.CopyDBClusterParameterGroupInput) (*rds.CopyDBClusterParameterGroupOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("CopyDBClusterParameterGroup", input)
+
+	return &rds.CopyDBClusterParameterGroupOutput{}, nil
+}
+
+func (d *dryRunRDS) CopyDBParameterGroup(input *rds.CopyDBParameterGroupInput) (*rds.CopyDBParameterGroupOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("CopyDBParameterGroup", input)
+
+	return &rds.CopyDBParameterGroupOutput{}, nil
+}
+
+func (d *dryRunRDS) ModifyDBClusterParameterGroup(input *rds.ModifyDBClusterParameterGroupInput) (*rds.DBClusterParameterGroupNameMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("ModifyDBClusterParameterGroup", input)
+
+	return &rds.DBClusterParameterGroupNameMessage{DBClusterParameterGroupName: input.DBClusterParameterGroupName}, nil
+}
+
+func (d *dryRunRDS) ModifyDBParameterGroup(input *rds.ModifyDBParameterGroupInput) (*rds.DBParameterGroupNameMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("ModifyDBParameterGroup", input)
+
+	return &rds.DBParameterGroupNameMessage{DBParameterGroupName: input.DBParameterGroupName}, nil
+}
+
+func (d *dryRunRDS) DeleteDBClusterParameterGroup(input *rds.DeleteDBClusterParameterGroupInput) (*rds.DeleteDBClusterParameterGroupOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("DeleteDBClusterParameterGroup", input)
+
+	return &rds.DeleteDBClusterParameterGroupOutput{}, nil
+}
+
+func (d *dryRunRDS) DeleteDBParameterGroup(input *rds.DeleteDBParameterGroupInput) (*rds.DeleteDBParameterGroupOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("DeleteDBParameterGroup", input)
+
+	return &rds.DeleteDBParameterGroupOutput{}, nil
+}
+
+func (d *dryRunRDS) RebootDBInstance(input *rds.RebootDBInstanceInput) (*rds.RebootDBInstanceOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plan("RebootDBInstance", input)
+
+	return &rds.RebootDBInstanceOutput{}, nil
+}
+
+// Write plans of every job in the requested format
+func renderPlans(w io.Writer, plans []jobPlan, format string) error {
+	if format == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plans)
+	}
+
+	for _, plan := range plans {
+		fmt.Fprintf(w, "\nPlan for job [%v] - %d action(s):\n", plan.Job, len(plan.Actions))
+		for i, action := range plan.Actions {
+			fmt.Fprintf(w, "  %d. %v\n", i+1, action.Action)
+			fmt.Fprintf(w, "     %v\n", strings.ReplaceAll(fmt.Sprint(action.Input), "\n", "\n     "))
+		}
+		if plan.Error != "" {
+			fmt.Fprintf(w, "  plan incomplete, stopped on error: %v\n", plan.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func TestDryRunRestoreRecordsPlan(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+	dryRunClient := newDryRunRDS(fake)
+	if err := runRestore(context.Background(), dryRunClient, testRestoreConfig()); err != nil {
+		t.Fatalf("dry-run restore: %v", err)
+	}
+
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("dry-run sent mutating calls %v", calls)
+	}
+
+	var actions []string
+	for _, action := range dryRunClient.actions {
+		actions = append(actions, action.Action)
+	}
+	want := []string{"DeleteDBInstance", "DeleteDBCluster", "RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("planned actions = %v, want %v", actions, want)
+	}
+}
+
+func TestDryRunFreshTargetSkipsDeletes(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	dryRunClient := newDryRunRDS(fake)
+	if err := runRestore(context.Background(), dryRunClient, testRestoreConfig()); err != nil {
+		t.Fatalf("dry-run restore: %v", err)
+	}
+	if len(dryRunClient.actions) != 2 {
+		t.Errorf("got %d planned actions, want restore and create only", len(dryRunClient.actions))
+	}
+}
+
+func TestDryRunDoesNotWaitForDeleteInProgress(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.clusters["test-db-restore"].cluster.Status = aws.String("deleting")
+	fake.clusters["test-db-restore"].pendingPolls = 1000
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.WaitTimeout = 50 * time.Millisecond
+	restoreConfig.WaitPollInterval = time.Millisecond
+
+	dryRunClient := newDryRunRDS(fake)
+	if err := runRestore(context.Background(), dryRunClient, restoreConfig); err != nil {
+		t.Fatalf("dry-run restore: %v", err)
+	}
+	var actions []string
+	for _, action := range dryRunClient.actions {
+		actions = append(actions, action.Action)
+	}
+	want := []string{"RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("planned actions = %v, want %v", actions, want)
+	}
+}
+
+func TestRenderPlansJSON(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	dryRunClient := newDryRunRDS(fake)
+	if err := runRestore(context.Background(), dryRunClient, testRestoreConfig()); err != nil {
+		t.Fatalf("dry-run restore: %v", err)
+	}
+
+	var out bytes.Buffer
+	plans := []jobPlan{{Job: "test-db-restore", Actions: dryRunClient.actions}}
+	if err := renderPlans(&out, plans, outputJSON); err != nil {
+		t.Fatalf("renderPlans: %v", err)
+	}
+
+	var decoded []struct {
+		Job     string
+		Actions []struct {
+			Action string
+			Input  map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("plan is not valid JSON: %v\n%v", err, out.String())
+	}
+	restoreInput := decoded[0].Actions[0].Input
+	if restoreInput["SourceDBClusterIdentifier"] != "test-db" || restoreInput["DBClusterIdentifier"] != "test-db-restore" {
+		t.Errorf("unexpected restore input in plan: %v", restoreInput)
+	}
+
+	out.Reset()
+	if err := renderPlans(&out, plans, outputText); err != nil {
+		t.Fatalf("renderPlans: %v", err)
+	}
+	if !strings.Contains(out.String(), "1. RestoreDBClusterToPointInTime") {
+		t.Errorf("text plan missing restore action:\n%v", out.String())
+	}
+}
+
+func TestRunCommandWritesJSONPlanOnlyToPlanOutput(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	var out bytes.Buffer
+	if exitCode := runCommand(context.Background(), []string{"plan", "--output", "json"}, testEnv(nil), fakeClients(fake), &out); exitCode != 0 {
+		t.Fatalf("exit code = %d, want 0", exitCode)
+	}
+
+	var decoded []jobPlan
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("plan output is not only the JSON plan: %v\n%v", err, out.String())
+	}
+	if len(decoded) != 1 || len(decoded[0].Actions) != 2 || decoded[0].Actions[0].Action != "RestoreDBClusterToPointInTime" {
+		t.Errorf("unexpected plan: %+v", decoded)
+	}
+	if logOutput != os.Stdout {
+		t.Errorf("logs not back on stdout after the JSON plan")
+	}
+}
+
+func TestDryRunPlansParameterOverridesAndReboot(t *testing.T) {
+	fake := fakeWithQAParameterGroups()
+
+	restoreConfig := qaParameterRestoreConfig()
+	restoreConfig.Instances = restoreConfig.Instances[:1]
+	restoreConfig.ClusterParameterOverrides = map[string]string{"binlog_format": "OFF"}
+
+	dryRunClient := newDryRunRDS(fake)
+	if err := runRestore(context.Background(), dryRunClient, restoreConfig); err != nil {
+		t.Fatalf("dry-run restore: %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("dry-run sent mutating calls %v", calls)
+	}
+
+	var actions []string
+	for _, action := range dryRunClient.actions {
+		actions = append(actions, action.Action)
+	}
+	want := []string{"RestoreDBClusterToPointInTime", "CreateDBInstance", "ModifyDBClusterParameterGroup", "RebootDBInstance"}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("planned actions = %v, want %v", actions, want)
+	}
+}
+
+// Every command and restore strategy under --dry-run against a fake holding a
+// previous restore, snapshots, parameter groups and a standalone instance
+func TestDryRunSendsNoMutatingCalls(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		setup func(fake *fakeRDS)
+	}{
+		{name: "restore", args: []string{"restore", "--dry-run"}},
+		{name: "plan", args: []string{"plan"}},
+		{name: "cleanup with final snapshot", args: []string{"cleanup", "--dry-run"}, env: map[string]string{
+			"finalSnapshotIdentifier": "{restoreRDS}-final-{runId}", "finalSnapshotRetention": "1",
+		}},
+		{name: "restore with final snapshot", args: []string{"restore", "--dry-run"}, env: map[string]string{
+			"finalSnapshotIdentifier": "{restoreRDS}-final-{runId}", "finalSnapshotRetention": "1",
+		}},
+		{name: "status", args: []string{"status", "--dry-run"}},
+		{name: "list-restore-points", args: []string{"list-restore-points", "--dry-run"}},
+		{name: "named snapshot", args: []string{"plan"}, env: map[string]string{
+			"restoreSource": sourceSnapshot, "restoreSnapshotIdentifier": "test-db-golden",
+		}},
+		{name: "latest automated snapshot", args: []string{"plan"}, env: map[string]string{"restoreSource": sourceLatestAutomatedSnapshot}},
+		{name: "latest tagged snapshot", args: []string{"plan"}, env: map[string]string{
+			"restoreSource": sourceLatestTaggedSnapshot, "restoreSnapshotTag": "purpose=golden",
+		}},
+		{name: "new snapshot", args: []string{"plan"}, env: map[string]string{"restoreSource": sourceNewSnapshot}},
+		{name: "copy-on-write clone", args: []string{"plan"}, env: map[string]string{"restoreType": restoreTypeCopyOnWrite}},
+		{name: "cross-region", args: []string{"plan"}, env: map[string]string{
+			"restoreSource": sourceLatestAutomatedSnapshot, "sourceRegion": "eu-west-1", "rdsKmsKeyId": "arn:aws:kms:us-east-1:123456789012:key/restore",
+		}},
+		{name: "cross-account", args: []string{"plan"}, env: map[string]string{
+			"restoreSource": sourceLatestAutomatedSnapshot, "rdsKmsKeyId": "arn:aws:kms:us-east-1:123456789012:key/restore",
+			"sourceRoleArn": "arn:aws:iam::111111111111:role/rds-restore-source", "targetRoleArn": "arn:aws:iam::123456789012:role/rds-restore-target",
+		}},
+		{name: "copied parameter groups with overrides", args: []string{"plan"}, env: map[string]string{
+			"copySourceParameterGroups": "true", "rdsClusterParameterOverrides": "binlog_format=OFF", "rdsParameterOverrides": "general_log=1",
+		}},
+		{name: "parameter groups with overrides", args: []string{"plan"}, env: map[string]string{
+			"rdsClusterParameterGroup": "qa-cluster", "rdsClusterParameterOverrides": "binlog_format=OFF",
+			"rdsParameterGroup": "qa-instance", "rdsParameterOverrides": "performance_schema=1",
+		}},
+		{name: "serverless-v2", args: []string{"plan"}, env: map[string]string{"rdsCapacityMode": capacityServerlessV2}},
+		{name: "serverless-v1", args: []string{"plan"}, env: map[string]string{"rdsCapacityMode": capacityServerlessV1}, setup: func(fake *fakeRDS) {
+			fake.clusters["test-db"].cluster.EngineVersion = aws.String("5.7.mysql_aurora.2.07.2")
+		}},
+		{name: "mirrored topology", args: []string{"plan"}, env: map[string]string{"mirrorSourceTopology": "true"}},
+		{name: "standalone instance", args: []string{"plan"}, env: map[string]string{"sourceRDS": "test-db-pg", "restoreRDS": "test-db-pg-restore"}},
+		{name: "standalone instance cleanup", args: []string{"cleanup", "--dry-run"}, env: map[string]string{
+			"sourceRDS": "test-db-pg", "restoreRDS": "test-db-pg-restore", "finalSnapshotIdentifier": "{restoreRDS}-final-{runId}",
+		}},
+	}
+
+	for _, test := range tests {
+		fake := fakeWithSourceParameterGroups()
+		fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+		fake.addClusterSnapshot("test-db", "rds:test-db-2021-08-21", time.Date(2021, 8, 21, 3, 0, 0, 0, time.UTC))
+		fake.snapshots["rds:test-db-2021-08-21"].SnapshotType = aws.String("automated")
+		fake.addClusterSnapshot("test-db", "test-db-golden", time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC))
+		fake.snapshots["test-db-golden"].TagList = []*rds.Tag{{Key: aws.String("purpose"), Value: aws.String("golden")}}
+		fake.clusterParameterGroups["qa-cluster"] = fakeClusterParameterGroup("qa-cluster", "aurora-mysql5.7")
+		fake.clusterGroupParameters["qa-cluster"] = []*rds.Parameter{fakeParameter("binlog_format", "ROW", applyTypeStatic)}
+		fake.parameterGroups["qa-instance"] = fakeParameterGroup("qa-instance", "aurora-mysql5.7")
+		fake.groupParameters["qa-instance"] = []*rds.Parameter{fakeParameter("performance_schema", "0", applyTypeStatic)}
+		fake.addStandaloneInstance("test-db-pg", "available")
+		fake.addRestoredStandaloneInstance("test-db-pg-restore", "test-db-pg")
+		if test.setup != nil {
+			test.setup(fake)
+		}
+
+		if exitCode := runCommand(context.Background(), test.args, testEnv(test.env), fakeClients(fake), io.Discard); exitCode != 0 {
+			t.Errorf("%v: exit code = %d, want 0", test.name, exitCode)
+		}
+		if calls := mutatingCalls(fake); len(calls) != 0 {
+			t.Errorf("%v: dry-run sent mutating calls %v", test.name, calls)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Default rdsEngine and rdsEngineVersion to the engine and version of sourceRDS
+// and look up the parameter group family restoreRDS is created with
+func resolveEngine(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	sourceCluster, describeErr := describeRDSCluster(sourceClient(rdsClientSess), restoreConfig.SourceRDS)
+	if describeErr != nil {
+		return describeErr
+	}
+
+	if sourceCluster == nil {
+		// Snapshots outlive their source, the engine can't be detected then
+		if restoreConfig.Engine == "" {
+			logf("Source RDS cluster [%v] not found, using default engine %v\n", restoreConfig.SourceRDS, defaultEngine)
+			restoreConfig.Engine = defaultEngine
+		}
+	} else {
+		sourceEngine := RDSEngine(aws.StringValue(sourceCluster.Engine))
+		sourceEngineVersion := aws.StringValue(sourceCluster.EngineVersion)
+
+		// A point-in-time restore or clone always has the engine and version of its source
+		if restoreConfig.RestoreSource == sourcePointInTime {
+			if restoreConfig.Engine != "" && restoreConfig.Engine != sourceEngine {
+				return fmt.Errorf("rdsEngine [%v] doesn't match engine [%v] of sourceRDS, point-in-time restores keep the source engine", restoreConfig.Engine, sourceEngine)
+			}
+			if restoreConfig.EngineVersion != "" && restoreConfig.EngineVersion != sourceEngineVersion {
+				return fmt.Errorf("rdsEngineVersion [%v] doesn't match version [%v] of sourceRDS, point-in-time restores keep the source version", restoreConfig.EngineVersion, sourceEngineVersion)
+			}
+		}
+
+		if restoreConfig.Engine == "" {
+			restoreConfig.Engine = sourceEngine
+		}
+		if restoreConfig.EngineVersion == "" && restoreConfig.Engine == sourceEngine {
+			restoreConfig.EngineVersion = sourceEngineVersion
+		}
+	}
+
+	if !restoreConfig.Engine.supported() {
+		return fmt.Errorf("Engine [%v] of sourceRDS is not supported, expected one of %v", restoreConfig.Engine, supportedEngines)
+	}
+
+	engineVersion, describeErr := describeEngineVersion(rdsClientSess, restoreConfig)
+	if describeErr != nil {
+		return describeErr
+	}
+	serverlessErr := checkServerlessEngineMode(restoreConfig, engineVersion)
+	if serverlessErr != nil {
+		return serverlessErr
+	}
+	restoreConfig.ParameterGroupFamily = aws.StringValue(engineVersion.DBParameterGroupFamily)
+
+	logf("Restoring with engine [%v %v], parameter group family [%v]\n", restoreConfig.Engine, restoreConfig.EngineVersion, restoreConfig.ParameterGroupFamily)
+	return nil
+}
+
+// Engine version restoreRDS is created with - the default version of the
+// engine when no version is set
+func describeEngineVersion(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (*rds.DBEngineVersion, error) {
+	input := &rds.DescribeDBEngineVersionsInput{
+		Engine: aws.String(string(restoreConfig.Engine)),
+	}
+	if restoreConfig.EngineVersion != "" {
+		input.EngineVersion = aws.String(restoreConfig.EngineVersion)
+	} else {
+		input.DefaultOnly = aws.Bool(true)
+	}
+
+	resp, err := rdsClientSess.DescribeDBEngineVersions(input)
+	if err != nil {
+		return nil, fmt.Errorf("Describe engine version [%v %v] err: %v", restoreConfig.Engine, restoreConfig.EngineVersion, err)
+	}
+	if len(resp.DBEngineVersions) == 0 {
+		return nil, fmt.Errorf("Engine version [%v %v] is not available in [%v]", restoreConfig.Engine, restoreConfig.EngineVersion, restoreConfig.AWSRegion)
+	}
+	return resp.DBEngineVersions[0], nil
+}
+
+// Check that every instance class can be ordered for the engine and version,
+// before anything is deleted
+func checkOrderableInstanceClasses(rdsClientSess rdsiface.RDSAPI, engine string, engineVersion string, instanceClasses []string) error {
+	checked := map[string]bool{}
+	var unorderable []string
+
+	for _, instanceClass := range instanceClasses {
+		if checked[instanceClass] {
+			continue
+		}
+		checked[instanceClass] = true
+
+		input := &rds.DescribeOrderableDBInstanceOptionsInput{
+			Engine:          aws.String(engine),
+			DBInstanceClass: aws.String(instanceClass),
+		}
+		if engineVersion != "" {
+			input.EngineVersion = aws.String(engineVersion)
+		}
+
+		resp, err := rdsClientSess.DescribeOrderableDBInstanceOptions(input)
+		if err != nil {
+			return fmt.Errorf("Describe orderable options of [%v] err: %v", instanceClass, err)
+		}
+		if len(resp.OrderableDBInstanceOptions) == 0 {
+			unorderable = append(unorderable, instanceClass)
+		}
+	}
+
+	if len(unorderable) > 0 {
+		return fmt.Errorf("Instance class %v can't be ordered for engine [%v %v]", unorderable, engine, engineVersion)
+	}
+	return nil
+}
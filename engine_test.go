@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Aurora PostgreSQL source cluster with a single writer
+func fakeWithPostgreSQLSource() *fakeRDS {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.clusters["test-db"].cluster.Engine = aws.String("aurora-postgresql")
+	fake.clusters["test-db"].cluster.EngineVersion = aws.String("13.4")
+	return fake
+}
+
+func TestRestoreRDSClusterDetectsSourceEngine(t *testing.T) {
+	fake := fakeWithPostgreSQLSource()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Engine = ""
+	restoreConfig.InstanceType = "db.r5.large"
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	if restoreConfig.Engine != EngineAuroraPostgreSQL || restoreConfig.EngineVersion != "13.4" {
+		t.Errorf("engine = %v %v, want aurora-postgresql 13.4", restoreConfig.Engine, restoreConfig.EngineVersion)
+	}
+	if restoreConfig.ParameterGroupFamily != "aurora-postgresql13" {
+		t.Errorf("ParameterGroupFamily = %v, want aurora-postgresql13", restoreConfig.ParameterGroupFamily)
+	}
+	if got := aws.StringValue(fake.createInstanceInputs[0].Engine); got != "aurora-postgresql" {
+		t.Errorf("instance engine = %v, want aurora-postgresql", got)
+	}
+}
+
+func TestRestoreRDSClusterRefusesUnorderableClass(t *testing.T) {
+	fake := fakeWithPostgreSQLSource()
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Engine = ""
+	err := restoreRDSCluster(context.Background(), fake, restoreConfig)
+	if err == nil || !strings.Contains(err.Error(), "[db.t3.small] can't be ordered for engine [aurora-postgresql 13.4]") {
+		t.Fatalf("expected unorderable class error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("unorderable class sent mutating calls: %v", calls)
+	}
+}
+
+func TestRestoreRDSClusterPointInTimeEngineMismatch(t *testing.T) {
+	fake := fakeWithPostgreSQLSource()
+
+	err := restoreRDSCluster(context.Background(), fake, testRestoreConfig())
+	if err == nil || !strings.Contains(err.Error(), "point-in-time restores keep the source engine") {
+		t.Fatalf("expected engine mismatch error, got %v", err)
+	}
+}
+
+func TestRestoreRDSClusterSnapshotEngineUpgrade(t *testing.T) {
+	fake := fakeWithSourceSnapshots()
+	fake.snapshots["test-db-adhoc"].EngineVersion = aws.String("5.7.mysql_aurora.2.10.0")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RestoreSource = sourceSnapshot
+	restoreConfig.SnapshotIdentifier = "test-db-adhoc"
+	restoreConfig.EngineVersion = "8.0.mysql_aurora.3.01.0"
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	if got := aws.StringValue(fake.restoreFromSnapshotInputs[0].EngineVersion); got != "8.0.mysql_aurora.3.01.0" {
+		t.Errorf("EngineVersion = %v, want 8.0.mysql_aurora.3.01.0", got)
+	}
+	if restoreConfig.ParameterGroupFamily != "aurora-mysql8.0" {
+		t.Errorf("ParameterGroupFamily = %v, want aurora-mysql8.0", restoreConfig.ParameterGroupFamily)
+	}
+}
+
+func TestResolveEngineDefaultsWithoutSource(t *testing.T) {
+	fake := newFakeRDS()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Engine = ""
+	restoreConfig.RestoreSource = sourceSnapshot
+	if err := resolveEngine(fake, restoreConfig); err != nil {
+		t.Fatalf("resolveEngine: %v", err)
+	}
+	if restoreConfig.Engine != defaultEngine || restoreConfig.EngineVersion != "" {
+		t.Errorf("engine = %v %v, want %v and the default version", restoreConfig.Engine, restoreConfig.EngineVersion, defaultEngine)
+	}
+	if restoreConfig.ParameterGroupFamily != "aurora-mysql5.7" {
+		t.Errorf("ParameterGroupFamily = %v, want aurora-mysql5.7", restoreConfig.ParameterGroupFamily)
+	}
+}
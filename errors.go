@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors of steps that ran concurrently
+type multiError []error
+
+func (e multiError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Lets errors.As find e.g. a SafetyError in any of the combined errors
+func (e multiError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lets errors.Is find e.g. context.Canceled in any of the combined errors
+func (e multiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Combine non-nil errors, nil if there are none
+func combineErrors(errs []error) error {
+	var combined multiError
+	for _, err := range errs {
+		if err != nil {
+			combined = append(combined, err)
+		}
+	}
+	if len(combined) == 0 {
+		return nil
+	}
+	return combined
+}
@@ -0,0 +1,879 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// In-memory RDS used by the tests - models cluster and instance lifecycle
+// states, every Describe call moves pending resources one poll closer to
+// their next state (creating -> available, deleting -> gone)
+type fakeRDS struct {
+	// Embedded so that calling an API the fake doesn't model panics loudly
+	rdsiface.RDSAPI
+
+	mu        sync.Mutex
+	clusters  map[string]*fakeCluster
+	instances map[string]*fakeInstance
+	snapshots map[string]*rds.DBClusterSnapshot
+
+	// Snapshots of standalone instances
+	instanceSnapshots map[string]*rds.DBSnapshot
+
+	// Parameter groups by name and tags of the groups by ARN
+	clusterParameterGroups map[string]*rds.DBClusterParameterGroup
+	parameterGroups        map[string]*rds.DBParameterGroup
+	resourceTags           map[string][]*rds.Tag
+
+	// Parameters of the cluster and instance parameter groups by group name
+	clusterGroupParameters map[string][]*rds.Parameter
+	groupParameters        map[string][]*rds.Parameter
+
+	// Engine versions (the default one first per engine) and instance classes the region offers
+	engineVersions   []*rds.DBEngineVersion
+	orderableClasses map[string][]string
+
+	// Number of Describe polls a resource stays in a transitional state
+	transitionPolls int
+	// Status a cluster or instance being created ends up in instead of available, by identifier
+	createFailures map[string]string
+	// Error of every point-in-time cluster restore, e.g. throttling
+	restoreErr error
+
+	// Names of the API calls received, in order
+	calls []string
+
+	// Inputs of the mutating calls, for assertions
+	restoreInputs             []*rds.RestoreDBClusterToPointInTimeInput
+	restoreFromSnapshotInputs []*rds.RestoreDBClusterFromSnapshotInput
+	restoreInstanceInputs     []*rds.RestoreDBInstanceToPointInTimeInput
+	restoreInstanceSnapInputs []*rds.RestoreDBInstanceFromDBSnapshotInput
+	copySnapshotInputs        []*rds.CopyDBClusterSnapshotInput
+	snapshotAttributeInputs   []*rds.ModifyDBClusterSnapshotAttributeInput
+	createInstanceInputs      []*rds.CreateDBInstanceInput
+	modifyClusterParamsInputs []*rds.ModifyDBClusterParameterGroupInput
+	modifyParamsInputs        []*rds.ModifyDBParameterGroupInput
+}
+
+type fakeCluster struct {
+	cluster      *rds.DBCluster
+	pendingPolls int
+}
+
+type fakeInstance struct {
+	instance     *rds.DBInstance
+	pendingPolls int
+}
+
+func newFakeRDS() *fakeRDS {
+	return &fakeRDS{
+		clusters:          map[string]*fakeCluster{},
+		instances:         map[string]*fakeInstance{},
+		snapshots:         map[string]*rds.DBClusterSnapshot{},
+		instanceSnapshots: map[string]*rds.DBSnapshot{},
+		clusterParameterGroups: map[string]*rds.DBClusterParameterGroup{
+			"default.aurora-mysql5.7":     fakeClusterParameterGroup("default.aurora-mysql5.7", "aurora-mysql5.7"),
+			"default.aurora-postgresql13": fakeClusterParameterGroup("default.aurora-postgresql13", "aurora-postgresql13"),
+		},
+		parameterGroups: map[string]*rds.DBParameterGroup{
+			"default.aurora-mysql5.7":     fakeParameterGroup("default.aurora-mysql5.7", "aurora-mysql5.7"),
+			"default.aurora-postgresql13": fakeParameterGroup("default.aurora-postgresql13", "aurora-postgresql13"),
+		},
+		resourceTags:           map[string][]*rds.Tag{},
+		clusterGroupParameters: map[string][]*rds.Parameter{},
+		groupParameters:        map[string][]*rds.Parameter{},
+		engineVersions: []*rds.DBEngineVersion{
+			fakeEngineVersion("aurora-mysql", "5.7.mysql_aurora.2.10.0", "aurora-mysql5.7"),
+			fakeEngineVersion("aurora-mysql", "8.0.mysql_aurora.3.01.0", "aurora-mysql8.0"),
+			fakeEngineVersion("aurora-mysql", "5.7.mysql_aurora.2.07.2", "aurora-mysql5.7", "serverless"),
+			fakeEngineVersion("aurora-postgresql", "13.4", "aurora-postgresql13"),
+			fakeEngineVersion("aurora-postgresql", "12.8", "aurora-postgresql12"),
+			fakeEngineVersion("aurora", "5.6.10a", "aurora5.6"),
+		},
+		orderableClasses: map[string][]string{
+			"aurora-mysql":      {"db.t3.small", "db.t3.medium", "db.r5.large", "db.r5.2xlarge", "db.serverless"},
+			"aurora-postgresql": {"db.t3.medium", "db.r5.large", "db.r5.2xlarge", "db.serverless"},
+			"aurora":            {"db.t3.small", "db.r5.large"},
+			"postgres":          {"db.t3.small", "db.t3.medium", "db.m5.large"},
+		},
+		transitionPolls: 2,
+		createFailures:  map[string]string{},
+	}
+}
+
+// Add an existing cluster in the given status
+func (f *fakeRDS) addCluster(name string, status string) {
+	f.clusters[name] = &fakeCluster{
+		cluster: &rds.DBCluster{
+			DBClusterIdentifier:     aws.String(name),
+			Status:                  aws.String(status),
+			Engine:                  aws.String("aurora-mysql"),
+			EngineVersion:           aws.String("5.7.mysql_aurora.2.10.0"),
+			DBClusterParameterGroup: aws.String("default.aurora-mysql5.7"),
+			EarliestRestorableTime:  aws.Time(time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)),
+			LatestRestorableTime:    aws.Time(time.Date(2021, 8, 21, 21, 0, 0, 0, time.UTC)),
+			DBClusterMembers:        []*rds.DBClusterMember{},
+		},
+		pendingPolls: f.transitionPolls,
+	}
+}
+
+// Add an available cluster restored from source by a previous run of this tool
+func (f *fakeRDS) addRestoredCluster(name string, source string) {
+	f.addCluster(name, "available")
+	f.clusters[name].cluster.TagList = restoreTags(&RestoreConfig{SourceRDS: source, RunID: "previous-run"})
+}
+
+// Add an existing instance in the given status as a member of a cluster
+func (f *fakeRDS) addInstance(clusterName string, name string, status string) {
+	f.instances[name] = &fakeInstance{
+		instance: &rds.DBInstance{
+			DBInstanceIdentifier: aws.String(name),
+			DBClusterIdentifier:  aws.String(clusterName),
+			DBInstanceStatus:     aws.String(status),
+			DBInstanceClass:      aws.String("db.t3.small"),
+			Engine:               aws.String("aurora-mysql"),
+			DBParameterGroups: []*rds.DBParameterGroupStatus{
+				{DBParameterGroupName: aws.String("default.aurora-mysql5.7"), ParameterApplyStatus: aws.String("in-sync")},
+			},
+		},
+		pendingPolls: f.transitionPolls,
+	}
+	if c, ok := f.clusters[clusterName]; ok {
+		c.cluster.DBClusterMembers = append(c.cluster.DBClusterMembers, &rds.DBClusterMember{
+			DBInstanceIdentifier:          aws.String(name),
+			IsClusterWriter:               aws.Bool(len(c.cluster.DBClusterMembers) == 0),
+			DBClusterParameterGroupStatus: aws.String("in-sync"),
+		})
+	}
+}
+
+func (f *fakeRDS) removeClusterMember(clusterName string, instanceName string) {
+	c, ok := f.clusters[clusterName]
+	if !ok {
+		return
+	}
+	members := []*rds.DBClusterMember{}
+	for _, m := range c.cluster.DBClusterMembers {
+		if aws.StringValue(m.DBInstanceIdentifier) != instanceName {
+			members = append(members, m)
+		}
+	}
+	c.cluster.DBClusterMembers = members
+}
+
+// Add an existing standalone instance in the given status
+func (f *fakeRDS) addStandaloneInstance(name string, status string) {
+	f.addInstance("", name, status)
+	f.instances[name].instance.DBClusterIdentifier = nil
+	f.instances[name].instance.Engine = aws.String("postgres")
+}
+
+// Add an available standalone instance restored from source by a previous run of this tool
+func (f *fakeRDS) addRestoredStandaloneInstance(name string, source string) {
+	f.addStandaloneInstance(name, "available")
+	f.instances[name].instance.TagList = restoreTags(&RestoreConfig{SourceRDS: source, RunID: "previous-run"})
+}
+
+// Advance lifecycle of every pending resource by one poll
+func (f *fakeRDS) tick() {
+	for name, c := range f.clusters {
+		switch aws.StringValue(c.cluster.Status) {
+		case "creating", "deleting":
+			if c.pendingPolls > 0 {
+				c.pendingPolls--
+				continue
+			}
+			if aws.StringValue(c.cluster.Status) == "deleting" {
+				delete(f.clusters, name)
+			} else {
+				c.cluster.Status = aws.String(f.createdStatus(name))
+			}
+		}
+	}
+	for name, i := range f.instances {
+		switch aws.StringValue(i.instance.DBInstanceStatus) {
+		case "creating", "deleting", "rebooting":
+			if i.pendingPolls > 0 {
+				i.pendingPolls--
+				continue
+			}
+			switch aws.StringValue(i.instance.DBInstanceStatus) {
+			case "deleting":
+				f.removeClusterMember(aws.StringValue(i.instance.DBClusterIdentifier), name)
+				delete(f.instances, name)
+			case "rebooting":
+				f.setParameterApplyStatus(i.instance, "in-sync")
+				i.instance.DBInstanceStatus = aws.String("available")
+			default:
+				i.instance.DBInstanceStatus = aws.String(f.createdStatus(name))
+			}
+		}
+	}
+}
+
+func (f *fakeRDS) createdStatus(name string) string {
+	if status, ok := f.createFailures[name]; ok {
+		return status
+	}
+	return "available"
+}
+
+func (f *fakeRDS) record(call string) {
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeRDS) callCount(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, c := range f.calls {
+		if c == call {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *fakeRDS) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBClusters")
+	f.tick()
+
+	name := aws.StringValue(input.DBClusterIdentifier)
+	if name == "" {
+		out := &rds.DescribeDBClustersOutput{}
+		for _, c := range f.clusters {
+			out.DBClusters = append(out.DBClusters, c.cluster)
+		}
+		return out, nil
+	}
+
+	c, ok := f.clusters[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterNotFoundFault, fmt.Sprintf("DBCluster %v not found.", name), nil)
+	}
+	return &rds.DescribeDBClustersOutput{DBClusters: []*rds.DBCluster{c.cluster}}, nil
+}
+
+func (f *fakeRDS) DescribeDBClustersPages(input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool) error {
+	out, err := f.DescribeDBClusters(input)
+	if err != nil {
+		return err
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeRDS) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBInstances")
+	f.tick()
+
+	name := aws.StringValue(input.DBInstanceIdentifier)
+	if name == "" {
+		out := &rds.DescribeDBInstancesOutput{}
+		for _, i := range f.instances {
+			out.DBInstances = append(out.DBInstances, i.instance)
+		}
+		return out, nil
+	}
+
+	i, ok := f.instances[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceNotFoundFault, fmt.Sprintf("DBInstance %v not found.", name), nil)
+	}
+	return &rds.DescribeDBInstancesOutput{DBInstances: []*rds.DBInstance{i.instance}}, nil
+}
+
+func (f *fakeRDS) RestoreDBClusterToPointInTime(input *rds.RestoreDBClusterToPointInTimeInput) (*rds.RestoreDBClusterToPointInTimeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("RestoreDBClusterToPointInTime")
+	f.restoreInputs = append(f.restoreInputs, input)
+	if f.restoreErr != nil {
+		return nil, f.restoreErr
+	}
+
+	source := aws.StringValue(input.SourceDBClusterIdentifier)
+	target := aws.StringValue(input.DBClusterIdentifier)
+	if _, ok := f.clusters[source]; !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterNotFoundFault, fmt.Sprintf("DBCluster %v not found.", source), nil)
+	}
+	if _, ok := f.clusters[target]; ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterAlreadyExistsFault, fmt.Sprintf("DBCluster %v already exists.", target), nil)
+	}
+
+	f.addCluster(target, "creating")
+	f.clusters[target].cluster.TagList = input.Tags
+	f.clusters[target].cluster.EngineMode = input.EngineMode
+	if input.DBClusterParameterGroupName != nil {
+		f.clusters[target].cluster.DBClusterParameterGroup = input.DBClusterParameterGroupName
+	}
+	return &rds.RestoreDBClusterToPointInTimeOutput{DBCluster: f.clusters[target].cluster}, nil
+}
+
+func (f *fakeRDS) RestoreDBClusterFromSnapshot(input *rds.RestoreDBClusterFromSnapshotInput) (*rds.RestoreDBClusterFromSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("RestoreDBClusterFromSnapshot")
+	f.restoreFromSnapshotInputs = append(f.restoreFromSnapshotInputs, input)
+
+	snapshotName := aws.StringValue(input.SnapshotIdentifier)
+	target := aws.StringValue(input.DBClusterIdentifier)
+	if _, ok := f.snapshots[snapshotName]; !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterSnapshotNotFoundFault, fmt.Sprintf("DBClusterSnapshot %v not found.", snapshotName), nil)
+	}
+	if _, ok := f.clusters[target]; ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterAlreadyExistsFault, fmt.Sprintf("DBCluster %v already exists.", target), nil)
+	}
+
+	f.addCluster(target, "creating")
+	f.clusters[target].cluster.TagList = input.Tags
+	f.clusters[target].cluster.EngineMode = input.EngineMode
+	if input.DBClusterParameterGroupName != nil {
+		f.clusters[target].cluster.DBClusterParameterGroup = input.DBClusterParameterGroupName
+	}
+	return &rds.RestoreDBClusterFromSnapshotOutput{DBCluster: f.clusters[target].cluster}, nil
+}
+
+func (f *fakeRDS) CreateDBInstance(input *rds.CreateDBInstanceInput) (*rds.CreateDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("CreateDBInstance")
+	f.createInstanceInputs = append(f.createInstanceInputs, input)
+
+	clusterName := aws.StringValue(input.DBClusterIdentifier)
+	name := aws.StringValue(input.DBInstanceIdentifier)
+	c, ok := f.clusters[clusterName]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterNotFoundFault, fmt.Sprintf("DBCluster %v not found.", clusterName), nil)
+	}
+	if aws.StringValue(c.cluster.Status) != "available" {
+		return nil, awserr.New(rds.ErrCodeInvalidDBClusterStateFault, fmt.Sprintf("DBCluster %v is not available.", clusterName), nil)
+	}
+	if _, ok := f.instances[name]; ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceAlreadyExistsFault, fmt.Sprintf("DBInstance %v already exists.", name), nil)
+	}
+
+	f.addInstance(clusterName, name, "creating")
+	f.instances[name].instance.DBInstanceClass = input.DBInstanceClass
+	f.instances[name].instance.AvailabilityZone = input.AvailabilityZone
+	f.instances[name].instance.PromotionTier = input.PromotionTier
+	if input.DBParameterGroupName != nil {
+		f.instances[name].instance.DBParameterGroups[0].DBParameterGroupName = input.DBParameterGroupName
+	}
+	return &rds.CreateDBInstanceOutput{DBInstance: f.instances[name].instance}, nil
+}
+
+func (f *fakeRDS) RestoreDBInstanceToPointInTime(input *rds.RestoreDBInstanceToPointInTimeInput) (*rds.RestoreDBInstanceToPointInTimeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("RestoreDBInstanceToPointInTime")
+	f.restoreInstanceInputs = append(f.restoreInstanceInputs, input)
+
+	source := aws.StringValue(input.SourceDBInstanceIdentifier)
+	target := aws.StringValue(input.TargetDBInstanceIdentifier)
+	if _, ok := f.instances[source]; !ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceNotFoundFault, fmt.Sprintf("DBInstance %v not found.", source), nil)
+	}
+	if _, ok := f.instances[target]; ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceAlreadyExistsFault, fmt.Sprintf("DBInstance %v already exists.", target), nil)
+	}
+
+	f.addStandaloneInstance(target, "creating")
+	f.instances[target].instance.DBInstanceClass = input.DBInstanceClass
+	f.instances[target].instance.TagList = input.Tags
+	if input.DBParameterGroupName != nil {
+		f.instances[target].instance.DBParameterGroups[0].DBParameterGroupName = input.DBParameterGroupName
+	}
+	return &rds.RestoreDBInstanceToPointInTimeOutput{DBInstance: f.instances[target].instance}, nil
+}
+
+func (f *fakeRDS) RestoreDBInstanceFromDBSnapshot(input *rds.RestoreDBInstanceFromDBSnapshotInput) (*rds.RestoreDBInstanceFromDBSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("RestoreDBInstanceFromDBSnapshot")
+	f.restoreInstanceSnapInputs = append(f.restoreInstanceSnapInputs, input)
+
+	snapshotName := aws.StringValue(input.DBSnapshotIdentifier)
+	target := aws.StringValue(input.DBInstanceIdentifier)
+	if _, ok := f.instanceSnapshots[snapshotName]; !ok {
+		return nil, awserr.New(rds.ErrCodeDBSnapshotNotFoundFault, fmt.Sprintf("DBSnapshot %v not found.", snapshotName), nil)
+	}
+	if _, ok := f.instances[target]; ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceAlreadyExistsFault, fmt.Sprintf("DBInstance %v already exists.", target), nil)
+	}
+
+	f.addStandaloneInstance(target, "creating")
+	f.instances[target].instance.DBInstanceClass = input.DBInstanceClass
+	f.instances[target].instance.TagList = input.Tags
+	if input.DBParameterGroupName != nil {
+		f.instances[target].instance.DBParameterGroups[0].DBParameterGroupName = input.DBParameterGroupName
+	}
+	return &rds.RestoreDBInstanceFromDBSnapshotOutput{DBInstance: f.instances[target].instance}, nil
+}
+
+func (f *fakeRDS) DeleteDBInstance(input *rds.DeleteDBInstanceInput) (*rds.DeleteDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DeleteDBInstance")
+
+	name := aws.StringValue(input.DBInstanceIdentifier)
+	i, ok := f.instances[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceNotFoundFault, fmt.Sprintf("DBInstance %v not found.", name), nil)
+	}
+	if aws.StringValue(i.instance.DBInstanceStatus) != "available" {
+		return nil, awserr.New(rds.ErrCodeInvalidDBInstanceStateFault, fmt.Sprintf("DBInstance %v is not available.", name), nil)
+	}
+
+	i.instance.DBInstanceStatus = aws.String("deleting")
+	i.pendingPolls = f.transitionPolls
+	return &rds.DeleteDBInstanceOutput{DBInstance: i.instance}, nil
+}
+
+func (f *fakeRDS) DeleteDBCluster(input *rds.DeleteDBClusterInput) (*rds.DeleteDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DeleteDBCluster")
+
+	name := aws.StringValue(input.DBClusterIdentifier)
+	c, ok := f.clusters[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterNotFoundFault, fmt.Sprintf("DBCluster %v not found.", name), nil)
+	}
+	if len(c.cluster.DBClusterMembers) > 0 || aws.StringValue(c.cluster.Status) != "available" {
+		return nil, awserr.New(rds.ErrCodeInvalidDBClusterStateFault, fmt.Sprintf("DBCluster %v is not in a deletable state.", name), nil)
+	}
+
+	if !aws.BoolValue(input.SkipFinalSnapshot) {
+		f.addClusterSnapshot(name, aws.StringValue(input.FinalDBSnapshotIdentifier), time.Now())
+	}
+
+	c.cluster.Status = aws.String("deleting")
+	c.pendingPolls = f.transitionPolls
+	return &rds.DeleteDBClusterOutput{DBCluster: c.cluster}, nil
+}
+
+// Add an available manual snapshot of a cluster
+func (f *fakeRDS) addClusterSnapshot(clusterName string, name string, created time.Time) {
+	f.snapshots[name] = &rds.DBClusterSnapshot{
+		DBClusterSnapshotIdentifier: aws.String(name),
+		DBClusterIdentifier:         aws.String(clusterName),
+		SnapshotType:                aws.String("manual"),
+		Status:                      aws.String("available"),
+		SnapshotCreateTime:          aws.Time(created),
+	}
+}
+
+func (f *fakeRDS) DescribeDBClusterSnapshots(input *rds.DescribeDBClusterSnapshotsInput) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	var out *rds.DescribeDBClusterSnapshotsOutput
+	err := f.DescribeDBClusterSnapshotsPages(input, func(page *rds.DescribeDBClusterSnapshotsOutput, lastPage bool) bool {
+		out = page
+		return false
+	})
+	if err == nil && input.DBClusterSnapshotIdentifier != nil && len(out.DBClusterSnapshots) == 0 {
+		name := aws.StringValue(input.DBClusterSnapshotIdentifier)
+		return nil, awserr.New(rds.ErrCodeDBClusterSnapshotNotFoundFault, fmt.Sprintf("DBClusterSnapshot %v not found.", name), nil)
+	}
+	return out, err
+}
+
+func (f *fakeRDS) DescribeDBClusterSnapshotsPages(input *rds.DescribeDBClusterSnapshotsInput, fn func(*rds.DescribeDBClusterSnapshotsOutput, bool) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBClusterSnapshots")
+
+	out := &rds.DescribeDBClusterSnapshotsOutput{}
+	for _, snapshot := range f.snapshots {
+		if input.DBClusterSnapshotIdentifier != nil && aws.StringValue(snapshot.DBClusterSnapshotIdentifier) != aws.StringValue(input.DBClusterSnapshotIdentifier) {
+			continue
+		}
+		if input.DBClusterIdentifier != nil && aws.StringValue(snapshot.DBClusterIdentifier) != aws.StringValue(input.DBClusterIdentifier) {
+			continue
+		}
+		if input.SnapshotType != nil && aws.StringValue(snapshot.SnapshotType) != aws.StringValue(input.SnapshotType) {
+			continue
+		}
+		out.DBClusterSnapshots = append(out.DBClusterSnapshots, snapshot)
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeRDS) CopyDBClusterSnapshot(input *rds.CopyDBClusterSnapshotInput) (*rds.CopyDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("CopyDBClusterSnapshot")
+	f.copySnapshotInputs = append(f.copySnapshotInputs, input)
+
+	name := aws.StringValue(input.TargetDBClusterSnapshotIdentifier)
+	if _, ok := f.snapshots[name]; ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterSnapshotAlreadyExistsFault, fmt.Sprintf("DBClusterSnapshot %v already exists.", name), nil)
+	}
+	f.addClusterSnapshot("", name, time.Now())
+	f.snapshots[name].TagList = input.Tags
+	return &rds.CopyDBClusterSnapshotOutput{DBClusterSnapshot: f.snapshots[name]}, nil
+}
+
+func (f *fakeRDS) CreateDBClusterSnapshot(input *rds.CreateDBClusterSnapshotInput) (*rds.CreateDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("CreateDBClusterSnapshot")
+
+	clusterName := aws.StringValue(input.DBClusterIdentifier)
+	name := aws.StringValue(input.DBClusterSnapshotIdentifier)
+	if _, ok := f.clusters[clusterName]; !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterNotFoundFault, fmt.Sprintf("DBCluster %v not found.", clusterName), nil)
+	}
+	f.addClusterSnapshot(clusterName, name, time.Now())
+	f.snapshots[name].TagList = input.Tags
+	return &rds.CreateDBClusterSnapshotOutput{DBClusterSnapshot: f.snapshots[name]}, nil
+}
+
+func (f *fakeRDS) ModifyDBClusterSnapshotAttribute(input *rds.ModifyDBClusterSnapshotAttributeInput) (*rds.ModifyDBClusterSnapshotAttributeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ModifyDBClusterSnapshotAttribute")
+	f.snapshotAttributeInputs = append(f.snapshotAttributeInputs, input)
+
+	name := aws.StringValue(input.DBClusterSnapshotIdentifier)
+	snapshot, ok := f.snapshots[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterSnapshotNotFoundFault, fmt.Sprintf("DBClusterSnapshot %v not found.", name), nil)
+	}
+	if aws.StringValue(snapshot.SnapshotType) != "manual" {
+		return nil, awserr.New(rds.ErrCodeInvalidDBClusterSnapshotStateFault, fmt.Sprintf("DBClusterSnapshot %v can't be shared.", name), nil)
+	}
+	return &rds.ModifyDBClusterSnapshotAttributeOutput{}, nil
+}
+
+func (f *fakeRDS) DeleteDBClusterSnapshot(input *rds.DeleteDBClusterSnapshotInput) (*rds.DeleteDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DeleteDBClusterSnapshot")
+
+	name := aws.StringValue(input.DBClusterSnapshotIdentifier)
+	snapshot, ok := f.snapshots[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterSnapshotNotFoundFault, fmt.Sprintf("DBClusterSnapshot %v not found.", name), nil)
+	}
+	delete(f.snapshots, name)
+	return &rds.DeleteDBClusterSnapshotOutput{DBClusterSnapshot: snapshot}, nil
+}
+
+// Add an available snapshot of a standalone instance
+func (f *fakeRDS) addInstanceSnapshot(instanceName string, name string, snapshotType string, created time.Time) {
+	f.instanceSnapshots[name] = &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String(name),
+		DBInstanceIdentifier: aws.String(instanceName),
+		SnapshotType:         aws.String(snapshotType),
+		Status:               aws.String("available"),
+		SnapshotCreateTime:   aws.Time(created),
+	}
+}
+
+func (f *fakeRDS) DescribeDBSnapshotsPages(input *rds.DescribeDBSnapshotsInput, fn func(*rds.DescribeDBSnapshotsOutput, bool) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBSnapshots")
+
+	out := &rds.DescribeDBSnapshotsOutput{}
+	for _, snapshot := range f.instanceSnapshots {
+		if aws.StringValue(snapshot.DBInstanceIdentifier) == aws.StringValue(input.DBInstanceIdentifier) &&
+			aws.StringValue(snapshot.SnapshotType) == aws.StringValue(input.SnapshotType) {
+			out.DBSnapshots = append(out.DBSnapshots, snapshot)
+		}
+	}
+	fn(out, true)
+	return nil
+}
+
+func fakeEngineVersion(engine string, version string, family string, extraModes ...string) *rds.DBEngineVersion {
+	return &rds.DBEngineVersion{
+		Engine:                 aws.String(engine),
+		EngineVersion:          aws.String(version),
+		DBParameterGroupFamily: aws.String(family),
+		SupportedEngineModes:   aws.StringSlice(append([]string{"provisioned"}, extraModes...)),
+	}
+}
+
+func (f *fakeRDS) DescribeDBEngineVersions(input *rds.DescribeDBEngineVersionsInput) (*rds.DescribeDBEngineVersionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBEngineVersions")
+
+	out := &rds.DescribeDBEngineVersionsOutput{}
+	for _, version := range f.engineVersions {
+		if aws.StringValue(version.Engine) != aws.StringValue(input.Engine) {
+			continue
+		}
+		if input.EngineVersion != nil && aws.StringValue(version.EngineVersion) != aws.StringValue(input.EngineVersion) {
+			continue
+		}
+		out.DBEngineVersions = append(out.DBEngineVersions, version)
+		if aws.BoolValue(input.DefaultOnly) {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRDS) DescribeOrderableDBInstanceOptions(input *rds.DescribeOrderableDBInstanceOptionsInput) (*rds.DescribeOrderableDBInstanceOptionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeOrderableDBInstanceOptions")
+
+	out := &rds.DescribeOrderableDBInstanceOptionsOutput{}
+	for _, instanceClass := range f.orderableClasses[aws.StringValue(input.Engine)] {
+		if instanceClass == aws.StringValue(input.DBInstanceClass) {
+			out.OrderableDBInstanceOptions = append(out.OrderableDBInstanceOptions, &rds.OrderableDBInstanceOption{
+				Engine:          input.Engine,
+				EngineVersion:   input.EngineVersion,
+				DBInstanceClass: aws.String(instanceClass),
+			})
+		}
+	}
+	return out, nil
+}
+
+func fakeClusterParameterGroup(name string, family string) *rds.DBClusterParameterGroup {
+	return &rds.DBClusterParameterGroup{
+		DBClusterParameterGroupName: aws.String(name),
+		DBClusterParameterGroupArn:  aws.String("arn:aws:rds:us-east-1:123456789012:cluster-pg:" + name),
+		DBParameterGroupFamily:      aws.String(family),
+	}
+}
+
+func fakeParameterGroup(name string, family string) *rds.DBParameterGroup {
+	return &rds.DBParameterGroup{
+		DBParameterGroupName:   aws.String(name),
+		DBParameterGroupArn:    aws.String("arn:aws:rds:us-east-1:123456789012:pg:" + name),
+		DBParameterGroupFamily: aws.String(family),
+	}
+}
+
+func (f *fakeRDS) DescribeDBClusterParameterGroups(input *rds.DescribeDBClusterParameterGroupsInput) (*rds.DescribeDBClusterParameterGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBClusterParameterGroups")
+
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	group, ok := f.clusterParameterGroups[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, fmt.Sprintf("DBClusterParameterGroup %v not found.", name), nil)
+	}
+	return &rds.DescribeDBClusterParameterGroupsOutput{DBClusterParameterGroups: []*rds.DBClusterParameterGroup{group}}, nil
+}
+
+func (f *fakeRDS) DescribeDBParameterGroups(input *rds.DescribeDBParameterGroupsInput) (*rds.DescribeDBParameterGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBParameterGroups")
+
+	name := aws.StringValue(input.DBParameterGroupName)
+	group, ok := f.parameterGroups[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, fmt.Sprintf("DBParameterGroup %v not found.", name), nil)
+	}
+	return &rds.DescribeDBParameterGroupsOutput{DBParameterGroups: []*rds.DBParameterGroup{group}}, nil
+}
+
+func (f *fakeRDS) CopyDBClusterParameterGroup(input *rds.CopyDBClusterParameterGroupInput) (*rds.CopyDBClusterParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("CopyDBClusterParameterGroup")
+
+	source, ok := f.clusterParameterGroups[aws.StringValue(input.SourceDBClusterParameterGroupIdentifier)]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, "source DBClusterParameterGroup not found.", nil)
+	}
+	name := aws.StringValue(input.TargetDBClusterParameterGroupIdentifier)
+	if _, ok := f.clusterParameterGroups[name]; ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupAlreadyExistsFault, fmt.Sprintf("DBClusterParameterGroup %v already exists.", name), nil)
+	}
+
+	group := fakeClusterParameterGroup(name, aws.StringValue(source.DBParameterGroupFamily))
+	f.clusterParameterGroups[name] = group
+	f.resourceTags[aws.StringValue(group.DBClusterParameterGroupArn)] = input.Tags
+	return &rds.CopyDBClusterParameterGroupOutput{DBClusterParameterGroup: group}, nil
+}
+
+func (f *fakeRDS) CopyDBParameterGroup(input *rds.CopyDBParameterGroupInput) (*rds.CopyDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("CopyDBParameterGroup")
+
+	source, ok := f.parameterGroups[aws.StringValue(input.SourceDBParameterGroupIdentifier)]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, "source DBParameterGroup not found.", nil)
+	}
+	name := aws.StringValue(input.TargetDBParameterGroupIdentifier)
+	if _, ok := f.parameterGroups[name]; ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupAlreadyExistsFault, fmt.Sprintf("DBParameterGroup %v already exists.", name), nil)
+	}
+
+	group := fakeParameterGroup(name, aws.StringValue(source.DBParameterGroupFamily))
+	f.parameterGroups[name] = group
+	f.resourceTags[aws.StringValue(group.DBParameterGroupArn)] = input.Tags
+	return &rds.CopyDBParameterGroupOutput{DBParameterGroup: group}, nil
+}
+
+func (f *fakeRDS) ModifyDBClusterParameterGroup(input *rds.ModifyDBClusterParameterGroupInput) (*rds.DBClusterParameterGroupNameMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ModifyDBClusterParameterGroup")
+	f.modifyClusterParamsInputs = append(f.modifyClusterParamsInputs, input)
+
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	if f.modifyParameters(f.clusterGroupParameters[name], input.Parameters) {
+		for _, c := range f.clusters {
+			if aws.StringValue(c.cluster.DBClusterParameterGroup) != name {
+				continue
+			}
+			for _, member := range c.cluster.DBClusterMembers {
+				member.DBClusterParameterGroupStatus = aws.String("pending-reboot")
+			}
+		}
+	}
+
+	return &rds.DBClusterParameterGroupNameMessage{DBClusterParameterGroupName: input.DBClusterParameterGroupName}, nil
+}
+
+func (f *fakeRDS) ModifyDBParameterGroup(input *rds.ModifyDBParameterGroupInput) (*rds.DBParameterGroupNameMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ModifyDBParameterGroup")
+	f.modifyParamsInputs = append(f.modifyParamsInputs, input)
+
+	name := aws.StringValue(input.DBParameterGroupName)
+	if f.modifyParameters(f.groupParameters[name], input.Parameters) {
+		for _, i := range f.instances {
+			for _, group := range i.instance.DBParameterGroups {
+				if aws.StringValue(group.DBParameterGroupName) == name {
+					group.ParameterApplyStatus = aws.String("pending-reboot")
+				}
+			}
+		}
+	}
+
+	return &rds.DBParameterGroupNameMessage{DBParameterGroupName: input.DBParameterGroupName}, nil
+}
+
+func (f *fakeRDS) DeleteDBClusterParameterGroup(input *rds.DeleteDBClusterParameterGroupInput) (*rds.DeleteDBClusterParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DeleteDBClusterParameterGroup")
+
+	delete(f.clusterParameterGroups, aws.StringValue(input.DBClusterParameterGroupName))
+	return &rds.DeleteDBClusterParameterGroupOutput{}, nil
+}
+
+func (f *fakeRDS) DeleteDBParameterGroup(input *rds.DeleteDBParameterGroupInput) (*rds.DeleteDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DeleteDBParameterGroup")
+
+	delete(f.parameterGroups, aws.StringValue(input.DBParameterGroupName))
+	return &rds.DeleteDBParameterGroupOutput{}, nil
+}
+
+func (f *fakeRDS) ListTagsForResource(input *rds.ListTagsForResourceInput) (*rds.ListTagsForResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ListTagsForResource")
+
+	return &rds.ListTagsForResourceOutput{TagList: f.resourceTags[aws.StringValue(input.ResourceName)]}, nil
+}
+
+func fakeParameter(name string, value string, applyType string) *rds.Parameter {
+	return &rds.Parameter{
+		ParameterName:  aws.String(name),
+		ParameterValue: aws.String(value),
+		ApplyType:      aws.String(applyType),
+		IsModifiable:   aws.Bool(true),
+	}
+}
+
+// Set the values of modified parameters, true when one of them waits for a reboot
+func (f *fakeRDS) modifyParameters(current []*rds.Parameter, modified []*rds.Parameter) bool {
+	pendingReboot := false
+	for _, parameter := range modified {
+		for _, c := range current {
+			if aws.StringValue(c.ParameterName) == aws.StringValue(parameter.ParameterName) {
+				c.ParameterValue = parameter.ParameterValue
+			}
+		}
+		if aws.StringValue(parameter.ApplyMethod) == rds.ApplyMethodPendingReboot {
+			pendingReboot = true
+		}
+	}
+	return pendingReboot
+}
+
+// Set the apply status of an instance's parameter group and its cluster's parameter group
+func (f *fakeRDS) setParameterApplyStatus(instance *rds.DBInstance, status string) {
+	for _, group := range instance.DBParameterGroups {
+		group.ParameterApplyStatus = aws.String(status)
+	}
+	if c, ok := f.clusters[aws.StringValue(instance.DBClusterIdentifier)]; ok {
+		for _, member := range c.cluster.DBClusterMembers {
+			if aws.StringValue(member.DBInstanceIdentifier) == aws.StringValue(instance.DBInstanceIdentifier) {
+				member.DBClusterParameterGroupStatus = aws.String(status)
+			}
+		}
+	}
+}
+
+func (f *fakeRDS) DescribeDBClusterParametersPages(input *rds.DescribeDBClusterParametersInput, fn func(*rds.DescribeDBClusterParametersOutput, bool) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBClusterParameters")
+
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	if _, ok := f.clusterParameterGroups[name]; !ok {
+		return awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, fmt.Sprintf("DBClusterParameterGroup %v not found.", name), nil)
+	}
+	fn(&rds.DescribeDBClusterParametersOutput{Parameters: f.clusterGroupParameters[name]}, true)
+	return nil
+}
+
+func (f *fakeRDS) DescribeDBParametersPages(input *rds.DescribeDBParametersInput, fn func(*rds.DescribeDBParametersOutput, bool) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DescribeDBParameters")
+
+	name := aws.StringValue(input.DBParameterGroupName)
+	if _, ok := f.parameterGroups[name]; !ok {
+		return awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, fmt.Sprintf("DBParameterGroup %v not found.", name), nil)
+	}
+	fn(&rds.DescribeDBParametersOutput{Parameters: f.groupParameters[name]}, true)
+	return nil
+}
+
+func (f *fakeRDS) RebootDBInstance(input *rds.RebootDBInstanceInput) (*rds.RebootDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("RebootDBInstance")
+
+	name := aws.StringValue(input.DBInstanceIdentifier)
+	i, ok := f.instances[name]
+	if !ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceNotFoundFault, fmt.Sprintf("DBInstance %v not found.", name), nil)
+	}
+	if aws.StringValue(i.instance.DBInstanceStatus) != "available" {
+		return nil, awserr.New(rds.ErrCodeInvalidDBInstanceStateFault, fmt.Sprintf("DBInstance %v is not available.", name), nil)
+	}
+	i.instance.DBInstanceStatus = aws.String("rebooting")
+	i.pendingPolls = f.transitionPolls
+	return &rds.RebootDBInstanceOutput{DBInstance: i.instance}, nil
+}
@@ -2,4 +2,7 @@ module github.com/burizz/automated_rds_restore
 
 go 1.16
 
-require github.com/aws/aws-sdk-go v1.40.21
+require (
+	github.com/aws/aws-sdk-go v1.44.0
+	gopkg.in/yaml.v3 v3.0.1
+)
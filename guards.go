@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// Refuse to delete restoreRDS if it is the source itself or matches the deny list
+func checkProtectedTarget(restoreConfig *RestoreConfig) error {
+	if restoreConfig.RestoreRDS == restoreConfig.SourceRDS {
+		return &SafetyError{Reason: fmt.Sprintf("restoreRDS [%v] is the same cluster as sourceRDS", restoreConfig.RestoreRDS)}
+	}
+
+	for _, protectedIdentifier := range restoreConfig.ProtectedIdentifiers {
+		protectedRegex, err := regexp.Compile(protectedIdentifier)
+		if err != nil {
+			return &SafetyError{Reason: fmt.Sprintf("invalid protected identifier regex [%v]: %v", protectedIdentifier, err)}
+		}
+		if protectedRegex.MatchString(restoreConfig.RestoreRDS) {
+			return &SafetyError{Reason: fmt.Sprintf("restoreRDS [%v] matches protected identifier [%v]", restoreConfig.RestoreRDS, protectedIdentifier)}
+		}
+	}
+	return nil
+}
+
+// Refuse to run destructive calls with credentials of an account that isn't allowed
+func checkAccountAllowed(stsClientSess stsiface.STSAPI, restoreConfig *RestoreConfig) error {
+	if len(restoreConfig.AllowedAccountIDs) == 0 {
+		return nil
+	}
+
+	resp, err := stsClientSess.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("Cannot verify AWS account, GetCallerIdentity err: %v", err)
+	}
+
+	accountID := aws.StringValue(resp.Account)
+	for _, allowedAccountID := range restoreConfig.AllowedAccountIDs {
+		if accountID == allowedAccountID {
+			logf("AWS account [%v] is allowed\n", accountID)
+			return nil
+		}
+	}
+	return &SafetyError{Reason: fmt.Sprintf("credentials of [%v] belong to AWS account [%v], allowed accounts are %v",
+		aws.StringValue(resp.Arn), accountID, restoreConfig.AllowedAccountIDs)}
+}
+
+// Every guard that has to pass before a job is allowed to delete anything
+func checkDestructiveGuards(stsClientSess stsiface.STSAPI, restoreConfig *RestoreConfig) error {
+	protectedErr := checkProtectedTarget(restoreConfig)
+	if protectedErr != nil {
+		return protectedErr
+	}
+	return checkAccountAllowed(stsClientSess, restoreConfig)
+}
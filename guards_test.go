@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// STS returning a fixed caller identity
+type fakeSTS struct {
+	stsiface.STSAPI
+	account string
+}
+
+func (f *fakeSTS) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String(f.account),
+		Arn:     aws.String("arn:aws:iam::" + f.account + ":role/restore"),
+	}, nil
+}
+
+func TestCheckDestructiveGuards(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*RestoreConfig)
+		account string
+		refused bool
+	}{
+		{"no guards configured", func(c *RestoreConfig) {}, "111111111111", false},
+		{"restore is source", func(c *RestoreConfig) { c.RestoreRDS = c.SourceRDS }, "111111111111", true},
+		{"protected identifier", func(c *RestoreConfig) { c.ProtectedIdentifiers = []string{"^prod-", "-restore$"} }, "111111111111", true},
+		{"unprotected identifier", func(c *RestoreConfig) { c.ProtectedIdentifiers = []string{"^prod-"} }, "111111111111", false},
+		{"allowed account", func(c *RestoreConfig) { c.AllowedAccountIDs = []string{"111111111111"} }, "111111111111", false},
+		{"unexpected account", func(c *RestoreConfig) { c.AllowedAccountIDs = []string{"111111111111"} }, "222222222222", true},
+	}
+
+	for _, test := range tests {
+		restoreConfig := testRestoreConfig()
+		test.modify(restoreConfig)
+
+		err := checkDestructiveGuards(&fakeSTS{account: test.account}, restoreConfig)
+
+		var safetyErr *SafetyError
+		if refused := errors.As(err, &safetyErr); refused != test.refused {
+			t.Errorf("%v: refused = %v, want %v (err: %v)", test.name, refused, test.refused, err)
+		}
+	}
+}
+
+func TestDeleteRefusesProtectedTarget(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.ProtectedIdentifiers = []string{"^test-db"}
+	restoreConfig.ForceDelete = true
+
+	err := cleanupRDSCluster(context.Background(), fake, restoreConfig)
+
+	var safetyErr *SafetyError
+	if !errors.As(err, &safetyErr) {
+		t.Fatalf("expected SafetyError, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("sent mutating calls %v", calls)
+	}
+}
+
+func TestProtectedIdentifiersAddUpAcrossDefaults(t *testing.T) {
+	defaults := restoreSettings{ProtectedIdentifiers: []string{"^prod-"}}
+	merged := defaults.merge(restoreSettings{ProtectedIdentifiers: []string{"^live-"}})
+
+	if len(merged.ProtectedIdentifiers) != 2 {
+		t.Errorf("ProtectedIdentifiers = %v, want both default and job entries", merged.ProtectedIdentifiers)
+	}
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Kind of RDS resource sourceRDS and restoreRDS are - an Aurora cluster or a
+// standalone (MySQL, PostgreSQL, MariaDB, ...) instance
+const (
+	kindCluster  = "cluster"
+	kindInstance = "instance"
+)
+
+// Restore sources a standalone instance can be restored from
+var instanceRestoreSources = []string{sourcePointInTime, sourceSnapshot, sourceLatestAutomatedSnapshot}
+
+// Look up whether sourceRDS is an Aurora cluster or a standalone instance
+func rdsSourceKind(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (string, error) {
+	return rdsKind(sourceClient(rdsClientSess), restoreConfig.SourceRDS)
+}
+
+// Look up whether restoreRDS is an Aurora cluster or a standalone instance,
+// empty if it doesn't exist
+func rdsTargetKind(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (string, error) {
+	kind, err := rdsKind(rdsClientSess, restoreConfig.RestoreRDS)
+	if _, notFound := err.(*notFoundError); notFound {
+		return "", nil
+	}
+	return kind, err
+}
+
+// Neither a cluster nor a standalone instance with that identifier exists
+type notFoundError struct {
+	name string
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("Neither an RDS cluster nor an RDS instance [%v] exists", e.name)
+}
+
+func rdsKind(rdsClientSess rdsiface.RDSAPI, name string) (string, error) {
+	cluster, describeErr := describeRDSCluster(rdsClientSess, name)
+	if describeErr != nil {
+		return "", describeErr
+	}
+	if cluster != nil {
+		return kindCluster, nil
+	}
+
+	instance, describeErr := describeRDSInstance(rdsClientSess, name)
+	if describeErr != nil {
+		return "", describeErr
+	}
+	if instance == nil {
+		return "", &notFoundError{name: name}
+	}
+	if aws.StringValue(instance.DBClusterIdentifier) != "" {
+		return "", fmt.Errorf("RDS instance [%v] is a member of cluster [%v], use the cluster identifier", name, aws.StringValue(instance.DBClusterIdentifier))
+	}
+	return kindInstance, nil
+}
+
+func describeRDSInstance(rdsClientSess rdsiface.RDSAPI, rdsInstanceName string) (*rds.DBInstance, error) {
+	resp, err := rdsClientSess.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(rdsInstanceName),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Describe Err on instance [%v]: %v", rdsInstanceName, err)
+	}
+	if len(resp.DBInstances) == 0 {
+		return nil, nil
+	}
+	return resp.DBInstances[0], nil
+}
+
+// Problems of a config that only show once sourceRDS turns out to be a standalone instance
+func (c *RestoreConfig) instanceProblems() []string {
+	var problems []string
+
+	supportedSource := false
+	for _, source := range instanceRestoreSources {
+		if c.RestoreSource == source {
+			supportedSource = true
+		}
+	}
+	if !supportedSource {
+		problems = append(problems, fmt.Sprintf("restoreSource [%v] is not supported for RDS instances, expected one of %v", c.RestoreSource, instanceRestoreSources))
+	}
+	if c.RestoreType == restoreTypeCopyOnWrite {
+		problems = append(problems, fmt.Sprintf("restoreType %v is only supported for Aurora clusters", restoreTypeCopyOnWrite))
+	}
+	if c.crossRegion() || c.crossAccount() {
+		problems = append(problems, "cross-region and cross-account restores are only supported for Aurora clusters")
+	}
+	if len(c.Instances) > 0 || c.MirrorSourceTopology {
+		problems = append(problems, "rdsInstances and mirrorSourceTopology are only supported for Aurora clusters")
+	}
+	if c.CapacityMode != capacityProvisioned {
+		problems = append(problems, fmt.Sprintf("rdsCapacityMode %v is only supported for Aurora clusters", c.CapacityMode))
+	}
+	if c.ClusterParameterGroup != "" || len(c.ClusterParameterOverrides) > 0 || c.CopySourceParameterGroups {
+		problems = append(problems, "rdsClusterParameterGroup, rdsClusterParameterOverrides and copySourceParameterGroups are only supported for Aurora clusters, use rdsParameterGroup")
+	}
+	if c.FinalSnapshotRetention != 0 {
+		problems = append(problems, "finalSnapshotRetention is only supported for Aurora clusters, final snapshots of RDS instances are kept")
+	}
+	return problems
+}
+
+// Delete the previous restore and restore the standalone sourceRDS instance
+// into restoreRDS, step by step so that a rerun of the same run resumes where
+// it stopped
+func restoreRDSInstance(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	if problems := restoreConfig.instanceProblems(); len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	steps := []restoreStep{
+		{
+			name:   stepCheck,
+			always: true,
+			run: func(ctx context.Context) error {
+				return checkRestoreRDSInstance(rdsClientSess, restoreConfig)
+			},
+		},
+		{
+			// Delete previous restore
+			name: stepDeleteInstance,
+			run: func(ctx context.Context) error {
+				return deletePreviousInstance(ctx, rdsClientSess, restoreConfig)
+			},
+			verify: verifyInstanceDeleted(rdsClientSess, restoreConfig),
+		},
+		{
+			name: stepRestore,
+			run: func(ctx context.Context) error {
+				restoreErr := restoreRDSInstanceFromSource(rdsClientSess, restoreConfig)
+				if restoreErr != nil {
+					return fmt.Errorf("Restore RDS Instance from %v Err: %w", restoreConfig.RestoreSource, restoreErr)
+				}
+				return nil
+			},
+			verify: verifyInstanceRestored(rdsClientSess, restoreConfig),
+		},
+		{
+			// Wait until the restored instance is available
+			name: stepWaitInstance,
+			run: func(ctx context.Context) error {
+				waitInstanceCreateErr := waitUntilRDSInstanceCreated(ctx, rdsClientSess, restoreConfig, restoreConfig.RestoreRDS)
+				if waitInstanceCreateErr != nil {
+					return fmt.Errorf("Wait RDS Instance create Err: %w", waitInstanceCreateErr)
+				}
+				return nil
+			},
+			verify: verifyInstanceRestored(rdsClientSess, restoreConfig, "available"),
+		},
+		{
+			// Override parameters of rdsParameterGroup, rebooting the instance for static ones
+			name: stepParameterOverrides,
+			run: func(ctx context.Context) error {
+				overridesErr := applyParameterOverrides(ctx, rdsClientSess, restoreConfig, []string{restoreConfig.RestoreRDS})
+				if overridesErr != nil {
+					return fmt.Errorf("Parameter overrides Err: %w", overridesErr)
+				}
+				return nil
+			},
+		},
+	}
+	return runRestoreSteps(ctx, restoreConfig, steps, func() (string, error) {
+		return reconcileRDSInstance(rdsClientSess, restoreConfig)
+	})
+}
+
+// Check the restore can go through before anything is deleted
+func checkRestoreRDSInstance(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	// The restored instance keeps the engine of the source, check the class fits it before anything is deleted
+	sourceInstance, describeErr := describeRDSInstance(sourceClient(rdsClientSess), restoreConfig.SourceRDS)
+	if describeErr != nil {
+		return describeErr
+	}
+	if sourceInstance != nil {
+		orderableErr := checkOrderableInstanceClasses(rdsClientSess, aws.StringValue(sourceInstance.Engine), aws.StringValue(sourceInstance.EngineVersion), []string{restoreConfig.InstanceType})
+		if orderableErr != nil {
+			return orderableErr
+		}
+	}
+
+	overridesErr := checkParameterOverrides(rdsClientSess, restoreConfig)
+	if overridesErr != nil {
+		return fmt.Errorf("Parameter overrides Err: %w", overridesErr)
+	}
+	return nil
+}
+
+// Create the restoreRDS instance from the configured source
+func restoreRDSInstanceFromSource(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	switch restoreConfig.RestoreSource {
+	case sourceSnapshot:
+		return restoreInstanceFromSnapshot(rdsClientSess, restoreConfig, restoreConfig.SnapshotIdentifier)
+	case sourceLatestAutomatedSnapshot:
+		snapshotName, findErr := findLatestAutomatedInstanceSnapshot(rdsClientSess, restoreConfig)
+		if findErr != nil {
+			return findErr
+		}
+		return restoreInstanceFromSnapshot(rdsClientSess, restoreConfig, snapshotName)
+	default:
+		return restoreInstancePointInTime(rdsClientSess, restoreConfig)
+	}
+}
+
+func restoreInstancePointInTime(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	input := &rds.RestoreDBInstanceToPointInTimeInput{
+		SourceDBInstanceIdentifier: aws.String(restoreConfig.SourceRDS),  // Required
+		TargetDBInstanceIdentifier: aws.String(restoreConfig.RestoreRDS), // Required
+		UseLatestRestorableTime:    aws.Bool(true),
+		DBInstanceClass:            aws.String(restoreConfig.InstanceType),
+		Tags:                       restoreTags(restoreConfig),
+	}
+
+	// If restore time provided use it instead of last restorable time
+	if !restoreConfig.RestoreTime.IsZero() {
+		input.UseLatestRestorableTime = aws.Bool(false)
+		input.RestoreTime = aws.Time(restoreConfig.RestoreTime)
+	}
+
+	// Not required - AWS uses the default subnet group and security group otherwise
+	if restoreConfig.SubnetGroup != "" {
+		input.DBSubnetGroupName = aws.String(restoreConfig.SubnetGroup)
+	}
+	if len(restoreConfig.SecurityGroupIDs) > 0 {
+		input.VpcSecurityGroupIds = aws.StringSlice(restoreConfig.SecurityGroupIDs)
+	}
+	if restoreConfig.ParameterGroup != "" {
+		input.DBParameterGroupName = aws.String(restoreConfig.ParameterGroup)
+	}
+
+	logf("Creating RDS instance [%v] from Point-In-Time restore of [%v]\n", restoreConfig.RestoreRDS, restoreConfig.SourceRDS)
+	_, err := rdsClientSess.RestoreDBInstanceToPointInTime(input)
+	errMsg := fmt.Sprintf("Error restoring RDS instance [%v] -> [%v]", restoreConfig.SourceRDS, restoreConfig.RestoreRDS)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case rds.ErrCodeDBInstanceNotFoundFault:
+				logln(rds.ErrCodeDBInstanceNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidDBInstanceStateFault:
+				logln(rds.ErrCodeInvalidDBInstanceStateFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodePointInTimeRestoreNotEnabledFault:
+				logln(rds.ErrCodePointInTimeRestoreNotEnabledFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBInstanceAlreadyExistsFault:
+				logln(rds.ErrCodeDBInstanceAlreadyExistsFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInstanceQuotaExceededFault:
+				logln(rds.ErrCodeInstanceQuotaExceededFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInsufficientDBInstanceCapacityFault:
+				logln(rds.ErrCodeInsufficientDBInstanceCapacityFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeStorageQuotaExceededFault:
+				logln(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidRestoreFault:
+				logln(rds.ErrCodeInvalidRestoreFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBSubnetGroupNotFoundFault:
+				logln(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs:
+				logln(rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidSubnet:
+				logln(rds.ErrCodeInvalidSubnet, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidVPCNetworkStateFault:
+				logln(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeKMSKeyNotAccessibleFault:
+				logln(rds.ErrCodeKMSKeyNotAccessibleFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBParameterGroupNotFoundFault:
+				logln(rds.ErrCodeDBParameterGroupNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeOptionGroupNotFoundFault:
+				logln(rds.ErrCodeOptionGroupNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeStorageTypeNotSupportedFault:
+				logln(rds.ErrCodeStorageTypeNotSupportedFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDomainNotFoundFault:
+				logln(rds.ErrCodeDomainNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			default:
+				logln(aerr.Error())
+				return fmt.Errorf(errMsg)
+			}
+		} else {
+			// Print the error, cast err to awserr.Error to get the Code and Message from an error.
+			logln(err.Error())
+			return fmt.Errorf(errMsg)
+		}
+	}
+
+	logf("Executed RDS point-in-time restore for instances [%v] -> [%v]\n", restoreConfig.RestoreRDS, restoreConfig.SourceRDS)
+	return nil
+}
+
+func restoreInstanceFromSnapshot(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshotName string) error {
+	input := &rds.RestoreDBInstanceFromDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(restoreConfig.RestoreRDS), // Required
+		DBSnapshotIdentifier: aws.String(snapshotName),             // Required
+		DBInstanceClass:      aws.String(restoreConfig.InstanceType),
+		Tags:                 restoreTags(restoreConfig),
+	}
+
+	// Not required - AWS uses the default subnet group and security group otherwise
+	if restoreConfig.SubnetGroup != "" {
+		input.DBSubnetGroupName = aws.String(restoreConfig.SubnetGroup)
+	}
+	if len(restoreConfig.SecurityGroupIDs) > 0 {
+		input.VpcSecurityGroupIds = aws.StringSlice(restoreConfig.SecurityGroupIDs)
+	}
+	if restoreConfig.ParameterGroup != "" {
+		input.DBParameterGroupName = aws.String(restoreConfig.ParameterGroup)
+	}
+
+	logf("Creating RDS instance [%v] from snapshot [%v]\n", restoreConfig.RestoreRDS, snapshotName)
+	_, err := rdsClientSess.RestoreDBInstanceFromDBSnapshot(input)
+	errMsg := fmt.Sprintf("Error restoring RDS instance [%v] from snapshot [%v]", restoreConfig.RestoreRDS, snapshotName)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case rds.ErrCodeDBSnapshotNotFoundFault:
+				logln(rds.ErrCodeDBSnapshotNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidDBSnapshotStateFault:
+				logln(rds.ErrCodeInvalidDBSnapshotStateFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBInstanceAlreadyExistsFault:
+				logln(rds.ErrCodeDBInstanceAlreadyExistsFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInstanceQuotaExceededFault:
+				logln(rds.ErrCodeInstanceQuotaExceededFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInsufficientDBInstanceCapacityFault:
+				logln(rds.ErrCodeInsufficientDBInstanceCapacityFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeStorageQuotaExceededFault:
+				logln(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidRestoreFault:
+				logln(rds.ErrCodeInvalidRestoreFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBSubnetGroupNotFoundFault:
+				logln(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs:
+				logln(rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidSubnet:
+				logln(rds.ErrCodeInvalidSubnet, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeInvalidVPCNetworkStateFault:
+				logln(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeKMSKeyNotAccessibleFault:
+				logln(rds.ErrCodeKMSKeyNotAccessibleFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDBParameterGroupNotFoundFault:
+				logln(rds.ErrCodeDBParameterGroupNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeOptionGroupNotFoundFault:
+				logln(rds.ErrCodeOptionGroupNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeStorageTypeNotSupportedFault:
+				logln(rds.ErrCodeStorageTypeNotSupportedFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			case rds.ErrCodeDomainNotFoundFault:
+				logln(rds.ErrCodeDomainNotFoundFault, aerr.Error())
+				return fmt.Errorf(errMsg)
+			default:
+				logln(aerr.Error())
+				return fmt.Errorf(errMsg)
+			}
+		} else {
+			// Print the error, cast err to awserr.Error to get the Code and Message from an error.
+			logln(err.Error())
+			return fmt.Errorf(errMsg)
+		}
+	}
+
+	logf("Executed RDS snapshot restore for instance [%v] from snapshot [%v]\n", restoreConfig.RestoreRDS, snapshotName)
+	return nil
+}
+
+// Newest available automated snapshot of the sourceRDS instance
+func findLatestAutomatedInstanceSnapshot(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (string, error) {
+	var snapshots []*rds.DBSnapshot
+	input := &rds.DescribeDBSnapshotsInput{
+		DBInstanceIdentifier: aws.String(restoreConfig.SourceRDS),
+		SnapshotType:         aws.String("automated"),
+	}
+	describeErr := rdsClientSess.DescribeDBSnapshotsPages(input, func(page *rds.DescribeDBSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.DBSnapshots {
+			if aws.StringValue(snapshot.Status) == "available" {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+		return true
+	})
+	if describeErr != nil {
+		return "", fmt.Errorf("Describe automated snapshots of RDS instance [%v] err: %v", restoreConfig.SourceRDS, describeErr)
+	}
+	if len(snapshots) == 0 {
+		return "", fmt.Errorf("No available automated snapshot of [%v] found", restoreConfig.SourceRDS)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return aws.TimeValue(snapshots[i].SnapshotCreateTime).After(aws.TimeValue(snapshots[j].SnapshotCreateTime))
+	})
+	return aws.StringValue(snapshots[0].DBSnapshotIdentifier), nil
+}
+
+// Delete the restoreRDS instance if it exists and wait until it is gone
+func cleanupRDSInstance(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	if stepErr := beginStep(ctx, stepDeleteInstance); stepErr != nil {
+		return stepErr
+	}
+	return deletePreviousInstance(ctx, rdsClientSess, restoreConfig)
+}
+
+func deletePreviousInstance(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	instance, describeErr := describeRDSInstance(rdsClientSess, restoreConfig.RestoreRDS)
+	if describeErr != nil {
+		return describeErr
+	}
+	if instance == nil {
+		logf("RDS instance [%v] doesnt exist, skipping delete step\n", restoreConfig.RestoreRDS)
+		return nil
+	}
+
+	switch aws.StringValue(instance.DBInstanceStatus) {
+	case "deleting":
+		logf("RDS instance [%v] is already being deleted, waiting for the delete to finish ...\n", restoreConfig.RestoreRDS)
+		waitDeleteErr := waitUntilRDSInstanceDeleted(ctx, rdsClientSess, restoreConfig, restoreConfig.RestoreRDS)
+		if waitDeleteErr != nil {
+			return fmt.Errorf("Wait RDS Instance delete Err: %w", waitDeleteErr)
+		}
+		return nil
+	case "creating":
+		ownershipErr := checkOwnershipTags(restoreConfig, fmt.Sprintf("RDS instance [%v]", restoreConfig.RestoreRDS), instance.TagList)
+		if ownershipErr != nil {
+			return fmt.Errorf("Delete RDS Instance Err: %w", ownershipErr)
+		}
+		logf("RDS instance [%v] is still being created, waiting until it can be deleted ...\n", restoreConfig.RestoreRDS)
+		waitCreateErr := waitUntilRDSInstanceCreated(ctx, rdsClientSess, restoreConfig, restoreConfig.RestoreRDS)
+		if waitCreateErr != nil {
+			return fmt.Errorf("Wait RDS Instance create Err: %w", waitCreateErr)
+		}
+	}
+
+	logf("RDS instance [%v] already exists, deleting it now ...\n", restoreConfig.RestoreRDS)
+	deleteErr := deleteStandaloneRDSInstance(rdsClientSess, restoreConfig, instance)
+	if deleteErr != nil {
+		return fmt.Errorf("Delete RDS Instance Err: %w", deleteErr)
+	}
+
+	waitDeleteErr := waitUntilRDSInstanceDeleted(ctx, rdsClientSess, restoreConfig, restoreConfig.RestoreRDS)
+	if waitDeleteErr != nil {
+		return fmt.Errorf("Wait RDS Instance delete Err: %w", waitDeleteErr)
+	}
+	return nil
+}
+
+func deleteStandaloneRDSInstance(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, instance *rds.DBInstance) error {
+	rdsInstanceName := restoreConfig.RestoreRDS
+
+	// Refuse to delete the source, a protected instance or an instance this tool didn't create
+	protectedErr := checkProtectedTarget(restoreConfig)
+	if protectedErr != nil {
+		return protectedErr
+	}
+	if aws.StringValue(instance.DBClusterIdentifier) != "" {
+		return &SafetyError{Reason: fmt.Sprintf("RDS instance [%v] is a member of cluster [%v], not a standalone restore", rdsInstanceName, aws.StringValue(instance.DBClusterIdentifier))}
+	}
+	ownershipErr := checkOwnershipTags(restoreConfig, fmt.Sprintf("RDS instance [%v]", rdsInstanceName), instance.TagList)
+	if ownershipErr != nil {
+		return ownershipErr
+	}
+
+	input := &rds.DeleteDBInstanceInput{
+		DBInstanceIdentifier: aws.String(rdsInstanceName),
+		SkipFinalSnapshot:    aws.Bool(true),
+	}
+
+	// Optionally keep a final snapshot of the previous restore
+	if restoreConfig.FinalSnapshotIdentifier != "" {
+		finalSnapshotName := renderFinalSnapshotIdentifier(restoreConfig, time.Now())
+		input.SkipFinalSnapshot = aws.Bool(false)
+		input.FinalDBSnapshotIdentifier = aws.String(finalSnapshotName)
+		logf("Taking final snapshot [%v] of RDS instance [%v]\n", finalSnapshotName, rdsInstanceName)
+	}
+
+	_, err := rdsClientSess.DeleteDBInstance(input)
+	if err != nil {
+		return fmt.Errorf("Error deleting RDS instance [%v]: %v", rdsInstanceName, err)
+	}
+
+	logf("Deleting RDS instance [%v]\n", rdsInstanceName)
+	return nil
+}
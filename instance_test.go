@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestRunRestoreStandaloneInstanceReplacesPreviousRestore(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addStandaloneInstance("test-db", "available")
+	fake.addRestoredStandaloneInstance("test-db-restore", "test-db")
+
+	if err := runRestore(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("runRestore: %v", err)
+	}
+
+	wantCalls := []string{"DeleteDBInstance", "RestoreDBInstanceToPointInTime"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("mutating calls = %v, want %v", calls, wantCalls)
+	}
+
+	input := fake.restoreInstanceInputs[0]
+	if !aws.BoolValue(input.UseLatestRestorableTime) || aws.StringValue(input.DBInstanceClass) != "db.t3.small" {
+		t.Errorf("unexpected restore input %v", input)
+	}
+	if !createdByRun(fake.instances["test-db-restore"].instance.TagList, testRestoreConfig()) {
+		t.Errorf("restored instance is not tagged with this run")
+	}
+	if status := aws.StringValue(fake.instances["test-db-restore"].instance.DBInstanceStatus); status != "available" {
+		t.Errorf("restored instance status = %v, want available", status)
+	}
+}
+
+func TestRunRestoreStandaloneInstanceLatestAutomatedSnapshot(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addStandaloneInstance("test-db", "available")
+	fake.addInstanceSnapshot("test-db", "rds:test-db-2021-08-20", "automated", time.Date(2021, 8, 20, 3, 0, 0, 0, time.UTC))
+	fake.addInstanceSnapshot("test-db", "rds:test-db-2021-08-21", "automated", time.Date(2021, 8, 21, 3, 0, 0, 0, time.UTC))
+	fake.addInstanceSnapshot("test-db", "manual-newest", "manual", time.Date(2021, 8, 22, 3, 0, 0, 0, time.UTC))
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RestoreSource = sourceLatestAutomatedSnapshot
+	if err := runRestore(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("runRestore: %v", err)
+	}
+
+	if got := aws.StringValue(fake.restoreInstanceSnapInputs[0].DBSnapshotIdentifier); got != "rds:test-db-2021-08-21" {
+		t.Errorf("restored from snapshot %v, want rds:test-db-2021-08-21", got)
+	}
+}
+
+func TestRunRestoreStandaloneInstanceRefusesUntaggedTarget(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addStandaloneInstance("test-db", "available")
+	fake.addStandaloneInstance("test-db-restore", "available")
+
+	err := runRestore(context.Background(), fake, testRestoreConfig())
+	var safetyErr *SafetyError
+	if !errors.As(err, &safetyErr) {
+		t.Fatalf("expected SafetyError, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("refused restore sent mutating calls: %v", calls)
+	}
+}
+
+func TestRunCleanupStandaloneInstance(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addRestoredStandaloneInstance("test-db-restore", "test-db")
+
+	if err := runCleanup(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("runCleanup: %v", err)
+	}
+	if _, ok := fake.instances["test-db-restore"]; ok {
+		t.Errorf("restoreRDS instance still exists after cleanup")
+	}
+}
+
+func TestRdsKindRefusesClusterMember(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("aurora-db", "available")
+	fake.addInstance("aurora-db", "aurora-db-0", "available")
+
+	if _, err := rdsKind(fake, "aurora-db-0"); err == nil {
+		t.Errorf("expected error for a cluster member instance")
+	}
+	if _, err := rdsKind(fake, "missing-db"); err == nil {
+		t.Errorf("expected not found error")
+	}
+}
+
+func TestInstanceProblems(t *testing.T) {
+	restoreConfig := testRestoreConfig()
+	if problems := restoreConfig.instanceProblems(); len(problems) != 0 {
+		t.Errorf("default config: problems = %q, want none", problems)
+	}
+
+	restoreConfig.RestoreSource = sourceNewSnapshot
+	restoreConfig.RestoreType = restoreTypeCopyOnWrite
+	restoreConfig.SourceRoleARN = "arn:aws:iam::123456789012:role/rds-restore"
+	restoreConfig.MirrorSourceTopology = true
+	if problems := restoreConfig.instanceProblems(); len(problems) != 4 {
+		t.Errorf("cluster only settings: problems = %q, want 4", problems)
+	}
+}
+
+func TestRestoreInstancePointInTimeReportsAWSFaultLikeClusterRestore(t *testing.T) {
+	fake := newFakeRDS()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.SourceRDS = "missing-db"
+	err := restoreInstancePointInTime(fake, restoreConfig)
+	if err == nil || err.Error() != "Error restoring RDS instance [missing-db] -> [test-db-restore]" {
+		t.Fatalf("expected restore error of the cluster path's form, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// Root context of a run, cancelled on SIGTERM (e.g. a CronJob pod being
+// evicted) or SIGINT. A second signal kills the process right away.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		logf("Received %v, interrupting the running step ...\n", sig)
+		signal.Stop(signals)
+		cancel()
+	}()
+	return ctx
+}
+
+// The run was interrupted somewhere in err
+func isInterrupted(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// Progress of a job, kept in its context so that an interrupted job can tell
+// which step it was in and which resources were still changing
+type jobProgress struct {
+	mu   sync.Mutex
+	step string
+	// Resources being waited for, with their last polled status
+	inFlight map[string]string
+}
+
+type progressKey struct{}
+
+func withProgress(ctx context.Context) (context.Context, *jobProgress) {
+	progress := &jobProgress{inFlight: map[string]string{}}
+	return context.WithValue(ctx, progressKey{}, progress), progress
+}
+
+// Progress of the job running in ctx, nil outside of a job
+func progressOf(ctx context.Context) *jobProgress {
+	progress, _ := ctx.Value(progressKey{}).(*jobProgress)
+	return progress
+}
+
+// Enter the next step of a job, unless the job was interrupted - nothing new
+// is started then
+func beginStep(ctx context.Context, step string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("Interrupted before step [%v]: %w", step, err)
+	}
+	if progress := progressOf(ctx); progress != nil {
+		progress.mu.Lock()
+		progress.step = step
+		progress.mu.Unlock()
+	}
+	return nil
+}
+
+// Resource is being waited for and was last seen in status
+func (p *jobProgress) polled(resource string, status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight[resource] = status
+}
+
+// Wait for resource is over
+func (p *jobProgress) settled(resource string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.inFlight, resource)
+}
+
+// Print the interrupted step and the resources that are still changing
+func (p *jobProgress) logInterrupted(jobName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	logf("Job [%v] interrupted in step [%v]\n", jobName, p.step)
+	if len(p.inFlight) == 0 {
+		logf("No AWS resources were in-flight\n")
+		return
+	}
+	resources := make([]string, 0, len(p.inFlight))
+	for resource := range p.inFlight {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		logf("In-flight: %v [%v]\n", resource, p.inFlight[resource])
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRestoreRDSClusterInterruptedBeforeStartSendsNothing(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := restoreRDSCluster(ctx, fake, testRestoreConfig())
+	if !isInterrupted(err) {
+		t.Fatalf("expected interrupted error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("interrupted restore sent mutating calls: %v", calls)
+	}
+}
+
+func TestRestoreRDSClusterInterruptedReportsInFlightResources(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.createFailures["test-db-restore-0"] = "creating"
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.WaitPollInterval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jobCtx, progress := withProgress(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err := restoreRDSCluster(jobCtx, fake, restoreConfig)
+
+	if !isInterrupted(err) {
+		t.Fatalf("expected interrupted error, got %v", err)
+	}
+	if isWaitFailure(err) {
+		t.Errorf("interrupted wait reported as wait failure: %v", err)
+	}
+	if progress.step != stepWaitInstances {
+		t.Errorf("interrupted step = %v, want %v", progress.step, stepWaitInstances)
+	}
+	want := map[string]string{"RDS instance [test-db-restore-0]": "creating"}
+	if !reflect.DeepEqual(progress.inFlight, want) {
+		t.Errorf("in-flight = %v, want %v", progress.inFlight, want)
+	}
+}
+
+func TestWaiterSettlesFinishedWait(t *testing.T) {
+	ctx, progress := withProgress(context.Background())
+	poll, _ := statusSequence("creating", "available")
+	w := &waiter{
+		resource:      "RDS cluster [test-db-restore]",
+		successStates: []string{"available"},
+		timing:        waitTiming{timeout: time.Minute},
+		poll:          poll,
+	}
+
+	if err := w.wait(ctx); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if len(progress.inFlight) != 0 {
+		t.Errorf("in-flight after the wait = %v, want none", progress.inFlight)
+	}
+}
+
+func TestIsInterruptedFindsCombinedCancel(t *testing.T) {
+	err := combineErrors([]error{errors.New("delete failed"), context.Canceled})
+	if !isInterrupted(err) {
+		t.Errorf("isInterrupted(%v) = false", err)
+	}
+	if isInterrupted(errors.New("delete failed")) {
+		t.Errorf("isInterrupted of a plain error = true")
+	}
+}
@@ -1,174 +1,619 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 )
 
 // TODO: test if replaced instance will be detected properly by Terraform and not try to replace it again
 // TODO: k8s cron job deploy - pulumi or helm
 // TODO: loglevel = "DEBUG"
-// TODO: delete all instances inside cluster, nevermind how many they are
 // TODO: add monitoring if it fails to generate an alert
 
+// Exit codes
+const (
+	exitFailure = 1
+	exitUsage   = 2
+	// A safety guard refused a destructive call - nothing was deleted
+	exitSafetyGuard = 3
+	// A wait timed out or a resource reached a failure status - it may be half restored
+	exitWaitFailure = 4
+	// SIGTERM or SIGINT interrupted a job - the log lists its step and in-flight resources
+	exitInterrupted = 5
+)
+
+// Logs of the run - stdout, unless stdout carries a JSON plan
+var logOutput io.Writer = os.Stdout
+
+func logf(format string, a ...interface{}) {
+	fmt.Fprintf(logOutput, format, a...)
+}
+
+func logln(a ...interface{}) {
+	fmt.Fprintln(logOutput, a...)
+}
+
+// AWS API clients of one region
+type awsClients struct {
+	rds rdsiface.RDSAPI
+	sts stsiface.STSAPI
+}
+
 func main() {
-	// Env Vars
-	awsRegion := os.Getenv("awsRegion")
+	// Cancelled on SIGTERM or SIGINT, the running step stops and no new one starts
+	ctx := interruptContext()
+	os.Exit(runCommand(ctx, os.Args[1:], os.Getenv, initAWSClients, os.Stdout))
+}
 
-	sourceRDS := os.Getenv("sourceRDS")
-	restoreRDS := os.Getenv("restoreRDS")
-	rdsSubnetGroup := os.Getenv("rdsSubnetGroup")
-	rdsSecurityGroupId := os.Getenv("rdsSecurityGroupId")
+// Run the command given by args for every job and return the exit code, jobs
+// come from the config file and the env vars read with getenv. Dry-run plans
+// are written to planOutput.
+func runCommand(ctx context.Context, args []string, getenv func(string) string, newClients func(awsRegion string, roleARN string) (*awsClients, error), planOutput io.Writer) int {
+	// Subcommand is optional, without one the tool restores as it always did
+	commandName := defaultCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		commandName, args = args[0], args[1:]
+	}
 
-	// Optional restore date and time - defaults to latest available point in time
-	restoreDate := os.Getenv("restoreDate")
-	restoreTime := os.Getenv("restoreTime")
+	cmd, ok := findCommand(commandName)
+	if !ok {
+		logf("Unknown command [%v]\n\n", commandName)
+		printUsage()
+		return exitUsage
+	}
 
-	// Optional instance type - defaults to db.t3.small
-	rdsInstanceType := os.Getenv("rdsInstanceType")
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = printUsage
+	// Optional config file with one or more jobs - env vars override its settings
+	configPath := flags.String("config", getenv("configFile"), "path to YAML or JSON config file describing restore jobs")
+	dryRun := flags.Bool("dry-run", cmd.dryRun, "run describe calls only and print the mutating calls that would be sent")
+	output := flags.String("output", outputText, "dry-run plan output format: text or json")
+	runID := flags.String("run-id", getenv("runId"), "ID of this run, tagged on restored clusters (default: current UTC timestamp)")
+	forceDelete := flags.Bool("force-delete", false, "delete restoreRDS even if it wasn't created by this tool (or set forceDelete=true)")
+	if parseErr := flags.Parse(args); parseErr != nil {
+		if parseErr == flag.ErrHelp {
+			return 0
+		}
+		return exitUsage
+	}
 
-	// Optional rds engine - defaults to aurora-mysql
-	rdsEngine := os.Getenv("rdsEngine")
+	// Env var is an alternative to the flag for the delete override
+	if forceDeleteEnv := getenv("forceDelete"); forceDeleteEnv != "" && !*forceDelete {
+		parsedForceDelete, parseErr := strconv.ParseBool(forceDeleteEnv)
+		if parseErr != nil {
+			logf("Invalid forceDelete [%v], expected true or false\n", forceDeleteEnv)
+			return exitUsage
+		}
+		*forceDelete = parsedForceDelete
+	}
+	if *runID == "" {
+		*runID = newRunID()
+	}
+	// Run ID goes into snapshot identifiers and tags
+	if !rdsIdentifierRegex.MatchString(*runID) {
+		logf("Invalid run ID [%v], expected letters, digits and single hyphens starting with a letter\n", *runID)
+		return exitUsage
+	}
 
-	// TODO: figure out how to add this to created cluster without too many conditionals
-	 // both on - db_cluster_parameter_group_name and db_parameter_group_name
+	if *output != outputText && *output != outputJSON {
+		logf("Unknown output format [%v], expected %v or %v\n", *output, outputText, outputJSON)
+		return exitUsage
+	}
+	dryRunEnabled := cmd.mutating && (*dryRun || cmd.dryRun)
 
-	// Optional parameter group - defaults to default.aurora-mysql5.7
-	//rdsParameterGroup := os.Getenv("rdsParameterGroup")
+	// Keep stdout for the JSON plan only, logs go to stderr
+	if dryRunEnabled && *output == outputJSON {
+		logOutput = os.Stderr
+		defer func() { logOutput = os.Stdout }()
+	}
 
-	// Params
-	var restoreParams = map[string]string{
-		"awsRegion": awsRegion,
-		"sourceRDS": sourceRDS,
-		"restoreRDS": restoreRDS,
-		"rdsSubnetGroup": rdsSubnetGroup,
-		"rdsSecurityGroupId": rdsSecurityGroupId,
+	// Load and validate config from config file and env vars
+	restoreConfigs, configErr := loadRestoreConfigs(*configPath, getenv)
+	if configErr != nil {
+		logf("Config Err: %v", configErr)
+		return exitFailure
 	}
 
-	// Init AWS Session and RDS Client
-	rdsClient, initErr := initRDSClient(awsRegion)
-	if initErr != nil {
-		fmt.Printf("Init Err: %v", initErr)
-		os.Exit(1)
+	for _, restoreConfig := range restoreConfigs {
+		restoreConfig.RunID = *runID
+		// Longest identifier the run ID goes into, checked before any job deletes anything
+		if snapshotIdentifier := sourceSnapshotIdentifier(restoreConfig); len(snapshotIdentifier) > 63 {
+			logf("Run ID [%v] is too long for restoreRDS [%v], snapshot identifier [%v] is over 63 chars\n", *runID, restoreConfig.RestoreRDS, snapshotIdentifier)
+			return exitUsage
+		}
+		restoreConfig.ForceDelete = *forceDelete
+		// Plans neither resume from nor leave checkpoints
+		if dryRunEnabled {
+			restoreConfig.CheckpointDir = ""
+		}
+	}
+	logf("Run ID: %v\n", *runID)
+
+	// One set of AWS clients per region and role, shared by the jobs using them
+	regionClients := map[string]*awsClients{}
+	clientsFor := func(awsRegion string, roleARN string) (*awsClients, error) {
+		clientsKey := awsRegion + " " + roleARN
+		clients, ok := regionClients[clientsKey]
+		if !ok {
+			var initErr error
+			clients, initErr = newClients(awsRegion, roleARN)
+			if initErr != nil {
+				return nil, initErr
+			}
+			regionClients[clientsKey] = clients
+		}
+		return clients, nil
 	}
 
-	// If date and time provided use it instead of last restorable time
-	if restoreDate != "" {
-		if restoreTime != "" {
-			restoreParams["restoreFromTime"] = restoreDate + "T" + restoreTime + ".000Z"
-		} else {
-			restoreParams["restoreFromTime"] = restoreDate + "T01:00:00.000Z"
+	// Run command for every job, a failed job doesn't stop the following ones
+	var failedJobs []string
+	var skippedJobs []string
+	var plans []jobPlan
+	safetyGuardTripped := false
+	waitFailed := false
+	interrupted := false
+	for _, restoreConfig := range restoreConfigs {
+		if ctx.Err() != nil {
+			skippedJobs = append(skippedJobs, restoreConfig.Name)
+			continue
+		}
+		logf("Running %v for job [%v]: [%v] -> [%v]\n", cmd.name, restoreConfig.Name, restoreConfig.SourceRDS, restoreConfig.RestoreRDS)
+
+		// Init AWS Session and clients
+		clients, initErr := clientsFor(restoreConfig.AWSRegion, restoreConfig.TargetRoleARN)
+		if initErr != nil {
+			logf("Init Err: %v", initErr)
+			return exitFailure
+		}
+		var rdsClient rdsiface.RDSAPI = clients.rds
+
+		// Source in another region or account is described and snapshotted with a client of its own
+		if restoreConfig.crossRegion() || restoreConfig.crossAccount() {
+			sourceClients, initErr := clientsFor(restoreConfig.sourceRegion(), restoreConfig.SourceRoleARN)
+			if initErr != nil {
+				logf("Init Err: %v", initErr)
+				return exitFailure
+			}
+			rdsClient = &jobRDS{RDSAPI: rdsClient, source: sourceClients.rds}
+		}
+
+		// Deny list and account guard before a command that may delete anything
+		if cmd.mutating {
+			guardErr := checkDestructiveGuards(clients.sts, restoreConfig)
+			if guardErr != nil {
+				logf("Job [%v] %v Err: %v\n", restoreConfig.Name, cmd.name, guardErr)
+				failedJobs = append(failedJobs, restoreConfig.Name)
+				var safetyErr *SafetyError
+				if errors.As(guardErr, &safetyErr) {
+					safetyGuardTripped = true
+				}
+				if dryRunEnabled {
+					plans = append(plans, jobPlan{Job: restoreConfig.Name, Error: guardErr.Error()})
+				}
+				continue
+			}
+		}
+
+		// Snapshot is shared with, and a clone has to be in, the account of the target credentials
+		if cmd.mutating && (restoreConfig.crossAccount() || restoreConfig.RestoreType == restoreTypeCopyOnWrite) {
+			accountErr := resolveTargetAccount(clients.sts, restoreConfig)
+			if accountErr != nil {
+				logf("Job [%v] %v Err: %v\n", restoreConfig.Name, cmd.name, accountErr)
+				failedJobs = append(failedJobs, restoreConfig.Name)
+				continue
+			}
+		}
+
+		// Dry-run - send describe calls only and record everything else
+		var dryRunClient *dryRunRDS
+		if dryRunEnabled {
+			dryRunClient = newJobDryRunRDS(rdsClient)
+			rdsClient = dryRunClient
+		}
+
+		jobCtx, progress := withProgress(ctx)
+		runErr := cmd.run(jobCtx, rdsClient, restoreConfig)
+		if runErr != nil {
+			logf("Job [%v] %v Err: %v\n", restoreConfig.Name, cmd.name, runErr)
+			failedJobs = append(failedJobs, restoreConfig.Name)
+			if isInterrupted(runErr) {
+				interrupted = true
+				progress.logInterrupted(restoreConfig.Name)
+			}
+			var safetyErr *SafetyError
+			if errors.As(runErr, &safetyErr) {
+				safetyGuardTripped = true
+			}
+			if isWaitFailure(runErr) {
+				waitFailed = true
+			}
+		}
+
+		if dryRunClient != nil {
+			plan := jobPlan{Job: restoreConfig.Name, Actions: dryRunClient.actions}
+			if runErr != nil {
+				plan.Error = runErr.Error()
+			}
+			plans = append(plans, plan)
 		}
-		fmt.Printf("Restore time set to %v\n", restoreParams["restoreFromTime"])
-	} else {
-		fmt.Printf("Restore time set to latest available\n")
 	}
 
-	// If instance type provided change default
-	if rdsInstanceType != "" {
-		restoreParams["rdsInstanceType"] = rdsInstanceType
-	} else {
-		restoreParams["rdsInstanceType"] = "db.t3.small"
+	if dryRunEnabled {
+		renderErr := renderPlans(planOutput, plans, *output)
+		if renderErr != nil {
+			logf("Render plan Err: %v\n", renderErr)
+			return exitFailure
+		}
 	}
 
-	// If rds engine provided change default
-	if rdsEngine != "" {
-		restoreParams["rdsEngine"] = rdsEngine
-	} else {
-		restoreParams["rdsEngine"] = "aurora-mysql"
-	}
-
-	//// If rds parameter group provided change default
-	//if rdsEngine != "" {
-		//restoreParams["rdsParameterGroup"] = rdsParameterGroup
-	//} else {
-		//restoreParams["rdsParameterGroup"] = "default.aurora-mysql5.7"
-	//}
-
-	// Check if RDS instance exists, if it doesn't, skip Instance delete step
-	rdsInstanceExists, checkRDSInstanceExistsErr := rdsInstanceExists(rdsClient, restoreParams)
-	if checkRDSInstanceExistsErr != nil {
-		fmt.Printf("Check if RDS Instance exists Err: %v", checkRDSInstanceExistsErr)
-		os.Exit(1)
-	}
-
-	// Check if RDS instance exists, if it doesn't skip Instance delete step
-	if rdsInstanceExists {
-		// Delete RDS instance
-		deleteInstanceErr := deleteRDSInstance(rdsClient, restoreParams)
-		if deleteInstanceErr != nil {
-			fmt.Printf("Delete RDS Instance Err: %v", deleteInstanceErr)
-			os.Exit(1)
+	if len(skippedJobs) > 0 {
+		logf("Jobs not started after the interrupt: %v\n", skippedJobs)
+		interrupted = true
+	}
+	if interrupted {
+		if len(failedJobs) > 0 {
+			logf("Failed jobs: %v\n", failedJobs)
+		}
+		return exitInterrupted
+	}
+	if len(failedJobs) > 0 {
+		logf("Failed jobs: %v\n", failedJobs)
+		if safetyGuardTripped {
+			return exitSafetyGuard
+		}
+		if waitFailed {
+			return exitWaitFailure
 		}
+		return exitFailure
+	}
+	return 0
+}
+
+func printUsage() {
+	logf("Usage: automated_rds_restore [command] [--config restore.yaml] [--dry-run] [--output text|json]\n\nCommands:\n")
+	for _, cmd := range commands {
+		logf("  %-20v %v\n", cmd.name, cmd.description)
+	}
+	logf("\nWithout a command, %v is run. Jobs come from the config file and/or env vars (see README).\n", defaultCommand)
+}
+
+// Delete previous restored cluster (if any) and restore source RDS into it,
+// step by step so that a rerun of the same run resumes where it stopped
+func restoreRDSCluster(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	var topology []InstanceSpec
+	var parameterGroups *parameterGroupCopy
+	instances := func() []InstanceSpec { return topology }
+
+	steps := []restoreStep{
+		{
+			// Everything that can fail is checked before anything is deleted
+			name:   stepCheck,
+			always: true,
+			run: func(ctx context.Context) error {
+				var checkErr error
+				topology, parameterGroups, checkErr = checkRestoreRDSCluster(rdsClientSess, restoreConfig)
+				if checkErr != nil {
+					return checkErr
+				}
+				// Resumed and adopted runs skip the copy step but are created with the copies too
+				useCopiedParameterGroups(restoreConfig, parameterGroups)
+				return nil
+			},
+		},
+		{
+			name: stepDeleteInstances,
+			run: func(ctx context.Context) error {
+				return deletePreviousInstances(ctx, rdsClientSess, restoreConfig)
+			},
+		},
+		{
+			name: stepDeleteCluster,
+			run: func(ctx context.Context) error {
+				return deletePreviousCluster(ctx, rdsClientSess, restoreConfig)
+			},
+			verify: verifyClusterDeleted(rdsClientSess, restoreConfig),
+		},
+		{
+			// Copies of the previous restore's parameter groups are only free once it's deleted
+			name: stepCopyParameterGroups,
+			run: func(ctx context.Context) error {
+				copyGroupsErr := copyParameterGroups(rdsClientSess, restoreConfig, parameterGroups)
+				if copyGroupsErr != nil {
+					return fmt.Errorf("Copy parameter groups Err: %w", copyGroupsErr)
+				}
+				return nil
+			},
+			verify: verifyClusterDeleted(rdsClientSess, restoreConfig),
+		},
+		{
+			// Restore RDS into a new cluster from the configured source
+			name: stepRestore,
+			run: func(ctx context.Context) error {
+				restoreErr := restoreRDS(ctx, rdsClientSess, restoreConfig)
+				if restoreErr != nil {
+					return fmt.Errorf("Restore RDS from %v Err: %w", restoreConfig.RestoreSource, restoreErr)
+				}
+				return nil
+			},
+			verify: verifyClusterRestored(rdsClientSess, restoreConfig, noInstances),
+		},
+		{
+			name: stepWaitCluster,
+			run: func(ctx context.Context) error {
+				waitClusterCreateErr := waitUntilRDSClusterCreated(ctx, rdsClientSess, restoreConfig)
+				if waitClusterCreateErr != nil {
+					return fmt.Errorf("Wait RDS Cluster create Err: %w", waitClusterCreateErr)
+				}
+				return nil
+			},
+			verify: verifyClusterRestored(rdsClientSess, restoreConfig, noInstances, "available"),
+		},
+		{
+			name: stepCreateInstances,
+			run: func(ctx context.Context) error {
+				// Serverless v1 clusters have no instances
+				if len(topology) == 0 {
+					logf("RDS cluster [%v] is %v, skipping instance create step\n", restoreConfig.RestoreRDS, restoreConfig.CapacityMode)
+					return nil
+				}
+				createRDSInstancesErr := createRDSInstances(rdsClientSess, restoreConfig, topology)
+				if createRDSInstancesErr != nil {
+					return fmt.Errorf("Create RDS Instances Err: %w", createRDSInstancesErr)
+				}
+				return nil
+			},
+			verify: verifyClusterRestored(rdsClientSess, restoreConfig, instances),
+		},
+		{
+			name: stepWaitInstances,
+			run: func(ctx context.Context) error {
+				waitInstancesErr := waitUntilRDSInstancesCreated(ctx, rdsClientSess, restoreConfig, topology)
+				if waitInstancesErr != nil {
+					return fmt.Errorf("Create RDS Instances Err: %w", waitInstancesErr)
+				}
+				return nil
+			},
+			verify: verifyClusterRestored(rdsClientSess, restoreConfig, instances, "available"),
+		},
+		{
+			// Override parameters of the attached groups, rebooting the instances for static ones
+			name: stepParameterOverrides,
+			run: func(ctx context.Context) error {
+				var rdsInstanceNames []string
+				for _, instance := range topology {
+					rdsInstanceNames = append(rdsInstanceNames, instance.Name)
+				}
+				overridesErr := applyParameterOverrides(ctx, rdsClientSess, restoreConfig, rdsInstanceNames)
+				if overridesErr != nil {
+					return fmt.Errorf("Parameter overrides Err: %w", overridesErr)
+				}
+				return nil
+			},
+		},
+	}
+	return runRestoreSteps(ctx, restoreConfig, steps, func() (string, error) {
+		return reconcileRDSCluster(rdsClientSess, restoreConfig, topology)
+	})
+}
 
-		waitDeleteInstanceErr := waitUntilRDSInstanceDeleted(rdsClient, restoreParams)
-		if waitDeleteInstanceErr != nil {
-			fmt.Printf("Wait RDS Instance delete Err : %v", waitDeleteInstanceErr)
-			os.Exit(1)
+// Instances verified for the steps before any are created
+func noInstances() []InstanceSpec {
+	return nil
+}
+
+// Check the restore can go through before anything is deleted, returning the
+// instances to create and the parameter groups to copy
+func checkRestoreRDSCluster(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) ([]InstanceSpec, *parameterGroupCopy, error) {
+	// Clone limits are checked while the previous restore still exists
+	if restoreConfig.RestoreType == restoreTypeCopyOnWrite {
+		preflightErr := checkClonePreflight(rdsClientSess, restoreConfig)
+		if preflightErr != nil {
+			return nil, nil, fmt.Errorf("Clone preflight Err: %v", preflightErr)
 		}
 	}
 
+	// Engine and instance classes are checked before anything is deleted too
+	engineErr := resolveEngine(rdsClientSess, restoreConfig)
+	if engineErr != nil {
+		return nil, nil, fmt.Errorf("Resolve RDS engine Err: %v", engineErr)
+	}
+
+	topology, topologyErr := restoreTopology(rdsClientSess, restoreConfig)
+	if topologyErr != nil {
+		return nil, nil, topologyErr
+	}
+
+	var instanceClasses []string
+	for _, instance := range topology {
+		instanceClasses = append(instanceClasses, instance.InstanceClass)
+	}
+	orderableErr := checkOrderableInstanceClasses(rdsClientSess, string(restoreConfig.Engine), restoreConfig.EngineVersion, instanceClasses)
+	if orderableErr != nil {
+		return nil, nil, orderableErr
+	}
+
+	parameterGroups, parameterGroupsErr := planParameterGroups(rdsClientSess, restoreConfig)
+	if parameterGroupsErr != nil {
+		return nil, nil, fmt.Errorf("Parameter groups Err: %v", parameterGroupsErr)
+	}
+	return topology, parameterGroups, nil
+}
+
+// Delete previous restored cluster and its instances, skipping whatever doesn't exist
+func cleanupRDSCluster(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	if stepErr := beginStep(ctx, stepDeleteInstances); stepErr != nil {
+		return stepErr
+	}
+	deleteInstancesErr := deletePreviousInstances(ctx, rdsClientSess, restoreConfig)
+	if deleteInstancesErr != nil {
+		return deleteInstancesErr
+	}
+
+	if stepErr := beginStep(ctx, stepDeleteCluster); stepErr != nil {
+		return stepErr
+	}
+	deleteClusterErr := deletePreviousCluster(ctx, rdsClientSess, restoreConfig)
+	if deleteClusterErr != nil {
+		return deleteClusterErr
+	}
+
+	// Snapshots interrupted snapshot restores kept for the cluster being created
+	pruneErr := pruneRestoreSnapshots(rdsClientSess, restoreConfig)
+	if pruneErr != nil {
+		return fmt.Errorf("Prune snapshots of earlier runs Err: %v", pruneErr)
+	}
+	return nil
+}
+
+// Delete the instances of the previous restored cluster, if any
+func deletePreviousInstances(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	// Find all instances in the cluster, if there are none, skip Instance delete step
+	rdsInstanceNames, listInstancesErr := rdsClusterInstances(rdsClientSess, restoreConfig)
+	if listInstancesErr != nil {
+		return fmt.Errorf("List RDS cluster Instances Err: %v", listInstancesErr)
+	}
+
+	if len(rdsInstanceNames) == 0 {
+		logf("RDS cluster [%v] has no instances, skipping instance delete step\n", restoreConfig.RestoreRDS)
+		return nil
+	}
+	logf("RDS instances %v already exist in RDS cluster [%v], deleting them now ...\n", rdsInstanceNames, restoreConfig.RestoreRDS)
+
+	// Delete RDS instances in parallel and wait until all of them are gone
+	deleteInstancesErr := deleteRDSInstances(ctx, rdsClientSess, restoreConfig, rdsInstanceNames)
+	if deleteInstancesErr != nil {
+		return fmt.Errorf("Delete RDS Instances Err: %w", deleteInstancesErr)
+	}
+	return nil
+}
+
+// Delete the previous restored cluster, if any, once its instances are gone.
+// A cluster already being deleted is only waited for, one still being created
+// is waited for until it can be deleted.
+func deletePreviousCluster(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
 	// Check if RDS cluster exists, if it doesn't, skip Cluster delete step
 	// Should be executed only if Instance is deleted first, as instance deletion actually deletes cluster as well
-	rdsClusterExists, checkRDSClusterExistsErr := rdsClusterExists(rdsClient, restoreParams)
-	if checkRDSClusterExistsErr != nil {
-		fmt.Printf("Check if RDS Cluster exists Err: %v", checkRDSClusterExistsErr)
-		os.Exit(1)
-	}
-
-	if rdsClusterExists {
-		// Delete RDS cluster
-		deleteClusterErr := deleteRDSCluster(rdsClient, restoreParams)
-		if deleteClusterErr != nil {
-			fmt.Printf("Delete RDS Cluster Err: %v", deleteClusterErr)
-			os.Exit(1)
-		}
+	cluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.RestoreRDS)
+	if describeErr != nil {
+		return fmt.Errorf("Check if RDS Cluster exists Err: %v", describeErr)
+	}
+
+	if cluster == nil {
+		logf("RDS cluster [%v] doesnt exist, skipping delete step\n", restoreConfig.RestoreRDS)
+		return nil
+	}
 
-		// Wait until RDS Cluster is deleted
-		waitDeleteClusterErr := waitUntilRDSClusterDeleted(rdsClient, restoreParams)
+	switch aws.StringValue(cluster.Status) {
+	case "deleting":
+		logf("RDS cluster [%v] is already being deleted, waiting for the delete to finish ...\n", restoreConfig.RestoreRDS)
+		waitDeleteClusterErr := waitUntilRDSClusterDeleted(ctx, rdsClientSess, restoreConfig)
 		if waitDeleteClusterErr != nil {
-			fmt.Printf("Wait RDS Cluster delete Err : %v", waitDeleteClusterErr)
-			os.Exit(1)
+			return fmt.Errorf("Wait RDS Cluster delete Err : %w", waitDeleteClusterErr)
+		}
+		return nil
+	case "creating":
+		deletableErr := checkDeletableRestore(rdsClientSess, restoreConfig)
+		if deletableErr != nil {
+			return fmt.Errorf("Delete RDS Cluster Err: %w", deletableErr)
+		}
+		logf("RDS cluster [%v] is still being created, waiting until it can be deleted ...\n", restoreConfig.RestoreRDS)
+		waitClusterCreateErr := waitUntilRDSClusterCreated(ctx, rdsClientSess, restoreConfig)
+		if waitClusterCreateErr != nil {
+			return fmt.Errorf("Wait RDS Cluster create Err: %w", waitClusterCreateErr)
 		}
 	}
+	logf("RDS cluster [%v] already exists, deleting it now ...\n", restoreConfig.RestoreRDS)
 
-	// Restore point in time RDS into a new cluster
-	restoreErr := restorePointInTimeRDS(rdsClient, restoreParams)
-	if restoreErr != nil {
-		fmt.Printf("Restore Point-In-Time RDS Err: %v", restoreErr)
-		os.Exit(1)
+	// Delete RDS cluster
+	deleteClusterErr := deleteRDSCluster(rdsClientSess, restoreConfig)
+	if deleteClusterErr != nil {
+		return fmt.Errorf("Delete RDS Cluster Err: %w", deleteClusterErr)
 	}
 
-	// Wait until DB instance created
-	waitClusterCreateErr := waitUntilRDSClusterCreated(rdsClient, restoreParams)
-	if waitClusterCreateErr != nil {
-		fmt.Printf("Wait RDS Cluster create Err: %v", waitClusterCreateErr)
-		os.Exit(1)
+	// Wait until RDS Cluster is deleted
+	waitDeleteClusterErr := waitUntilRDSClusterDeleted(ctx, rdsClientSess, restoreConfig)
+	if waitDeleteClusterErr != nil {
+		return fmt.Errorf("Wait RDS Cluster delete Err : %w", waitDeleteClusterErr)
 	}
 
-	// Create RDS Instance in RDS Cluster
-	createRDSInstanceErr := createRDSInstance(rdsClient, restoreParams)
-	if createRDSInstanceErr != nil {
-		fmt.Printf("Create RDS Instance Err: %v", createRDSInstanceErr)
-		os.Exit(1)
+	// Delete final snapshots beyond the retention count
+	pruneErr := pruneFinalSnapshots(rdsClientSess, restoreConfig)
+	if pruneErr != nil {
+		return fmt.Errorf("Prune final snapshots Err: %v", pruneErr)
 	}
+	return nil
+}
+
+// Delete RDS instances concurrently and wait until all of them are deleted
+func deleteRDSInstances(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, rdsInstanceNames []string) error {
+	instanceErrs := make([]error, len(rdsInstanceNames))
 
-	// Wait until DB instance created in RDS cluster
-	waitInstanceCreateErr := waitUntilRDSInstanceCreated(rdsClient, restoreParams)
-	if waitInstanceCreateErr != nil {
-		fmt.Printf("Wait RDS Instance create Err: %v", waitInstanceCreateErr)
-		os.Exit(1)
+	var wg sync.WaitGroup
+	for i, rdsInstanceName := range rdsInstanceNames {
+		wg.Add(1)
+		go func(i int, rdsInstanceName string) {
+			defer wg.Done()
+
+			status, statusErr := instanceStatus(rdsClientSess, rdsInstanceName)()
+			if statusErr != nil {
+				instanceErrs[i] = fmt.Errorf("Describe RDS Instance [%v] Err: %v", rdsInstanceName, statusErr)
+				return
+			}
+
+			switch status {
+			case "deleting":
+				// Left deleting by a previous run, only wait for it
+				logf("RDS instance [%v] is already being deleted\n", rdsInstanceName)
+			case "creating":
+				deletableErr := checkDeletableRestore(rdsClientSess, restoreConfig)
+				if deletableErr != nil {
+					instanceErrs[i] = fmt.Errorf("Delete RDS Instance [%v] Err: %w", rdsInstanceName, deletableErr)
+					return
+				}
+				logf("RDS instance [%v] is still being created, waiting until it can be deleted ...\n", rdsInstanceName)
+				waitInstanceCreateErr := waitUntilRDSInstanceCreated(ctx, rdsClientSess, restoreConfig, rdsInstanceName)
+				if waitInstanceCreateErr != nil {
+					instanceErrs[i] = fmt.Errorf("Wait RDS Instance [%v] create Err: %w", rdsInstanceName, waitInstanceCreateErr)
+					return
+				}
+				fallthrough
+			default:
+				deleteInstanceErr := deleteRDSInstance(rdsClientSess, restoreConfig, rdsInstanceName)
+				if deleteInstanceErr != nil {
+					instanceErrs[i] = fmt.Errorf("Delete RDS Instance [%v] Err: %w", rdsInstanceName, deleteInstanceErr)
+					return
+				}
+			}
+
+			waitDeleteInstanceErr := waitUntilRDSInstanceDeleted(ctx, rdsClientSess, restoreConfig, rdsInstanceName)
+			if waitDeleteInstanceErr != nil {
+				instanceErrs[i] = fmt.Errorf("Wait RDS Instance [%v] delete Err : %w", rdsInstanceName, waitDeleteInstanceErr)
+			}
+		}(i, rdsInstanceName)
 	}
+	wg.Wait()
+
+	return combineErrors(instanceErrs)
 }
 
-func initRDSClient(awsRegion string) (*rds.RDS, error) {
+func initAWSClients(awsRegion string, roleARN string) (*awsClients, error) {
 	// Create AWS session with default credentials and region (in ENV vars)
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(awsRegion)},
@@ -177,226 +622,246 @@ func initRDSClient(awsRegion string) (*rds.RDS, error) {
 		return nil, fmt.Errorf("Initialize: Cannot create AWS config sessions: %w", err)
 	}
 
-	svc := rds.New(sess)
-	fmt.Printf("AWS RDS Client initialized successfully\n")
-	return svc, nil
+	// Optional role assumed with the default credentials, refreshed before it expires
+	var configs []*aws.Config
+	if roleARN != "" {
+		configs = append(configs, &aws.Config{Credentials: stscreds.NewCredentials(sess, roleARN)})
+	}
+
+	clients := &awsClients{
+		rds: rds.New(sess, configs...),
+		sts: sts.New(sess, configs...),
+	}
+	if roleARN != "" {
+		logf("AWS RDS Client initialized successfully in [%v] as [%v]\n", awsRegion, roleARN)
+	} else {
+		logf("AWS RDS Client initialized successfully in [%v]\n", awsRegion)
+	}
+	return clients, nil
 }
 
-func restorePointInTimeRDS(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
+func restorePointInTimeRDS(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
 
-	var input *rds.RestoreDBClusterToPointInTimeInput
+	input := &rds.RestoreDBClusterToPointInTimeInput{
+		DBClusterIdentifier:       aws.String(restoreConfig.RestoreRDS), // Required
+		UseLatestRestorableTime:   aws.Bool(true),                       // Required
+		SourceDBClusterIdentifier: aws.String(restoreConfig.SourceRDS),  // Required
+		Tags:                      restoreTags(restoreConfig),           // Not required - ownership checked before delete
+	}
 
-	if restoreParams["restoreFromTime"] != "" {
-		parsedTime, parseTimeErr := time.Parse(time.RFC3339, restoreParams["restoreFromTime"])
-		if parseTimeErr != nil {
-			return fmt.Errorf("Cannot Parse Time format: %v", parseTimeErr)
-		}
+	// If restore time provided use it instead of last restorable time
+	if !restoreConfig.RestoreTime.IsZero() {
+		input.UseLatestRestorableTime = aws.Bool(false)
+		input.RestoreToTime = aws.Time(restoreConfig.RestoreTime) // Reqired if UseLatestRestorableTime is false
+	}
 
-		input = &rds.RestoreDBClusterToPointInTimeInput{
-			DBClusterIdentifier:       aws.String(restoreParams["restoreRDS"]),		// Required
-			UseLatestRestorableTime:   aws.Bool(false),								// Required
-			RestoreToTime:			   aws.Time(parsedTime),						// Reqired if UseLatestRestorableTime is false
-			DBSubnetGroupName:         aws.String(restoreParams["rdsSubnetGroup"]), // Not Required
-			SourceDBClusterIdentifier: aws.String(restoreParams["sourceRDS"]),      // Required
-			VpcSecurityGroupIds: []*string{
-				aws.String(restoreParams["rdsSecurityGroupId"]),
-			},
-			Tags: []*rds.Tag{														// Not required
-				{
-					Key:   aws.String("ManagedBy"),
-					Value: aws.String("Terraform"),
-				},
-			},
-		}
-	} else {
-		input = &rds.RestoreDBClusterToPointInTimeInput{
-			DBClusterIdentifier:       aws.String(restoreParams["restoreRDS"]),		// Required
-			UseLatestRestorableTime:   aws.Bool(true),								// Required
-			DBSubnetGroupName:         aws.String(restoreParams["rdsSubnetGroup"]), // Not Required
-			SourceDBClusterIdentifier: aws.String(restoreParams["sourceRDS"]),      // Required
-			VpcSecurityGroupIds: []*string{
-				aws.String(restoreParams["rdsSecurityGroupId"]),
-			},
-			Tags: []*rds.Tag{														// Not required
-				{
-					Key:   aws.String("ManagedBy"),
-					Value: aws.String("Terraform"),
-				},
-			},
-		}
+	// Not required - full-copy by default, copy-on-write creates an Aurora clone
+	restoreKind := "Point-In-Time restore"
+	if restoreConfig.RestoreType == restoreTypeCopyOnWrite {
+		input.RestoreType = aws.String(restoreTypeCopyOnWrite)
+		restoreKind = "copy-on-write clone"
+	}
+
+	// Not required - provisioned by default
+	input.EngineMode, input.ScalingConfiguration = serverlessV1Scaling(restoreConfig)
+	input.ServerlessV2ScalingConfiguration = serverlessV2Scaling(restoreConfig)
+
+	// Not required - default parameter group of the engine otherwise
+	if restoreConfig.ClusterParameterGroup != "" {
+		input.DBClusterParameterGroupName = aws.String(restoreConfig.ClusterParameterGroup)
 	}
 
-	fmt.Printf("Creating RDS cluster [%v] from latest Point-In-Time restore of [%v]\n", restoreParams["restoreRDS"], restoreParams["sourceRDS"])
+	// Not required - AWS uses the default subnet group and security group otherwise
+	if restoreConfig.SubnetGroup != "" {
+		input.DBSubnetGroupName = aws.String(restoreConfig.SubnetGroup)
+	}
+	if len(restoreConfig.SecurityGroupIDs) > 0 {
+		input.VpcSecurityGroupIds = aws.StringSlice(restoreConfig.SecurityGroupIDs)
+	}
+
+	logf("Creating RDS cluster [%v] from latest %v of [%v]\n", restoreConfig.RestoreRDS, restoreKind, restoreConfig.SourceRDS)
 
 	_, err := rdsClientSess.RestoreDBClusterToPointInTime(input)
-	errMsg := fmt.Sprintf("Error restoring RDS cluster [%v] -> [%v]", restoreParams["sourceRDS"], restoreParams["restoreRDS"])
+	errMsg := fmt.Sprintf("Error restoring RDS cluster [%v] -> [%v]", restoreConfig.SourceRDS, restoreConfig.RestoreRDS)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case rds.ErrCodeDBClusterAlreadyExistsFault:
-				fmt.Println(rds.ErrCodeDBClusterAlreadyExistsFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterAlreadyExistsFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeDBClusterNotFoundFault:
-				fmt.Println(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeDBClusterQuotaExceededFault:
-				fmt.Println(rds.ErrCodeDBClusterQuotaExceededFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterQuotaExceededFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeDBClusterSnapshotNotFoundFault:
-				fmt.Println(rds.ErrCodeDBClusterSnapshotNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterSnapshotNotFoundFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeDBSubnetGroupNotFoundFault:
-				fmt.Println(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInsufficientDBClusterCapacityFault:
-				fmt.Println(rds.ErrCodeInsufficientDBClusterCapacityFault, aerr.Error())
+				logln(rds.ErrCodeInsufficientDBClusterCapacityFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInsufficientStorageClusterCapacityFault:
-				fmt.Println(rds.ErrCodeInsufficientStorageClusterCapacityFault, aerr.Error())
+				logln(rds.ErrCodeInsufficientStorageClusterCapacityFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInvalidDBClusterSnapshotStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBClusterSnapshotStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBClusterSnapshotStateFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInvalidDBClusterStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInvalidDBSnapshotStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBSnapshotStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBSnapshotStateFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInvalidRestoreFault:
-				fmt.Println(rds.ErrCodeInvalidRestoreFault, aerr.Error())
+				logln(rds.ErrCodeInvalidRestoreFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInvalidSubnet:
-				fmt.Println(rds.ErrCodeInvalidSubnet, aerr.Error())
+				logln(rds.ErrCodeInvalidSubnet, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeInvalidVPCNetworkStateFault:
-				fmt.Println(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeKMSKeyNotAccessibleFault:
-				fmt.Println(rds.ErrCodeKMSKeyNotAccessibleFault, aerr.Error())
+				logln(rds.ErrCodeKMSKeyNotAccessibleFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeOptionGroupNotFoundFault:
-				fmt.Println(rds.ErrCodeOptionGroupNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeOptionGroupNotFoundFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeStorageQuotaExceededFault:
-				fmt.Println(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
+				logln(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeDomainNotFoundFault:
-				fmt.Println(rds.ErrCodeDomainNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDomainNotFoundFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			case rds.ErrCodeDBClusterParameterGroupNotFoundFault:
-				fmt.Println(rds.ErrCodeDBClusterParameterGroupNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterParameterGroupNotFoundFault, aerr.Error())
 				return fmt.Errorf(errMsg)
 			default:
-				fmt.Println(aerr.Error())
+				logln(aerr.Error())
+				return fmt.Errorf(errMsg)
 			}
 		} else {
 			// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-			fmt.Println(err.Error())
+			logln(err.Error())
 			return fmt.Errorf(errMsg)
 		}
 	}
 
-	// TODO: DEBUG - fmt.Println(result)
-	fmt.Printf("Executed RDS point-in-time restore for clusters [%v] -> [%v]\n", restoreParams["restoreRDS"], restoreParams["sourceRDS"])
+	// TODO: DEBUG - logln(result)
+	logf("Executed RDS %v for clusters [%v] -> [%v]\n", restoreKind, restoreConfig.RestoreRDS, restoreConfig.SourceRDS)
 	return nil
 }
 
 // Create RDS instance ine RDS cluster
-func createRDSInstance(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
-	rdsClusterName := restoreParams["restoreRDS"]
-	rdsInstanceName := restoreParams["restoreRDS"] + "-0" // TODO: this should be handled better
+func createRDSInstance(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, instance InstanceSpec) error {
+	rdsClusterName := restoreConfig.RestoreRDS
+	rdsInstanceName := instance.Name
 
 	input := &rds.CreateDBInstanceInput{
 		DBClusterIdentifier:  aws.String(rdsClusterName),
 		DBInstanceIdentifier: aws.String(rdsInstanceName),
-		DBInstanceClass:      aws.String(restoreParams["rdsInstanceType"]),
-		Engine:               aws.String(restoreParams["rdsEngine"]),
-		//AvailabilityZone:	  aws.String("us-east-1a"), // TODO: this doesn't help the terraform issue 
+		DBInstanceClass:      aws.String(instance.InstanceClass),
+		Engine:               aws.String(string(restoreConfig.Engine)),
+		PromotionTier:        instance.PromotionTier,
+	}
+	if instance.AvailabilityZone != "" {
+		input.AvailabilityZone = aws.String(instance.AvailabilityZone) // TODO: this doesn't help the terraform issue
+	}
+	if restoreConfig.ParameterGroup != "" {
+		input.DBParameterGroupName = aws.String(restoreConfig.ParameterGroup)
 	}
 
-	fmt.Printf("Creating RDS Instance [%v] in RDS cluster [%v]\n", rdsInstanceName, rdsClusterName)
+	logf("Creating RDS Instance [%v] in RDS cluster [%v]\n", rdsInstanceName, rdsClusterName)
 
 	_, err := rdsClientSess.CreateDBInstance(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case rds.ErrCodeDBInstanceAlreadyExistsFault:
-				fmt.Println(rds.ErrCodeDBInstanceAlreadyExistsFault, aerr.Error())
+				logln(rds.ErrCodeDBInstanceAlreadyExistsFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeInsufficientDBInstanceCapacityFault:
-				fmt.Println(rds.ErrCodeInsufficientDBInstanceCapacityFault, aerr.Error())
+				logln(rds.ErrCodeInsufficientDBInstanceCapacityFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDBParameterGroupNotFoundFault:
-				fmt.Println(rds.ErrCodeDBParameterGroupNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBParameterGroupNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDBSecurityGroupNotFoundFault:
-				fmt.Println(rds.ErrCodeDBSecurityGroupNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBSecurityGroupNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeInstanceQuotaExceededFault:
-				fmt.Println(rds.ErrCodeInstanceQuotaExceededFault, aerr.Error())
+				logln(rds.ErrCodeInstanceQuotaExceededFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeStorageQuotaExceededFault:
-				fmt.Println(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
+				logln(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDBSubnetGroupNotFoundFault:
-				fmt.Println(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs:
-				fmt.Println(rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs, aerr.Error())
+				logln(rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeInvalidDBClusterStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeInvalidSubnet:
-				fmt.Println(rds.ErrCodeInvalidSubnet, aerr.Error())
+				logln(rds.ErrCodeInvalidSubnet, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeInvalidVPCNetworkStateFault:
-				fmt.Println(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeProvisionedIopsNotAvailableInAZFault:
-				fmt.Println(rds.ErrCodeProvisionedIopsNotAvailableInAZFault, aerr.Error())
+				logln(rds.ErrCodeProvisionedIopsNotAvailableInAZFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeOptionGroupNotFoundFault:
-				fmt.Println(rds.ErrCodeOptionGroupNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeOptionGroupNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDBClusterNotFoundFault:
-				fmt.Println(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeStorageTypeNotSupportedFault:
-				fmt.Println(rds.ErrCodeStorageTypeNotSupportedFault, aerr.Error())
+				logln(rds.ErrCodeStorageTypeNotSupportedFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeAuthorizationNotFoundFault:
-				fmt.Println(rds.ErrCodeAuthorizationNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeAuthorizationNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeKMSKeyNotAccessibleFault:
-				fmt.Println(rds.ErrCodeKMSKeyNotAccessibleFault, aerr.Error())
+				logln(rds.ErrCodeKMSKeyNotAccessibleFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDomainNotFoundFault:
-				fmt.Println(rds.ErrCodeDomainNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDomainNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeBackupPolicyNotFoundFault:
-				fmt.Println(rds.ErrCodeBackupPolicyNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeBackupPolicyNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			default:
-				fmt.Println(aerr.Error())
+				logln(aerr.Error())
 				return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			}
 		} else {
 			// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-			fmt.Println(err.Error())
+			logln(err.Error())
 			return fmt.Errorf("Error creating RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 		}
 	}
 
-	// TODO: DEBUG - fmt.Println(result)
+	// TODO: DEBUG - logln(result)
 	return nil
 }
 
 // Delete RDS Intance in RDS Cluster
-func deleteRDSInstance(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
-	rdsClusterName := restoreParams["restoreRDS"]
-	rdsInstanceName := rdsClusterName + "-0" // TODO: this should be handled better
+func deleteRDSInstance(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, rdsInstanceName string) error {
+	rdsClusterName := restoreConfig.RestoreRDS
+
+	// Refuse to delete the source, a protected cluster or instances of a cluster this tool didn't create
+	deletableErr := checkDeletableRestore(rdsClientSess, restoreConfig)
+	if deletableErr != nil {
+		return deletableErr
+	}
 
 	input := &rds.DeleteDBInstanceInput{
 		DBInstanceIdentifier: aws.String(rdsInstanceName),
@@ -408,356 +873,189 @@ func deleteRDSInstance(rdsClientSess *rds.RDS, restoreParams map[string]string)
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case rds.ErrCodeDBInstanceNotFoundFault:
-				fmt.Println(rds.ErrCodeDBInstanceNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBInstanceNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeInvalidDBInstanceStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBInstanceStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBInstanceStateFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDBSnapshotAlreadyExistsFault:
-				fmt.Println(rds.ErrCodeDBSnapshotAlreadyExistsFault, aerr.Error())
+				logln(rds.ErrCodeDBSnapshotAlreadyExistsFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeSnapshotQuotaExceededFault:
-				fmt.Println(rds.ErrCodeSnapshotQuotaExceededFault, aerr.Error())
+				logln(rds.ErrCodeSnapshotQuotaExceededFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeInvalidDBClusterStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			case rds.ErrCodeDBInstanceAutomatedBackupQuotaExceededFault:
-				fmt.Println(rds.ErrCodeDBInstanceAutomatedBackupQuotaExceededFault, aerr.Error())
+				logln(rds.ErrCodeDBInstanceAutomatedBackupQuotaExceededFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			default:
-				fmt.Println(aerr.Error())
+				logln(aerr.Error())
 				return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 			}
 		} else {
 			// Print the error, cast err to awserr.Error to get the Code and
 			// Message from an error.
-			fmt.Println(err.Error())
+			logln(err.Error())
 			return fmt.Errorf("Error deleting RDS instance [%v] in RDS cluster [%v]", rdsInstanceName, rdsClusterName)
 		}
 	}
 
-	// TODO: DEBUG - fmt.Println(result)
-	fmt.Printf("Deleting RDS instance [%v] in RDS cluster [%v]\n", rdsClusterName, rdsClusterName)
+	// TODO: DEBUG - logln(result)
+	logf("Deleting RDS instance [%v] in RDS cluster [%v]\n", rdsInstanceName, rdsClusterName)
 	return nil
 }
 
 // Delete RDS Cluster
-func deleteRDSCluster(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
-	rdsClusterName := restoreParams["restoreRDS"]
+func deleteRDSCluster(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	rdsClusterName := restoreConfig.RestoreRDS
+
+	// Refuse to delete the source, a protected cluster or a cluster this tool didn't create
+	deletableErr := checkDeletableRestore(rdsClientSess, restoreConfig)
+	if deletableErr != nil {
+		return deletableErr
+	}
 
 	input := &rds.DeleteDBClusterInput{
 		DBClusterIdentifier: aws.String(rdsClusterName),
 		SkipFinalSnapshot:   aws.Bool(true),
 	}
 
+	// Optionally keep a final snapshot of the previous restore
+	if restoreConfig.FinalSnapshotIdentifier != "" {
+		finalSnapshotName := renderFinalSnapshotIdentifier(restoreConfig, time.Now())
+		input.SkipFinalSnapshot = aws.Bool(false)
+		input.FinalDBSnapshotIdentifier = aws.String(finalSnapshotName)
+		logf("Taking final snapshot [%v] of RDS cluster [%v]\n", finalSnapshotName, rdsClusterName)
+	}
+
 	_, err := rdsClientSess.DeleteDBCluster(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case rds.ErrCodeDBClusterNotFoundFault:
-				fmt.Println(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS cluster [%v]\n", rdsClusterName)
 			case rds.ErrCodeInvalidDBClusterStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS cluster [%v]\n", rdsClusterName)
 			case rds.ErrCodeDBClusterSnapshotAlreadyExistsFault:
-				fmt.Println(rds.ErrCodeDBClusterSnapshotAlreadyExistsFault, aerr.Error())
+				logln(rds.ErrCodeDBClusterSnapshotAlreadyExistsFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS cluster [%v]\n", rdsClusterName)
 			case rds.ErrCodeSnapshotQuotaExceededFault:
-				fmt.Println(rds.ErrCodeSnapshotQuotaExceededFault, aerr.Error())
+				logln(rds.ErrCodeSnapshotQuotaExceededFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS cluster [%v]\n", rdsClusterName)
 			case rds.ErrCodeInvalidDBClusterSnapshotStateFault:
-				fmt.Println(rds.ErrCodeInvalidDBClusterSnapshotStateFault, aerr.Error())
+				logln(rds.ErrCodeInvalidDBClusterSnapshotStateFault, aerr.Error())
 				return fmt.Errorf("Error deleting RDS cluster [%v]\n", rdsClusterName)
 			default:
-				fmt.Println(aerr.Error())
+				logln(aerr.Error())
 				return fmt.Errorf("Error deleting RDS cluster [%v]\n", rdsClusterName)
 			}
 		} else {
 			// Print the error, cast err to awserr.Error to get the Code and
 			// Message from an error.
-			fmt.Println(err.Error())
+			logln(err.Error())
 			return fmt.Errorf("Error deleting RDS cluster [%v]\n", rdsClusterName)
 		}
 	}
 
-	// TODO: DEBUG - fmt.Println(result)
-	fmt.Printf("Deleting RDS cluster [%v]\n", rdsClusterName)
+	// TODO: DEBUG - logln(result)
+	logf("Deleting RDS cluster [%v]\n", rdsClusterName)
 	return nil
 }
 
-// Check if RDS instance exists
-func rdsInstanceExists(rdsClientSess *rds.RDS, restoreParams map[string]string) (dbExists bool, checkDBExistsErr error) {
-	rdsClusterName := restoreParams["restoreRDS"]
-	rdsInstanceName := rdsClusterName + "-0"
-
-	input := &rds.DescribeDBInstancesInput{
-		DBInstanceIdentifier: aws.String(rdsInstanceName),
+// List the instances (DBClusterMembers) of the restoreRDS cluster - none if the cluster doesn't exist
+func rdsClusterInstances(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) ([]string, error) {
+	cluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.RestoreRDS)
+	if describeErr != nil {
+		return nil, describeErr
 	}
-
-	_, err := rdsClientSess.DescribeDBInstances(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeDBInstanceNotFoundFault:
-				// TODO: remove if not needed
-				//fmt.Println(rds.ErrCodeDBInstanceNotFoundFault, aerr.Error())
-				fmt.Printf("RDS instance [%v] doesnt exist, skipping delete step\n", rdsInstanceName)
-				return false, nil
-			default:
-				fmt.Println(aerr.Error())
-				return false, fmt.Errorf("Describe Err on cluster [%v]", rdsClusterName)
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-			fmt.Println(err.Error())
-			return false, fmt.Errorf("Describe Err on cluster [%v]", rdsClusterName)
-		}
+	if cluster == nil {
+		return nil, nil
 	}
 
-	// TODO: DEBUG - fmt.Println(result)
-	fmt.Printf("RDS instance [%v] already exists, deleting it now ...\n", rdsInstanceName)
-	return true, nil
-}
-
-// Check if RDS cluster exists
-func rdsClusterExists(rdsClientSess *rds.RDS, restoreParams map[string]string) (dbExists bool, checkDBExistsErr error) {
-	rdsClusterName := restoreParams["restoreRDS"]
-
-	input := &rds.DescribeDBClustersInput{
-		DBClusterIdentifier: aws.String(rdsClusterName),
+	var rdsInstanceNames []string
+	for _, member := range cluster.DBClusterMembers {
+		rdsInstanceNames = append(rdsInstanceNames, aws.StringValue(member.DBInstanceIdentifier))
 	}
-
-	_, err := rdsClientSess.DescribeDBClusters(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeDBClusterNotFoundFault:
-				// TODO: remove if not needed
-				//fmt.Println(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
-				fmt.Printf("RDS cluster [%v] doesnt exist, skipping delete step\n", rdsClusterName)
-				return false, nil
-			default:
-				fmt.Println(aerr.Error())
-				return false, fmt.Errorf("Describe Err on cluster [%v]", rdsClusterName)
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-			fmt.Println(err.Error())
-			return false, fmt.Errorf("Describe Err on cluster [%v]", rdsClusterName)
-		}
-	}
-
-	// TODO: DEBUG - fmt.Println(result)
-	fmt.Printf("RDS cluster [%v] already exists, deleting it now ...\n", rdsClusterName)
-	return true, nil
+	return rdsInstanceNames, nil
 }
 
 // Wait until RDS Cluster is fully deleted
-func waitUntilRDSClusterDeleted(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
-
-	rdsClusterName := restoreParams["restoreRDS"]
-
-	input := &rds.DescribeDBClustersInput{
-		DBClusterIdentifier: aws.String(rdsClusterName),
+func waitUntilRDSClusterDeleted(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	rdsClusterName := restoreConfig.RestoreRDS
+
+	logf("Wait until RDS cluster [%v] is fully deleted...\n", rdsClusterName)
+	w := &waiter{
+		resource:      fmt.Sprintf("RDS cluster [%v]", rdsClusterName),
+		successStates: []string{statusNotFound},
+		failureStates: clusterFailureStates,
+		timing:        restoreConfig.waitTiming(defaultWaitTimeout),
+		poll:          clusterStatus(rdsClientSess, rdsClusterName),
 	}
-
-	// Check if Cluster exists
-	_, err := rdsClientSess.DescribeDBClusters(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == rds.ErrCodeDBClusterNotFoundFault {
-				fmt.Println(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
-				return fmt.Errorf("Wait RDS cluster deletion err %v", err)
-			} else {
-				// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-				fmt.Println(err.Error())
-				return fmt.Errorf("Wait RDS cluster deletion err %v", err)
-			}
-		}
+	if waitErr := w.wait(ctx); waitErr != nil {
+		return waitErr
 	}
 
-	// TODO: DEBUG - fmt.Println(result)
-	fmt.Printf("Wait until RDS cluster [%v] is fully deleted...\n", rdsClusterName)
-
-	start := time.Now()
-
-	maxWaitAttempts := 120
-
-	// Check until deleted
-	for waitAttempt := 0; waitAttempt < maxWaitAttempts; waitAttempt++ {
-		elapsedTime := time.Since(start).Seconds()
-
-		if waitAttempt > 0 {
-			formattedTime := strings.Split(fmt.Sprintf("%6v", elapsedTime), ".")
-			fmt.Printf("Cluster deletion elapsed time: %vs\n", formattedTime[0])
-		}
-
-		resp, err := rdsClientSess.DescribeDBClusters(input)
-		if err != nil {
-			if aerr, ok := err.(awserr.Error); ok {
-				if aerr.Code() == rds.ErrCodeDBClusterNotFoundFault {
-					fmt.Println("RDS Cluster deleted successfully")
-					return nil
-				} else {
-					// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-					fmt.Println(err.Error())
-					return fmt.Errorf("Wait RDS cluster deletion err %v", err)
-				}
-			}
-		}
-
-		fmt.Printf("Cluster status: [%s]\n", *resp.DBClusters[0].Status)
-		if *resp.DBClusters[0].Status == "terminated" {
-			fmt.Printf("RDS cluster [%v] deleted successfully\n", rdsClusterName)
-			return nil
-		}
-		time.Sleep(30 * time.Second)
-	}
-
-	// Timeout Err
-	return fmt.Errorf("RDS Cluster [%v] could not be deleted, exceed max wait attemps\n", rdsClusterName)
+	logf("RDS cluster [%v] deleted successfully\n", rdsClusterName)
+	return nil
 }
 
 // Wait until RDS Cluster is fully created
-func waitUntilRDSClusterCreated(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
-	rdsClusterName := restoreParams["restoreRDS"]
-
-	maxWaitAttempts := 120
-
-	input := &rds.DescribeDBClustersInput{
-		DBClusterIdentifier: aws.String(rdsClusterName),
+func waitUntilRDSClusterCreated(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	rdsClusterName := restoreConfig.RestoreRDS
+
+	logf("Wait until RDS cluster [%v] is fully created ...\n", rdsClusterName)
+	w := &waiter{
+		resource:      fmt.Sprintf("RDS cluster [%v]", rdsClusterName),
+		successStates: []string{"available"},
+		failureStates: creationFailureStates(clusterFailureStates),
+		timing:        restoreConfig.waitTiming(defaultWaitTimeout),
+		poll:          clusterStatus(rdsClientSess, rdsClusterName),
 	}
-
-	fmt.Printf("Wait until RDS cluster [%v] is fully created ...\n", rdsClusterName)
-
-	start := time.Now()
-
-	// Check until created
-	for waitAttempt := 0; waitAttempt < maxWaitAttempts; waitAttempt++ {
-		elapsedTime := time.Since(start)
-		if waitAttempt > 0 {
-			formattedTime := strings.Split(fmt.Sprintf("%6v", elapsedTime), ".")
-			fmt.Printf("Cluster creation elapsed time: %vs\n", formattedTime[0])
-		}
-
-		resp, err := rdsClientSess.DescribeDBClusters(input)
-
-		if err != nil {
-			return fmt.Errorf("Wait RDS cluster creation err %v", err)
-		}
-
-		fmt.Printf("Cluster status: [%s]\n", *resp.DBClusters[0].Status)
-		if *resp.DBClusters[0].Status == "available" {
-			fmt.Printf("RDS cluster [%v] created successfully\n", rdsClusterName)
-			return nil
-		}
-		time.Sleep(30 * time.Second)
+	if waitErr := w.wait(ctx); waitErr != nil {
+		return waitErr
 	}
-	return fmt.Errorf("Aurora Cluster [%v] is not ready, exceed max wait attemps\n", rdsClusterName)
-}
 
-// Wait until RDS instance in RDS Cluster is fully created
-func waitUntilRDSInstanceDeleted(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
-	rdsClusterName := restoreParams["restoreRDS"]
-	rdsInstanceName := rdsClusterName + "-0" // TODO: this should be handled better
+	logf("RDS cluster [%v] created successfully\n", rdsClusterName)
+	return nil
+}
 
-	input := &rds.DescribeDBInstancesInput{
-		DBInstanceIdentifier: aws.String(rdsInstanceName),
+// Wait until RDS instance in RDS Cluster is fully deleted
+func waitUntilRDSInstanceDeleted(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, rdsInstanceName string) error {
+	logf("Wait until RDS instance [%v] of [%v] is fully deleted...\n", rdsInstanceName, restoreConfig.RestoreRDS)
+	w := &waiter{
+		resource:      fmt.Sprintf("RDS instance [%v]", rdsInstanceName),
+		successStates: []string{statusNotFound},
+		failureStates: instanceFailureStates,
+		timing:        restoreConfig.waitTiming(defaultWaitTimeout),
+		poll:          instanceStatus(rdsClientSess, rdsInstanceName),
 	}
-
-	// Check if RDS Instance exists
-	_, describeErr := rdsClientSess.DescribeDBInstances(input)
-	if describeErr != nil {
-		if aerr, ok := describeErr.(awserr.Error); ok {
-			if aerr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
-				fmt.Println(rds.ErrCodeDBInstanceNotFoundFault, aerr.Error())
-				return fmt.Errorf("Wait RDS instance delete err %v", describeErr)
-			} else {
-				// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-				fmt.Println(describeErr.Error())
-				return fmt.Errorf("Wait RDS instance delete err %v", describeErr)
-			}
-		}
+	if waitErr := w.wait(ctx); waitErr != nil {
+		return waitErr
 	}
 
-	start := time.Now()
-	maxWaitAttempts := 120
-
-	fmt.Printf("Wait until RDS instance [%v] in cluster [%v] is fully deleted...\n", rdsInstanceName, rdsClusterName)
-
-	// Check until deleted
-	for waitAttempt := 0; waitAttempt < maxWaitAttempts; waitAttempt++ {
-		elapsedTime := time.Since(start)
-		if waitAttempt > 0 {
-			formattedTime := strings.Split(fmt.Sprintf("%6v", elapsedTime), ".")
-			fmt.Printf("Instance deletion elapsed time: %vs\n", formattedTime[0])
-		}
-
-		resp, describeErr := rdsClientSess.DescribeDBInstances(input)
-
-		if describeErr != nil {
-			if aerr, ok := describeErr.(awserr.Error); ok {
-				if aerr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
-					fmt.Println("RDS Instance deleted successfully")
-					return nil
-				} else {
-					// Print the error, cast err to awserr.Error to get the Code and Message from an error.
-					fmt.Println(describeErr.Error())
-					return fmt.Errorf("Wait RDS instance delete err %v", describeErr)
-				}
-			}
-		}
-
-		fmt.Printf("Instance status: [%s]\n", *resp.DBInstances[0].DBInstanceStatus)
-		// TODO: do i need to loop through this if more instances need to be deleted ? 
-		if *resp.DBInstances[0].DBInstanceStatus== "terminated" {
-			fmt.Printf("RDS instance [%v] deleted successfully\n", rdsClusterName)
-			return nil
-		}
-		time.Sleep(30 * time.Second)
-
-	}
-	fmt.Printf("RDS instance [%v] deleted successfully in RDS cluster [%v]\n", rdsInstanceName, rdsClusterName)
+	logf("RDS instance [%v] deleted successfully\n", rdsInstanceName)
 	return nil
 }
 
 // Wait until RDS instance in RDS Cluster is fully created
-func waitUntilRDSInstanceCreated(rdsClientSess *rds.RDS, restoreParams map[string]string) error {
-	rdsClusterName := restoreParams["restoreRDS"]
-	rdsInstanceName := rdsClusterName + "-0" // TODO: this should be handled better
-
-	input := &rds.DescribeDBInstancesInput{
-		DBInstanceIdentifier: aws.String(rdsInstanceName),
+func waitUntilRDSInstanceCreated(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, rdsInstanceName string) error {
+	logf("Wait until RDS instance [%v] of [%v] is fully created ...\n", rdsInstanceName, restoreConfig.RestoreRDS)
+	w := &waiter{
+		resource:      fmt.Sprintf("RDS instance [%v]", rdsInstanceName),
+		successStates: []string{"available"},
+		failureStates: creationFailureStates(instanceFailureStates),
+		timing:        restoreConfig.waitTiming(defaultWaitTimeout),
+		poll:          instanceStatus(rdsClientSess, rdsInstanceName),
 	}
-
-	start := time.Now()
-	maxWaitAttempts := 120
-
-	fmt.Printf("Wait until RDS instance [%v] in cluster [%v] is fully created ...\n", rdsInstanceName, rdsClusterName)
-
-	for waitAttempt := 0; waitAttempt < maxWaitAttempts; waitAttempt++ {
-		elapsedTime := time.Since(start)
-		if waitAttempt > 0 {
-			formattedTime := strings.Split(fmt.Sprintf("%6v", elapsedTime), ".")
-			fmt.Printf("Instance creation elapsed time: %vs\n", formattedTime[0])
-		}
-
-		resp, err := rdsClientSess.DescribeDBInstances(input)
-
-		if err != nil {
-			return fmt.Errorf("Wait RDS instance create err %v", err)
-		}
-
-		fmt.Printf("Instance status: [%s]\n", *resp.DBInstances[0].DBInstanceStatus)
-		if *resp.DBInstances[0].DBInstanceStatus== "available" {
-			fmt.Printf("RDS instance [%v] created successfully\n", rdsClusterName)
-			return nil
-		}
-		time.Sleep(30 * time.Second)
+	if waitErr := w.wait(ctx); waitErr != nil {
+		return waitErr
 	}
-	fmt.Printf("RDS instance [%v] created successfully in RDS cluster [%v]\n", rdsInstanceName, rdsClusterName)
+
+	logf("RDS instance [%v] created successfully\n", rdsInstanceName)
 	return nil
 }
 
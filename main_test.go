@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func init() {
+	// Don't sleep between status polls against the fake
+	waitPollInterval = 0
+	waitMaxPollInterval = 0
+}
+
+func testRestoreConfig() *RestoreConfig {
+	return &RestoreConfig{
+		AWSRegion:        "us-east-1",
+		SourceRDS:        "test-db",
+		RestoreRDS:       "test-db-restore",
+		SubnetGroup:      "rds-private-subnet",
+		SecurityGroupIDs: []string{"sg-03254e409e0bd8218"},
+		RestoreSource:    sourcePointInTime,
+		RestoreType:      restoreTypeFullCopy,
+		CapacityMode:     capacityProvisioned,
+		InstanceType:     "db.t3.small",
+		Engine:           EngineAuroraMySQL,
+		RunID:            "test-run",
+	}
+}
+
+// Mutating calls only, in the order they were sent
+func mutatingCalls(f *fakeRDS) []string {
+	calls := []string{}
+	for _, c := range f.calls {
+		if !strings.HasPrefix(c, "Describe") && !strings.HasPrefix(c, "List") {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func TestRestoreRDSClusterFreshTarget(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	want := []string{"RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if got := mutatingCalls(fake); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	if status := aws.StringValue(fake.clusters["test-db-restore"].cluster.Status); status != "available" {
+		t.Errorf("restored cluster status = %v, want available", status)
+	}
+	if status := aws.StringValue(fake.instances["test-db-restore-0"].instance.DBInstanceStatus); status != "available" {
+		t.Errorf("restored instance status = %v, want available", status)
+	}
+	if !aws.BoolValue(fake.restoreInputs[0].UseLatestRestorableTime) {
+		t.Errorf("expected restore from latest restorable time")
+	}
+}
+
+func TestRestoreRDSClusterReplacesPreviousRestore(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	want := []string{"DeleteDBInstance", "DeleteDBCluster", "RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if got := mutatingCalls(fake); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	if status := aws.StringValue(fake.instances["test-db-restore-0"].instance.DBInstanceStatus); status != "available" {
+		t.Errorf("restored instance status = %v, want available", status)
+	}
+}
+
+func TestRestoreRDSClusterOrphanCluster(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	want := []string{"DeleteDBCluster", "RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if got := mutatingCalls(fake); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+}
+
+func TestRestoreRDSClusterPointInTime(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RestoreTime = time.Date(2021, 8, 21, 21, 0, 0, 0, time.UTC)
+
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	input := fake.restoreInputs[0]
+	if aws.BoolValue(input.UseLatestRestorableTime) {
+		t.Errorf("expected UseLatestRestorableTime to be false")
+	}
+	want := time.Date(2021, 8, 21, 21, 0, 0, 0, time.UTC)
+	if !aws.TimeValue(input.RestoreToTime).Equal(want) {
+		t.Errorf("RestoreToTime = %v, want %v", aws.TimeValue(input.RestoreToTime), want)
+	}
+}
+
+func TestRestoreRDSClusterMissingSource(t *testing.T) {
+	fake := newFakeRDS()
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err == nil {
+		t.Fatalf("expected error when source cluster doesn't exist")
+	}
+	if n := fake.callCount("CreateDBInstance"); n != 0 {
+		t.Errorf("CreateDBInstance called %d times after failed restore", n)
+	}
+}
+
+func TestRestoreRDSClusterFailsOnUnknownRestoreError(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.restoreErr = awserr.New("ThrottlingException", "Rate exceeded", nil)
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err == nil {
+		t.Fatalf("expected error when the restore call fails")
+	}
+	if n := fake.callCount("CreateDBInstance"); n != 0 {
+		t.Errorf("CreateDBInstance called %d times after failed restore", n)
+	}
+}
+
+func TestCleanupDeletesAllClusterInstances(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+	fake.addInstance("test-db-restore", "test-db-restore-reader", "available")
+	fake.addInstance("test-db-restore", "manually-added-reader", "available")
+
+	if err := cleanupRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+
+	if n := fake.callCount("DeleteDBInstance"); n != 3 {
+		t.Errorf("DeleteDBInstance called %d times, want 3", n)
+	}
+	if len(fake.instances) != 0 {
+		t.Errorf("instances left after cleanup: %v", len(fake.instances))
+	}
+	if _, ok := fake.clusters["test-db-restore"]; ok {
+		t.Errorf("cluster still exists after cleanup")
+	}
+}
+
+// Env of a single restore job of test-db into test-db-restore
+func testEnv(overrides map[string]string) func(string) string {
+	env := map[string]string{
+		"awsRegion":          "us-east-1",
+		"sourceRDS":          "test-db",
+		"restoreRDS":         "test-db-restore",
+		"rdsSubnetGroup":     "rds-private-subnet",
+		"rdsSecurityGroupId": "sg-03254e409e0bd8218",
+		"runId":              "test-run",
+	}
+	for key, value := range overrides {
+		env[key] = value
+	}
+	return func(key string) string { return env[key] }
+}
+
+func fakeClients(fake *fakeRDS) func(string, string) (*awsClients, error) {
+	return func(awsRegion string, roleARN string) (*awsClients, error) {
+		return &awsClients{rds: fake, sts: &fakeSTS{account: "123456789012"}}, nil
+	}
+}
+
+func TestRunCommandExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		setup    func(fake *fakeRDS)
+		wantExit int
+	}{
+		{
+			name:     "restore",
+			setup:    func(fake *fakeRDS) { fake.addRestoredCluster("test-db-restore", "test-db") },
+			wantExit: 0,
+		},
+		{
+			name:     "restore command",
+			args:     []string{"restore"},
+			wantExit: 0,
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"restorre"},
+			wantExit: exitUsage,
+		},
+		{
+			name:     "unknown output",
+			args:     []string{"plan", "--output", "yaml"},
+			wantExit: exitUsage,
+		},
+		{
+			name:     "invalid run ID",
+			args:     []string{"--run-id", "nightly_1"},
+			wantExit: exitUsage,
+		},
+		{
+			name:     "run ID with double hyphen",
+			env:      map[string]string{"runId": "a--b"},
+			wantExit: exitUsage,
+		},
+		{
+			name:     "run ID too long for snapshot identifiers",
+			args:     []string{"--run-id", "build-" + strings.Repeat("1", 50)},
+			wantExit: exitUsage,
+		},
+		{
+			name:     "invalid config",
+			env:      map[string]string{"restoreRDS": ""},
+			wantExit: exitFailure,
+		},
+		{
+			name:     "missing source",
+			env:      map[string]string{"sourceRDS": "missing-db"},
+			wantExit: exitFailure,
+		},
+		{
+			name:     "foreign target",
+			setup:    func(fake *fakeRDS) { fake.addCluster("test-db-restore", "available") },
+			wantExit: exitSafetyGuard,
+		},
+		{
+			name:     "protected target",
+			env:      map[string]string{"rdsProtectedIdentifiers": "^test-db-restore$"},
+			wantExit: exitSafetyGuard,
+		},
+		{
+			name:     "instance create failure",
+			setup:    func(fake *fakeRDS) { fake.createFailures["test-db-restore-0"] = "incompatible-parameters" },
+			wantExit: exitWaitFailure,
+		},
+	}
+
+	for _, test := range tests {
+		fake := newFakeRDS()
+		fake.addCluster("test-db", "available")
+		if test.setup != nil {
+			test.setup(fake)
+		}
+
+		exitCode := runCommand(context.Background(), test.args, testEnv(test.env), fakeClients(fake), io.Discard)
+		if exitCode != test.wantExit {
+			t.Errorf("%v: exit code = %d, want %d", test.name, exitCode, test.wantExit)
+		}
+	}
+}
+
+func TestRunCommandRestoresFromEnv(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	env := testEnv(map[string]string{"rdsInstanceType": "db.r5.large", "runId": "env-run"})
+	if exitCode := runCommand(context.Background(), nil, env, fakeClients(fake), io.Discard); exitCode != 0 {
+		t.Fatalf("exit code = %d, want 0", exitCode)
+	}
+
+	want := []string{"RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if got := mutatingCalls(fake); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	if class := aws.StringValue(fake.createInstanceInputs[0].DBInstanceClass); class != "db.r5.large" {
+		t.Errorf("instance class = %v, want db.r5.large", class)
+	}
+	if !createdByRun(fake.clusters["test-db-restore"].cluster.TagList, &RestoreConfig{RunID: "env-run"}) {
+		t.Errorf("restored cluster not tagged with run ID env-run: %v", fake.clusters["test-db-restore"].cluster.TagList)
+	}
+}
+
+func TestRunCommandSkipsJobsAfterInterrupt(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if exitCode := runCommand(ctx, nil, testEnv(nil), fakeClients(fake), io.Discard); exitCode != exitInterrupted {
+		t.Errorf("exit code = %d, want %d", exitCode, exitInterrupted)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("interrupted run sent mutating calls: %v", calls)
+	}
+}
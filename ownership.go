@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Tags marking a cluster as created by this tool - the delete path refuses
+// to touch a cluster without them
+const (
+	toolName = "automated_rds_restore"
+
+	ownerTagKey  = "automated-rds-restore:managed-by"
+	sourceTagKey = "automated-rds-restore:source"
+	runIDTagKey  = "automated-rds-restore:run-id"
+	// Only on snapshots a restore creates on its way, restoreRDS they were made for
+	targetTagKey = "automated-rds-restore:target"
+)
+
+// SafetyError is returned when a destructive call is refused by a safety guard
+type SafetyError struct {
+	Reason string
+}
+
+func (e *SafetyError) Error() string {
+	return fmt.Sprintf("Refusing to delete: %v", e.Reason)
+}
+
+// Generate a run ID for tagging when none is provided, starting with a letter
+// like every run ID
+func newRunID() string {
+	return time.Now().UTC().Format("r20060102T150405Z")
+}
+
+// Tags added to the restored cluster
+func restoreTags(restoreConfig *RestoreConfig) []*rds.Tag {
+	return []*rds.Tag{
+		{
+			Key:   aws.String("ManagedBy"),
+			Value: aws.String("Terraform"),
+		},
+		{
+			Key:   aws.String(ownerTagKey),
+			Value: aws.String(toolName),
+		},
+		{
+			Key:   aws.String(sourceTagKey),
+			Value: aws.String(restoreConfig.SourceRDS),
+		},
+		{
+			Key:   aws.String(runIDTagKey),
+			Value: aws.String(restoreConfig.RunID),
+		},
+	}
+}
+
+// Whether a resource was tagged by this very run of the tool
+func createdByRun(tagList []*rds.Tag, restoreConfig *RestoreConfig) bool {
+	tags := map[string]string{}
+	for _, tag := range tagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags[ownerTagKey] == toolName && tags[runIDTagKey] == restoreConfig.RunID
+}
+
+// Run that restored a resource of this tool from sourceRDS, empty if it
+// wasn't restored by this tool or from another source
+func restoreRunID(tagList []*rds.Tag, restoreConfig *RestoreConfig) string {
+	tags := map[string]string{}
+	for _, tag := range tagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	if tags[ownerTagKey] != toolName || tags[sourceTagKey] != restoreConfig.SourceRDS {
+		return ""
+	}
+	return tags[runIDTagKey]
+}
+
+// Make sure the restoreRDS cluster was created by this tool from sourceRDS
+// before anything in it is deleted, unless ForceDelete is set
+func checkRestoreOwnership(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	rdsClusterName := restoreConfig.RestoreRDS
+
+	cluster, describeErr := describeRDSCluster(rdsClientSess, rdsClusterName)
+	if describeErr != nil {
+		return describeErr
+	}
+
+	resource := fmt.Sprintf("RDS cluster [%v]", rdsClusterName)
+	if cluster == nil {
+		return ownershipProblem(restoreConfig, resource+" not found, cannot verify ownership of its instances")
+	}
+	return checkOwnershipTags(restoreConfig, resource, cluster.TagList)
+}
+
+// Guards of every delete in the restoreRDS cluster - not the source, not
+// protected and created by this tool from sourceRDS
+func checkDeletableRestore(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	protectedErr := checkProtectedTarget(restoreConfig)
+	if protectedErr != nil {
+		return protectedErr
+	}
+	return checkRestoreOwnership(rdsClientSess, restoreConfig)
+}
+
+// Verify the ownership tags of restoreRDS, the cluster or the standalone instance
+func checkOwnershipTags(restoreConfig *RestoreConfig, resource string, tagList []*rds.Tag) error {
+	tags := map[string]string{}
+	for _, tag := range tagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	switch {
+	case tags[ownerTagKey] != toolName:
+		return ownershipProblem(restoreConfig, fmt.Sprintf("%v is not tagged %v=%v, it was not created by this tool", resource, ownerTagKey, toolName))
+	case tags[sourceTagKey] != restoreConfig.SourceRDS:
+		return ownershipProblem(restoreConfig, fmt.Sprintf("%v was restored from [%v], not from sourceRDS [%v]", resource, tags[sourceTagKey], restoreConfig.SourceRDS))
+	case tags[runIDTagKey] == "":
+		return ownershipProblem(restoreConfig, fmt.Sprintf("%v has no %v tag", resource, runIDTagKey))
+	}
+	logf("%v ownership verified - created by run [%v] from [%v]\n", resource, tags[runIDTagKey], tags[sourceTagKey])
+	return nil
+}
+
+func ownershipProblem(restoreConfig *RestoreConfig, problem string) error {
+	if restoreConfig.ForceDelete {
+		logf("WARNING: %v - deleting anyway, forceDelete is set\n", problem)
+		return nil
+	}
+	return &SafetyError{Reason: problem + " (set forceDelete to override)"}
+}
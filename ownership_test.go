@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func TestRestoredClusterIsTaggedWithOwnership(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	tags := map[string]string{}
+	for _, tag := range fake.clusters["test-db-restore"].cluster.TagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	if tags[ownerTagKey] != toolName || tags[sourceTagKey] != "test-db" || tags[runIDTagKey] != "test-run" {
+		t.Errorf("unexpected ownership tags: %v", tags)
+	}
+}
+
+func TestCleanupRefusesForeignClusters(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []*rds.Tag
+	}{
+		{"untagged", nil},
+		{"other source", restoreTags(&RestoreConfig{SourceRDS: "other-db", RunID: "previous-run"})},
+		{"no run id", restoreTags(&RestoreConfig{SourceRDS: "test-db"})},
+	}
+
+	for _, test := range tests {
+		fake := newFakeRDS()
+		fake.addCluster("test-db-restore", "available")
+		fake.clusters["test-db-restore"].cluster.TagList = test.tags
+		fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+		err := cleanupRDSCluster(context.Background(), fake, testRestoreConfig())
+
+		var safetyErr *SafetyError
+		if !errors.As(err, &safetyErr) {
+			t.Errorf("%v: expected SafetyError, got %v", test.name, err)
+		}
+		if calls := mutatingCalls(fake); len(calls) != 0 {
+			t.Errorf("%v: sent mutating calls %v", test.name, calls)
+		}
+	}
+}
+
+func TestCleanupForceDeleteOverridesOwnership(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db-restore", "available")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.ForceDelete = true
+
+	if err := cleanupRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("cleanup with ForceDelete: %v", err)
+	}
+	if _, ok := fake.clusters["test-db-restore"]; ok {
+		t.Errorf("cluster still exists after forced cleanup")
+	}
+}
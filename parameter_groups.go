@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// ModifyDB(Cluster)ParameterGroup accepts at most 20 parameters per call
+const maxParametersPerModify = 20
+
+// Parameter groups of sourceRDS copied into the groups of restoreRDS
+type parameterGroupCopy struct {
+	sourceClusterGroup  string
+	sourceInstanceGroup string
+}
+
+func (c *RestoreConfig) parameterGroupProblems() []string {
+	var problems []string
+
+	if c.CopySourceParameterGroups {
+		if c.ClusterParameterGroup != "" || c.ParameterGroup != "" {
+			problems = append(problems, "rdsClusterParameterGroup and rdsParameterGroup can't be set together with copySourceParameterGroups")
+		}
+		if c.crossRegion() || c.crossAccount() {
+			problems = append(problems, "copySourceParameterGroups can't copy parameter groups from another region or account")
+		}
+	} else {
+		// Without copies the overrides go to the groups restoreRDS is created with, default groups can't be modified
+		if len(c.ClusterParameterOverrides) > 0 && c.ClusterParameterGroup == "" {
+			problems = append(problems, "rdsClusterParameterOverrides need rdsClusterParameterGroup or copySourceParameterGroups")
+		}
+		if len(c.ParameterOverrides) > 0 && c.ParameterGroup == "" {
+			problems = append(problems, "rdsParameterOverrides need rdsParameterGroup or copySourceParameterGroups")
+		}
+	}
+
+	if c.CapacityMode == capacityServerlessV1 && (c.ParameterGroup != "" || len(c.ParameterOverrides) > 0) {
+		problems = append(problems, "serverless-v1 clusters have no instances, rdsParameterGroup and rdsParameterOverrides can't be used with it")
+	}
+	return problems
+}
+
+// Identifiers of the parameter groups copied from sourceRDS for restoreRDS
+func copiedClusterParameterGroupName(restoreConfig *RestoreConfig) string {
+	return strings.ToLower(fmt.Sprintf("%v-cluster-params", restoreConfig.RestoreRDS))
+}
+
+func copiedParameterGroupName(restoreConfig *RestoreConfig) string {
+	return strings.ToLower(fmt.Sprintf("%v-params", restoreConfig.RestoreRDS))
+}
+
+// Check the parameter groups restoreRDS is created with exist, match its engine
+// version and have the overridden parameters, before anything is deleted. With
+// copySourceParameterGroups it returns the groups of sourceRDS to copy once the
+// previous restore is gone.
+func planParameterGroups(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (*parameterGroupCopy, error) {
+	if !restoreConfig.CopySourceParameterGroups {
+		if restoreConfig.ClusterParameterGroup != "" {
+			family, err := clusterParameterGroupFamily(rdsClientSess, restoreConfig.ClusterParameterGroup)
+			if err != nil {
+				return nil, err
+			}
+			if family != restoreConfig.ParameterGroupFamily {
+				return nil, fmt.Errorf("rdsClusterParameterGroup [%v] is of family [%v], restoreRDS needs [%v]", restoreConfig.ClusterParameterGroup, family, restoreConfig.ParameterGroupFamily)
+			}
+		}
+		if restoreConfig.ParameterGroup != "" {
+			family, err := parameterGroupFamilyOf(rdsClientSess, restoreConfig.ParameterGroup)
+			if err != nil {
+				return nil, err
+			}
+			if family != restoreConfig.ParameterGroupFamily {
+				return nil, fmt.Errorf("rdsParameterGroup [%v] is of family [%v], restoreRDS needs [%v]", restoreConfig.ParameterGroup, family, restoreConfig.ParameterGroupFamily)
+			}
+		}
+		return nil, checkParameterOverrides(rdsClientSess, restoreConfig)
+	}
+
+	sourceCluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.SourceRDS)
+	if describeErr != nil {
+		return nil, describeErr
+	}
+	if sourceCluster == nil {
+		return nil, fmt.Errorf("Source RDS cluster [%v] not found, cannot copy its parameter groups", restoreConfig.SourceRDS)
+	}
+
+	groups := &parameterGroupCopy{sourceClusterGroup: aws.StringValue(sourceCluster.DBClusterParameterGroup)}
+	family, familyErr := clusterParameterGroupFamily(rdsClientSess, groups.sourceClusterGroup)
+	if familyErr != nil {
+		return nil, familyErr
+	}
+	if family != restoreConfig.ParameterGroupFamily {
+		return nil, fmt.Errorf("Cluster parameter group [%v] of sourceRDS is of family [%v], restoreRDS needs [%v] - set rdsClusterParameterGroup instead",
+			groups.sourceClusterGroup, family, restoreConfig.ParameterGroupFamily)
+	}
+
+	// Instances get the parameter group of the source writer, Serverless v1 has no instances
+	if restoreConfig.CapacityMode == capacityServerlessV1 {
+		return groups, checkGroupOverrides(rdsClientSess, restoreConfig, groups.sourceClusterGroup, "")
+	}
+	for _, member := range sourceCluster.DBClusterMembers {
+		if !aws.BoolValue(member.IsClusterWriter) {
+			continue
+		}
+		writer, describeErr := describeRDSInstance(rdsClientSess, aws.StringValue(member.DBInstanceIdentifier))
+		if describeErr != nil {
+			return nil, describeErr
+		}
+		if writer != nil && len(writer.DBParameterGroups) > 0 {
+			groups.sourceInstanceGroup = aws.StringValue(writer.DBParameterGroups[0].DBParameterGroupName)
+		}
+	}
+	if groups.sourceInstanceGroup == "" {
+		if len(restoreConfig.ParameterOverrides) > 0 {
+			return nil, fmt.Errorf("Source RDS cluster [%v] has no writer to copy the parameter group of, cannot apply rdsParameterOverrides", restoreConfig.SourceRDS)
+		}
+		logf("Source RDS cluster [%v] has no writer, instances get the default parameter group\n", restoreConfig.SourceRDS)
+	}
+
+	// The copies start out with the parameters of the source groups
+	if overridesErr := checkGroupOverrides(rdsClientSess, restoreConfig, groups.sourceClusterGroup, groups.sourceInstanceGroup); overridesErr != nil {
+		return nil, overridesErr
+	}
+	return groups, nil
+}
+
+func clusterParameterGroupFamily(rdsClientSess rdsiface.RDSAPI, groupName string) (string, error) {
+	resp, err := rdsClientSess.DescribeDBClusterParameterGroups(&rds.DescribeDBClusterParameterGroupsInput{
+		DBClusterParameterGroupName: aws.String(groupName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Describe Err on cluster parameter group [%v]: %v", groupName, err)
+	}
+	if len(resp.DBClusterParameterGroups) == 0 {
+		return "", fmt.Errorf("Cluster parameter group [%v] not found", groupName)
+	}
+	return aws.StringValue(resp.DBClusterParameterGroups[0].DBParameterGroupFamily), nil
+}
+
+func parameterGroupFamilyOf(rdsClientSess rdsiface.RDSAPI, groupName string) (string, error) {
+	resp, err := rdsClientSess.DescribeDBParameterGroups(&rds.DescribeDBParameterGroupsInput{
+		DBParameterGroupName: aws.String(groupName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Describe Err on parameter group [%v]: %v", groupName, err)
+	}
+	if len(resp.DBParameterGroups) == 0 {
+		return "", fmt.Errorf("Parameter group [%v] not found", groupName)
+	}
+	return aws.StringValue(resp.DBParameterGroups[0].DBParameterGroupFamily), nil
+}
+
+// Create restoreRDS and its instances with the copied parameter groups
+func useCopiedParameterGroups(restoreConfig *RestoreConfig, groups *parameterGroupCopy) {
+	if groups == nil {
+		return
+	}
+	restoreConfig.ClusterParameterGroup = copiedClusterParameterGroupName(restoreConfig)
+	if groups.sourceInstanceGroup != "" {
+		restoreConfig.ParameterGroup = copiedParameterGroupName(restoreConfig)
+	}
+}
+
+// Copy the parameter groups of sourceRDS into the groups of restoreRDS, replacing
+// the copies of the previous restore, and apply the overrides
+func copyParameterGroups(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, groups *parameterGroupCopy) error {
+	if groups == nil {
+		return nil
+	}
+
+	clusterGroupName := copiedClusterParameterGroupName(restoreConfig)
+	deleteErr := deletePreviousClusterParameterGroup(rdsClientSess, restoreConfig, clusterGroupName)
+	if deleteErr != nil {
+		return deleteErr
+	}
+
+	logf("Copying cluster parameter group [%v] to [%v]\n", groups.sourceClusterGroup, clusterGroupName)
+	_, copyErr := rdsClientSess.CopyDBClusterParameterGroup(&rds.CopyDBClusterParameterGroupInput{
+		SourceDBClusterParameterGroupIdentifier:  aws.String(groups.sourceClusterGroup),
+		TargetDBClusterParameterGroupIdentifier:  aws.String(clusterGroupName),
+		TargetDBClusterParameterGroupDescription: aws.String(fmt.Sprintf("Copy of %v for %v", groups.sourceClusterGroup, restoreConfig.RestoreRDS)),
+		Tags:                                     restoreTags(restoreConfig),
+	})
+	if copyErr != nil {
+		return fmt.Errorf("Error copying cluster parameter group [%v] to [%v]: %v", groups.sourceClusterGroup, clusterGroupName, copyErr)
+	}
+	for _, parameters := range parameterBatches(pendingRebootParameters(restoreConfig.ClusterParameterOverrides)) {
+		logf("Overriding parameters of cluster parameter group [%v]: %v\n", clusterGroupName, parameterNames(parameters))
+		_, modifyErr := rdsClientSess.ModifyDBClusterParameterGroup(&rds.ModifyDBClusterParameterGroupInput{
+			DBClusterParameterGroupName: aws.String(clusterGroupName),
+			Parameters:                  parameters,
+		})
+		if modifyErr != nil {
+			return fmt.Errorf("Error overriding parameters of cluster parameter group [%v]: %v", clusterGroupName, modifyErr)
+		}
+	}
+
+	if groups.sourceInstanceGroup == "" {
+		return nil
+	}
+
+	groupName := copiedParameterGroupName(restoreConfig)
+	deleteErr = deletePreviousParameterGroup(rdsClientSess, restoreConfig, groupName)
+	if deleteErr != nil {
+		return deleteErr
+	}
+
+	logf("Copying parameter group [%v] to [%v]\n", groups.sourceInstanceGroup, groupName)
+	_, copyErr = rdsClientSess.CopyDBParameterGroup(&rds.CopyDBParameterGroupInput{
+		SourceDBParameterGroupIdentifier:  aws.String(groups.sourceInstanceGroup),
+		TargetDBParameterGroupIdentifier:  aws.String(groupName),
+		TargetDBParameterGroupDescription: aws.String(fmt.Sprintf("Copy of %v for %v", groups.sourceInstanceGroup, restoreConfig.RestoreRDS)),
+		Tags:                              restoreTags(restoreConfig),
+	})
+	if copyErr != nil {
+		return fmt.Errorf("Error copying parameter group [%v] to [%v]: %v", groups.sourceInstanceGroup, groupName, copyErr)
+	}
+	for _, parameters := range parameterBatches(pendingRebootParameters(restoreConfig.ParameterOverrides)) {
+		logf("Overriding parameters of parameter group [%v]: %v\n", groupName, parameterNames(parameters))
+		_, modifyErr := rdsClientSess.ModifyDBParameterGroup(&rds.ModifyDBParameterGroupInput{
+			DBParameterGroupName: aws.String(groupName),
+			Parameters:           parameters,
+		})
+		if modifyErr != nil {
+			return fmt.Errorf("Error overriding parameters of parameter group [%v]: %v", groupName, modifyErr)
+		}
+	}
+	return nil
+}
+
+// Delete the cluster parameter group copied for the previous restore, once
+// nothing uses it anymore
+func deletePreviousClusterParameterGroup(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, groupName string) error {
+	resp, err := rdsClientSess.DescribeDBClusterParameterGroups(&rds.DescribeDBClusterParameterGroupsInput{
+		DBClusterParameterGroupName: aws.String(groupName),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBParameterGroupNotFoundFault {
+			return nil
+		}
+		return fmt.Errorf("Describe Err on cluster parameter group [%v]: %v", groupName, err)
+	}
+	if len(resp.DBClusterParameterGroups) == 0 {
+		return nil
+	}
+
+	ownershipErr := checkResourceOwnership(rdsClientSess, restoreConfig, fmt.Sprintf("Cluster parameter group [%v]", groupName), resp.DBClusterParameterGroups[0].DBClusterParameterGroupArn)
+	if ownershipErr != nil {
+		return ownershipErr
+	}
+
+	logf("Deleting cluster parameter group [%v] of the previous restore\n", groupName)
+	_, deleteErr := rdsClientSess.DeleteDBClusterParameterGroup(&rds.DeleteDBClusterParameterGroupInput{
+		DBClusterParameterGroupName: aws.String(groupName),
+	})
+	if deleteErr != nil {
+		return fmt.Errorf("Error deleting cluster parameter group [%v]: %v", groupName, deleteErr)
+	}
+	return nil
+}
+
+// Delete the parameter group copied for the instances of the previous restore
+func deletePreviousParameterGroup(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, groupName string) error {
+	resp, err := rdsClientSess.DescribeDBParameterGroups(&rds.DescribeDBParameterGroupsInput{
+		DBParameterGroupName: aws.String(groupName),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBParameterGroupNotFoundFault {
+			return nil
+		}
+		return fmt.Errorf("Describe Err on parameter group [%v]: %v", groupName, err)
+	}
+	if len(resp.DBParameterGroups) == 0 {
+		return nil
+	}
+
+	ownershipErr := checkResourceOwnership(rdsClientSess, restoreConfig, fmt.Sprintf("Parameter group [%v]", groupName), resp.DBParameterGroups[0].DBParameterGroupArn)
+	if ownershipErr != nil {
+		return ownershipErr
+	}
+
+	logf("Deleting parameter group [%v] of the previous restore\n", groupName)
+	_, deleteErr := rdsClientSess.DeleteDBParameterGroup(&rds.DeleteDBParameterGroupInput{
+		DBParameterGroupName: aws.String(groupName),
+	})
+	if deleteErr != nil {
+		return fmt.Errorf("Error deleting parameter group [%v]: %v", groupName, deleteErr)
+	}
+	return nil
+}
+
+// Verify the ownership tags of a resource that doesn't list its tags when described
+func checkResourceOwnership(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, resource string, resourceARN *string) error {
+	resp, err := rdsClientSess.ListTagsForResource(&rds.ListTagsForResourceInput{
+		ResourceName: resourceARN,
+	})
+	if err != nil {
+		return fmt.Errorf("List tags Err on %v: %v", resource, err)
+	}
+	return checkOwnershipTags(restoreConfig, resource, resp.TagList)
+}
+
+// Overrides as ModifyDB(Cluster)ParameterGroup parameters, sorted by name. The
+// copied groups aren't attached to anything yet, pending-reboot applies every
+// parameter when restoreRDS is created.
+func pendingRebootParameters(overrides map[string]string) []*rds.Parameter {
+	var parameters []*rds.Parameter
+	for _, name := range sortedKeys(overrides) {
+		parameters = append(parameters, &rds.Parameter{
+			ParameterName:  aws.String(name),
+			ParameterValue: aws.String(overrides[name]),
+			ApplyMethod:    aws.String(rds.ApplyMethodPendingReboot),
+		})
+	}
+	return parameters
+}
+
+// Split parameters into batches ModifyDB(Cluster)ParameterGroup accepts
+func parameterBatches(parameters []*rds.Parameter) [][]*rds.Parameter {
+	var batches [][]*rds.Parameter
+	for start := 0; start < len(parameters); start += maxParametersPerModify {
+		end := start + maxParametersPerModify
+		if end > len(parameters) {
+			end = len(parameters)
+		}
+		batches = append(batches, parameters[start:end])
+	}
+	return batches
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func parameterNames(parameters []*rds.Parameter) []string {
+	var names []string
+	for _, parameter := range parameters {
+		names = append(names, aws.StringValue(parameter.ParameterName))
+	}
+	return names
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// Source cluster with custom cluster and instance parameter groups, and the
+// copies of them made for the previous restore
+func fakeWithSourceParameterGroups() *fakeRDS {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addInstance("test-db", "test-db-0", "available")
+	fake.clusters["test-db"].cluster.DBClusterParameterGroup = aws.String("test-db-cluster")
+	fake.instances["test-db-0"].instance.DBParameterGroups[0].DBParameterGroupName = aws.String("test-db-instance")
+	fake.clusterParameterGroups["test-db-cluster"] = fakeClusterParameterGroup("test-db-cluster", "aurora-mysql5.7")
+	fake.parameterGroups["test-db-instance"] = fakeParameterGroup("test-db-instance", "aurora-mysql5.7")
+	fake.clusterGroupParameters["test-db-cluster"] = []*rds.Parameter{
+		fakeParameter("binlog_format", "ROW", applyTypeStatic),
+		fakeParameter("time_zone", "UTC", "dynamic"),
+	}
+	fake.groupParameters["test-db-instance"] = []*rds.Parameter{
+		fakeParameter("general_log", "0", "dynamic"),
+	}
+
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	previousCopies := restoreTags(&RestoreConfig{SourceRDS: "test-db", RunID: "previous-run"})
+	fake.clusterParameterGroups["test-db-restore-cluster-params"] = fakeClusterParameterGroup("test-db-restore-cluster-params", "aurora-mysql5.7")
+	fake.resourceTags[aws.StringValue(fake.clusterParameterGroups["test-db-restore-cluster-params"].DBClusterParameterGroupArn)] = previousCopies
+	fake.parameterGroups["test-db-restore-params"] = fakeParameterGroup("test-db-restore-params", "aurora-mysql5.7")
+	fake.resourceTags[aws.StringValue(fake.parameterGroups["test-db-restore-params"].DBParameterGroupArn)] = previousCopies
+	return fake
+}
+
+func TestRestoreRDSClusterWithParameterGroups(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.clusterParameterGroups["qa-cluster"] = fakeClusterParameterGroup("qa-cluster", "aurora-mysql5.7")
+	fake.parameterGroups["qa-instance"] = fakeParameterGroup("qa-instance", "aurora-mysql5.7")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.ClusterParameterGroup = "qa-cluster"
+	restoreConfig.ParameterGroup = "qa-instance"
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	if got := aws.StringValue(fake.restoreInputs[0].DBClusterParameterGroupName); got != "qa-cluster" {
+		t.Errorf("DBClusterParameterGroupName = %v, want qa-cluster", got)
+	}
+	if got := aws.StringValue(fake.createInstanceInputs[0].DBParameterGroupName); got != "qa-instance" {
+		t.Errorf("DBParameterGroupName = %v, want qa-instance", got)
+	}
+}
+
+func TestRestoreRDSClusterRefusesParameterGroupOfOtherFamily(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.ClusterParameterGroup = "default.aurora-postgresql13"
+	err := restoreRDSCluster(context.Background(), fake, restoreConfig)
+	if err == nil || !strings.Contains(err.Error(), "is of family [aurora-postgresql13], restoreRDS needs [aurora-mysql5.7]") {
+		t.Fatalf("expected parameter group family error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("parameter group family mismatch sent mutating calls: %v", calls)
+	}
+}
+
+func TestRestoreRDSClusterCopiesSourceParameterGroups(t *testing.T) {
+	fake := fakeWithSourceParameterGroups()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.CopySourceParameterGroups = true
+	restoreConfig.ClusterParameterOverrides = map[string]string{"binlog_format": "OFF", "time_zone": "UTC"}
+	restoreConfig.ParameterOverrides = map[string]string{"general_log": "1"}
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	wantCalls := []string{
+		"DeleteDBCluster",
+		"DeleteDBClusterParameterGroup", "CopyDBClusterParameterGroup", "ModifyDBClusterParameterGroup",
+		"DeleteDBParameterGroup", "CopyDBParameterGroup", "ModifyDBParameterGroup",
+		"RestoreDBClusterToPointInTime", "CreateDBInstance",
+	}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("mutating calls = %v, want %v", calls, wantCalls)
+	}
+
+	if got := aws.StringValue(fake.restoreInputs[0].DBClusterParameterGroupName); got != "test-db-restore-cluster-params" {
+		t.Errorf("DBClusterParameterGroupName = %v, want test-db-restore-cluster-params", got)
+	}
+	if got := aws.StringValue(fake.createInstanceInputs[0].DBParameterGroupName); got != "test-db-restore-params" {
+		t.Errorf("DBParameterGroupName = %v, want test-db-restore-params", got)
+	}
+	if got := parameterNames(fake.modifyClusterParamsInputs[0].Parameters); !reflect.DeepEqual(got, []string{"binlog_format", "time_zone"}) {
+		t.Errorf("cluster overrides = %v, want [binlog_format time_zone]", got)
+	}
+	if got := parameterNames(fake.modifyParamsInputs[0].Parameters); !reflect.DeepEqual(got, []string{"general_log"}) {
+		t.Errorf("instance overrides = %v, want [general_log]", got)
+	}
+}
+
+func TestRestoreRDSClusterResumedAfterCopyUsesCopiedParameterGroups(t *testing.T) {
+	fake := fakeWithSourceParameterGroups()
+	delete(fake.clusters, "test-db-restore")
+	// The restored cluster is still creating when the first run gives up
+	fake.transitionPolls = 1000
+
+	restoreConfig := checkpointRestoreConfig(t)
+	restoreConfig.CopySourceParameterGroups = true
+	restoreConfig.WaitTimeout = 20 * time.Millisecond
+	restoreConfig.WaitPollInterval = time.Millisecond
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); !isWaitFailure(err) {
+		t.Fatalf("expected the cluster wait to time out, got %v", err)
+	}
+
+	// The rerun starts from a fresh config, like a new process would
+	fake.transitionPolls = 0
+	fake.clusters["test-db-restore"].pendingPolls = 0
+	resumeConfig := testRestoreConfig()
+	resumeConfig.RunID = restoreConfig.RunID
+	resumeConfig.CheckpointDir = restoreConfig.CheckpointDir
+	resumeConfig.CopySourceParameterGroups = true
+	callsBefore := len(mutatingCalls(fake))
+	if err := restoreRDSCluster(context.Background(), fake, resumeConfig); err != nil {
+		t.Fatalf("resumed restoreRDSCluster: %v", err)
+	}
+
+	if calls := mutatingCalls(fake)[callsBefore:]; !reflect.DeepEqual(calls, []string{"CreateDBInstance"}) {
+		t.Errorf("resumed restore sent mutating calls %v, want [CreateDBInstance]", calls)
+	}
+	if got := aws.StringValue(fake.createInstanceInputs[0].DBParameterGroupName); got != "test-db-restore-params" {
+		t.Errorf("DBParameterGroupName = %v, want test-db-restore-params", got)
+	}
+}
+
+func TestRestoreRDSClusterAdoptedUsesCopiedParameterGroups(t *testing.T) {
+	fake := fakeWithSourceParameterGroups()
+	fake.clusters["test-db-restore"].cluster.Status = aws.String("creating")
+	// Still creating once the check step is done
+	fake.clusters["test-db-restore"].pendingPolls = 10
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.CopySourceParameterGroups = true
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, []string{"CreateDBInstance"}) {
+		t.Errorf("mutating calls = %v, want [CreateDBInstance]", calls)
+	}
+	if got := aws.StringValue(fake.createInstanceInputs[0].DBParameterGroupName); got != "test-db-restore-params" {
+		t.Errorf("DBParameterGroupName = %v, want test-db-restore-params", got)
+	}
+}
+
+func TestRestoreRDSClusterRefusesUnknownOverrideOfSourceParameterGroup(t *testing.T) {
+	fake := fakeWithSourceParameterGroups()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.CopySourceParameterGroups = true
+	restoreConfig.ParameterOverrides = map[string]string{"genral_log": "1"}
+	err := restoreRDSCluster(context.Background(), fake, restoreConfig)
+	if err == nil || !strings.Contains(err.Error(), "Parameter group [test-db-instance] has no parameter [genral_log]") {
+		t.Fatalf("expected unknown parameter error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("unknown parameter sent mutating calls: %v", calls)
+	}
+}
+
+func TestCopyParameterGroupsRefusesUntaggedGroup(t *testing.T) {
+	fake := fakeWithSourceParameterGroups()
+	fake.resourceTags[aws.StringValue(fake.clusterParameterGroups["test-db-restore-cluster-params"].DBClusterParameterGroupArn)] = nil
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.CopySourceParameterGroups = true
+	err := restoreRDSCluster(context.Background(), fake, restoreConfig)
+
+	var safetyErr *SafetyError
+	if !errors.As(err, &safetyErr) {
+		t.Fatalf("expected SafetyError, got %v", err)
+	}
+	if fake.callCount("DeleteDBClusterParameterGroup") != 0 {
+		t.Errorf("untagged cluster parameter group was deleted")
+	}
+}
+
+func TestParameterBatches(t *testing.T) {
+	overrides := map[string]string{}
+	for i := 0; i < 45; i++ {
+		overrides[fmt.Sprintf("param_%02d", i)] = "1"
+	}
+
+	batches := parameterBatches(pendingRebootParameters(overrides))
+	if len(batches) != 3 || len(batches[0]) != 20 || len(batches[2]) != 5 {
+		t.Fatalf("got %d batches, want 20, 20 and 5 parameters", len(batches))
+	}
+	if got := aws.StringValue(batches[1][0].ParameterName); got != "param_20" {
+		t.Errorf("second batch starts with %v, want param_20", got)
+	}
+	if got := aws.StringValue(batches[0][0].ApplyMethod); got != "pending-reboot" {
+		t.Errorf("ApplyMethod = %v, want pending-reboot", got)
+	}
+}
+
+func TestParameterGroupProblems(t *testing.T) {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.ParameterOverrides = map[string]string{"general_log": "1"}
+	if problems := restoreConfig.parameterGroupProblems(); len(problems) != 1 {
+		t.Errorf("overrides of the default group: problems = %q, want 1", problems)
+	}
+	restoreConfig.ParameterGroup = "qa-instance"
+	if problems := restoreConfig.parameterGroupProblems(); len(problems) != 0 {
+		t.Errorf("overrides of rdsParameterGroup: problems = %q, want none", problems)
+	}
+
+	restoreConfig.CopySourceParameterGroups = true
+	restoreConfig.SourceRegion = "eu-west-1"
+	if problems := restoreConfig.parameterGroupProblems(); len(problems) != 2 {
+		t.Errorf("copy with a group and another region: problems = %q, want 2", problems)
+	}
+}
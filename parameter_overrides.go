@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Apply type of parameters that only take effect after a reboot
+const applyTypeStatic = "static"
+
+// Parameter apply status of an instance whose groups are fully applied
+const parameterApplyInSync = "in-sync"
+
+// Check the overrides of rdsClusterParameterGroup and rdsParameterGroup name
+// modifiable parameters, before anything is deleted
+func checkParameterOverrides(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	return checkGroupOverrides(rdsClientSess, restoreConfig, restoreConfig.ClusterParameterGroup, restoreConfig.ParameterGroup)
+}
+
+// Check the overrides against the given cluster and instance parameter groups
+func checkGroupOverrides(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, clusterGroupName string, groupName string) error {
+	if len(restoreConfig.ClusterParameterOverrides) > 0 {
+		current, describeErr := clusterGroupParameters(rdsClientSess, clusterGroupName)
+		if describeErr != nil {
+			return describeErr
+		}
+		_, _, changedErr := changedParameters(fmt.Sprintf("Cluster parameter group [%v]", clusterGroupName), current, restoreConfig.ClusterParameterOverrides)
+		if changedErr != nil {
+			return changedErr
+		}
+	}
+	if len(restoreConfig.ParameterOverrides) > 0 {
+		current, describeErr := groupParameters(rdsClientSess, groupName)
+		if describeErr != nil {
+			return describeErr
+		}
+		_, _, changedErr := changedParameters(fmt.Sprintf("Parameter group [%v]", groupName), current, restoreConfig.ParameterOverrides)
+		if changedErr != nil {
+			return changedErr
+		}
+	}
+	return nil
+}
+
+// Apply the overrides to rdsClusterParameterGroup and rdsParameterGroup once
+// restoreRDS and its instances are available. Static parameters only take
+// effect after a reboot - the instances are rebooted one at a time then, each
+// waited for until it's available again with in-sync parameters.
+func applyParameterOverrides(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, rdsInstanceNames []string) error {
+	// Copied groups got their overrides before restoreRDS was created with them
+	if restoreConfig.CopySourceParameterGroups {
+		return nil
+	}
+	if len(restoreConfig.ClusterParameterOverrides) == 0 && len(restoreConfig.ParameterOverrides) == 0 {
+		return nil
+	}
+
+	var modified bool
+	var staticParameters []string
+
+	if len(restoreConfig.ClusterParameterOverrides) > 0 {
+		groupName := restoreConfig.ClusterParameterGroup
+		current, describeErr := clusterGroupParameters(rdsClientSess, groupName)
+		if describeErr != nil {
+			return describeErr
+		}
+		parameters, static, changedErr := changedParameters(fmt.Sprintf("Cluster parameter group [%v]", groupName), current, restoreConfig.ClusterParameterOverrides)
+		if changedErr != nil {
+			return changedErr
+		}
+		for _, batch := range parameterBatches(parameters) {
+			logf("Overriding parameters of cluster parameter group [%v]: %v\n", groupName, parameterNames(batch))
+			_, modifyErr := rdsClientSess.ModifyDBClusterParameterGroup(&rds.ModifyDBClusterParameterGroupInput{
+				DBClusterParameterGroupName: aws.String(groupName),
+				Parameters:                  batch,
+			})
+			if modifyErr != nil {
+				return fmt.Errorf("Error overriding parameters of cluster parameter group [%v]: %v", groupName, modifyErr)
+			}
+		}
+		modified = modified || len(parameters) > 0
+		staticParameters = append(staticParameters, static...)
+	}
+
+	if len(restoreConfig.ParameterOverrides) > 0 {
+		groupName := restoreConfig.ParameterGroup
+		current, describeErr := groupParameters(rdsClientSess, groupName)
+		if describeErr != nil {
+			return describeErr
+		}
+		parameters, static, changedErr := changedParameters(fmt.Sprintf("Parameter group [%v]", groupName), current, restoreConfig.ParameterOverrides)
+		if changedErr != nil {
+			return changedErr
+		}
+		for _, batch := range parameterBatches(parameters) {
+			logf("Overriding parameters of parameter group [%v]: %v\n", groupName, parameterNames(batch))
+			_, modifyErr := rdsClientSess.ModifyDBParameterGroup(&rds.ModifyDBParameterGroupInput{
+				DBParameterGroupName: aws.String(groupName),
+				Parameters:           batch,
+			})
+			if modifyErr != nil {
+				return fmt.Errorf("Error overriding parameters of parameter group [%v]: %v", groupName, modifyErr)
+			}
+		}
+		modified = modified || len(parameters) > 0
+		staticParameters = append(staticParameters, static...)
+	}
+
+	if !modified {
+		logf("Parameter overrides of [%v] are already applied\n", restoreConfig.RestoreRDS)
+		return nil
+	}
+
+	// Serverless v1 picks static parameters up at its next scaling point or resume
+	if len(rdsInstanceNames) == 0 {
+		if len(staticParameters) > 0 {
+			logf("RDS cluster [%v] has no instances to reboot, static parameters %v apply on its next restart\n", restoreConfig.RestoreRDS, staticParameters)
+		}
+		return nil
+	}
+
+	for _, rdsInstanceName := range rdsInstanceNames {
+		if len(staticParameters) > 0 {
+			logf("Static parameters %v are pending reboot, rebooting RDS instance [%v] ...\n", staticParameters, rdsInstanceName)
+			_, rebootErr := rdsClientSess.RebootDBInstance(&rds.RebootDBInstanceInput{
+				DBInstanceIdentifier: aws.String(rdsInstanceName),
+			})
+			if rebootErr != nil {
+				return fmt.Errorf("Error rebooting RDS instance [%v]: %v", rdsInstanceName, rebootErr)
+			}
+		}
+
+		waitErr := waitUntilParametersInSync(ctx, rdsClientSess, restoreConfig, rdsInstanceName)
+		if waitErr != nil {
+			return waitErr
+		}
+	}
+	return nil
+}
+
+// Overrides that differ from the current values of a group, with the apply
+// method their apply type needs - dynamic parameters are applied immediately,
+// static ones stay pending until a reboot and are returned by name
+func changedParameters(group string, current map[string]*rds.Parameter, overrides map[string]string) ([]*rds.Parameter, []string, error) {
+	var parameters []*rds.Parameter
+	var static []string
+
+	for _, name := range sortedKeys(overrides) {
+		parameter, ok := current[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%v has no parameter [%v]", group, name)
+		}
+		if !aws.BoolValue(parameter.IsModifiable) {
+			return nil, nil, fmt.Errorf("Parameter [%v] of %v can't be modified", name, group)
+		}
+		if aws.StringValue(parameter.ParameterValue) == overrides[name] {
+			continue
+		}
+
+		applyMethod := rds.ApplyMethodImmediate
+		if aws.StringValue(parameter.ApplyType) == applyTypeStatic {
+			applyMethod = rds.ApplyMethodPendingReboot
+			static = append(static, name)
+		}
+		parameters = append(parameters, &rds.Parameter{
+			ParameterName:  aws.String(name),
+			ParameterValue: aws.String(overrides[name]),
+			ApplyMethod:    aws.String(applyMethod),
+		})
+	}
+	return parameters, static, nil
+}
+
+func clusterGroupParameters(rdsClientSess rdsiface.RDSAPI, groupName string) (map[string]*rds.Parameter, error) {
+	parameters := map[string]*rds.Parameter{}
+	err := rdsClientSess.DescribeDBClusterParametersPages(&rds.DescribeDBClusterParametersInput{
+		DBClusterParameterGroupName: aws.String(groupName),
+	}, func(page *rds.DescribeDBClusterParametersOutput, lastPage bool) bool {
+		for _, parameter := range page.Parameters {
+			parameters[aws.StringValue(parameter.ParameterName)] = parameter
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Describe parameters Err on cluster parameter group [%v]: %v", groupName, err)
+	}
+	return parameters, nil
+}
+
+func groupParameters(rdsClientSess rdsiface.RDSAPI, groupName string) (map[string]*rds.Parameter, error) {
+	parameters := map[string]*rds.Parameter{}
+	err := rdsClientSess.DescribeDBParametersPages(&rds.DescribeDBParametersInput{
+		DBParameterGroupName: aws.String(groupName),
+	}, func(page *rds.DescribeDBParametersOutput, lastPage bool) bool {
+		for _, parameter := range page.Parameters {
+			parameters[aws.StringValue(parameter.ParameterName)] = parameter
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Describe parameters Err on parameter group [%v]: %v", groupName, err)
+	}
+	return parameters, nil
+}
+
+// Wait until an instance is available and its parameter groups, including the
+// cluster parameter group of its cluster, are in-sync
+func waitUntilParametersInSync(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, rdsInstanceName string) error {
+	logf("Wait until RDS instance [%v] is available with in-sync parameters ...\n", rdsInstanceName)
+	w := &waiter{
+		resource:      fmt.Sprintf("RDS instance [%v] parameters", rdsInstanceName),
+		successStates: []string{parameterApplyInSync},
+		failureStates: creationFailureStates(instanceFailureStates),
+		timing:        restoreConfig.waitTiming(defaultWaitTimeout),
+		// Status of the instance until it's available, the apply status of its parameters then
+		poll: func() (string, error) {
+			instance, describeErr := describeRDSInstance(rdsClientSess, rdsInstanceName)
+			if describeErr != nil {
+				return "", describeErr
+			}
+			if instance == nil {
+				return statusNotFound, nil
+			}
+			if status := aws.StringValue(instance.DBInstanceStatus); status != "available" {
+				return status, nil
+			}
+			return parameterApplyStatus(rdsClientSess, instance)
+		},
+	}
+	if waitErr := w.wait(ctx); waitErr != nil {
+		return waitErr
+	}
+
+	logf("RDS instance [%v] is available with in-sync parameters\n", rdsInstanceName)
+	return nil
+}
+
+// First parameter apply status of an instance that isn't in-sync
+func parameterApplyStatus(rdsClientSess rdsiface.RDSAPI, instance *rds.DBInstance) (string, error) {
+	for _, group := range instance.DBParameterGroups {
+		if status := aws.StringValue(group.ParameterApplyStatus); status != parameterApplyInSync {
+			return status, nil
+		}
+	}
+
+	clusterName := aws.StringValue(instance.DBClusterIdentifier)
+	if clusterName == "" {
+		return parameterApplyInSync, nil
+	}
+	cluster, describeErr := describeRDSCluster(rdsClientSess, clusterName)
+	if describeErr != nil {
+		return "", describeErr
+	}
+	if cluster == nil {
+		return "", fmt.Errorf("RDS cluster [%v] of instance [%v] not found", clusterName, aws.StringValue(instance.DBInstanceIdentifier))
+	}
+	for _, member := range cluster.DBClusterMembers {
+		if aws.StringValue(member.DBInstanceIdentifier) != aws.StringValue(instance.DBInstanceIdentifier) {
+			continue
+		}
+		if status := aws.StringValue(member.DBClusterParameterGroupStatus); status != "" && status != parameterApplyInSync {
+			return status, nil
+		}
+	}
+	return parameterApplyInSync, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// Source cluster and QA parameter groups with a static and a dynamic parameter each
+func fakeWithQAParameterGroups() *fakeRDS {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.clusterParameterGroups["qa-cluster"] = fakeClusterParameterGroup("qa-cluster", "aurora-mysql5.7")
+	fake.clusterGroupParameters["qa-cluster"] = []*rds.Parameter{
+		fakeParameter("binlog_format", "ROW", applyTypeStatic),
+		fakeParameter("time_zone", "UTC", "dynamic"),
+	}
+	fake.parameterGroups["qa-instance"] = fakeParameterGroup("qa-instance", "aurora-mysql5.7")
+	fake.groupParameters["qa-instance"] = []*rds.Parameter{
+		fakeParameter("general_log", "0", "dynamic"),
+		fakeParameter("performance_schema", "0", applyTypeStatic),
+	}
+	return fake
+}
+
+func qaParameterRestoreConfig() *RestoreConfig {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Instances = []InstanceSpec{{Name: "test-db-restore-0"}, {Name: "test-db-restore-1"}}
+	restoreConfig.ClusterParameterGroup = "qa-cluster"
+	restoreConfig.ParameterGroup = "qa-instance"
+	return restoreConfig
+}
+
+func TestRestoreRDSClusterAppliesParameterOverridesAndReboots(t *testing.T) {
+	fake := fakeWithQAParameterGroups()
+
+	restoreConfig := qaParameterRestoreConfig()
+	restoreConfig.ClusterParameterOverrides = map[string]string{"binlog_format": "OFF"}
+	restoreConfig.ParameterOverrides = map[string]string{"general_log": "1"}
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	wantCalls := []string{
+		"RestoreDBClusterToPointInTime", "CreateDBInstance", "CreateDBInstance",
+		"ModifyDBClusterParameterGroup", "ModifyDBParameterGroup",
+		"RebootDBInstance", "RebootDBInstance",
+	}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("mutating calls = %v, want %v", calls, wantCalls)
+	}
+
+	if got := aws.StringValue(fake.modifyClusterParamsInputs[0].Parameters[0].ApplyMethod); got != rds.ApplyMethodPendingReboot {
+		t.Errorf("static binlog_format ApplyMethod = %v, want %v", got, rds.ApplyMethodPendingReboot)
+	}
+	if got := aws.StringValue(fake.modifyParamsInputs[0].Parameters[0].ApplyMethod); got != rds.ApplyMethodImmediate {
+		t.Errorf("dynamic general_log ApplyMethod = %v, want %v", got, rds.ApplyMethodImmediate)
+	}
+
+	for _, name := range []string{"test-db-restore-0", "test-db-restore-1"} {
+		instance := fake.instances[name].instance
+		status, err := parameterApplyStatus(fake, instance)
+		if err != nil {
+			t.Fatalf("parameterApplyStatus: %v", err)
+		}
+		if aws.StringValue(instance.DBInstanceStatus) != "available" || status != parameterApplyInSync {
+			t.Errorf("instance [%v] is %v with %v parameters, want available and in-sync", name, aws.StringValue(instance.DBInstanceStatus), status)
+		}
+	}
+}
+
+func TestRestoreRDSClusterDynamicParameterOverridesSkipReboot(t *testing.T) {
+	fake := fakeWithQAParameterGroups()
+
+	restoreConfig := qaParameterRestoreConfig()
+	restoreConfig.ClusterParameterOverrides = map[string]string{"time_zone": "Europe/Sofia"}
+	restoreConfig.ParameterOverrides = map[string]string{"general_log": "1"}
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+	if fake.callCount("RebootDBInstance") != 0 {
+		t.Errorf("dynamic parameter overrides rebooted instances")
+	}
+}
+
+func TestRestoreRDSClusterSkipsAppliedParameterOverrides(t *testing.T) {
+	fake := fakeWithQAParameterGroups()
+
+	restoreConfig := qaParameterRestoreConfig()
+	restoreConfig.ClusterParameterOverrides = map[string]string{"binlog_format": "ROW"}
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+	if calls := fake.callCount("ModifyDBClusterParameterGroup") + fake.callCount("RebootDBInstance"); calls != 0 {
+		t.Errorf("already applied overrides sent %d modify and reboot calls", calls)
+	}
+}
+
+func TestRestoreRDSClusterRefusesUnknownParameterOverride(t *testing.T) {
+	fake := fakeWithQAParameterGroups()
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	restoreConfig := qaParameterRestoreConfig()
+	restoreConfig.ParameterOverrides = map[string]string{"genral_log": "1"}
+	err := restoreRDSCluster(context.Background(), fake, restoreConfig)
+	if err == nil || !strings.Contains(err.Error(), "Parameter group [qa-instance] has no parameter [genral_log]") {
+		t.Fatalf("expected unknown parameter error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("unknown parameter sent mutating calls: %v", calls)
+	}
+}
+
+func TestRunRestoreStandaloneInstanceRebootsForStaticOverrides(t *testing.T) {
+	fake := fakeWithQAParameterGroups()
+	fake.addStandaloneInstance("test-db-pg", "available")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.SourceRDS = "test-db-pg"
+	restoreConfig.ParameterGroup = "qa-instance"
+	restoreConfig.ParameterOverrides = map[string]string{"performance_schema": "1"}
+	if err := runRestore(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("runRestore: %v", err)
+	}
+
+	wantCalls := []string{"RestoreDBInstanceToPointInTime", "ModifyDBParameterGroup", "RebootDBInstance"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("mutating calls = %v, want %v", calls, wantCalls)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Step reconciling restoreRDS with what a previous run left behind, run when
+// there is no checkpoint to resume from
+const stepReconcile = "reconcile"
+
+// Decide where a cluster restore starts from the state a previous run left
+// restoreRDS in. A cluster of this tool from sourceRDS that is still being
+// created, whose instances are, or that has some but not all instances of the
+// topology is adopted - the restore resumes after the restore step, waits for
+// it and only adds the missing instances. Anything else starts from scratch,
+// the delete steps wait for deletes already in progress.
+func reconcileRDSCluster(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, topology []InstanceSpec) (string, error) {
+	cluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.RestoreRDS)
+	if describeErr != nil {
+		return "", describeErr
+	}
+	if cluster == nil {
+		return "", nil
+	}
+
+	// A cluster of someone else is left to the ownership guards of the delete steps
+	runID := restoreRunID(cluster.TagList, restoreConfig)
+	if runID == "" {
+		return "", nil
+	}
+
+	status := aws.StringValue(cluster.Status)
+	if status == "creating" {
+		logf("RDS cluster [%v] is still being created by run [%v], adopting it instead of deleting it\n", restoreConfig.RestoreRDS, runID)
+		return stepRestore, nil
+	}
+	if status != "available" {
+		return "", nil
+	}
+
+	var creating []string
+	members := map[string]bool{}
+	for _, member := range cluster.DBClusterMembers {
+		rdsInstanceName := aws.StringValue(member.DBInstanceIdentifier)
+		members[rdsInstanceName] = true
+		instanceStatus, statusErr := instanceStatus(rdsClientSess, rdsInstanceName)()
+		if statusErr != nil {
+			return "", fmt.Errorf("Describe RDS Instance [%v] Err: %v", rdsInstanceName, statusErr)
+		}
+		if instanceStatus == "creating" {
+			creating = append(creating, rdsInstanceName)
+		}
+	}
+	if len(creating) > 0 {
+		logf("RDS instances %v of RDS cluster [%v] are still being created by run [%v], adopting the cluster and adding the missing instances\n", creating, restoreConfig.RestoreRDS, runID)
+		return stepRestore, nil
+	}
+
+	// A run killed between instance creates leaves the cluster short of instances,
+	// a cluster without any is an orphan and restored from scratch
+	var missing []string
+	for _, instance := range topology {
+		if !members[instance.Name] {
+			missing = append(missing, instance.Name)
+		}
+	}
+	if len(members) > 0 && len(missing) > 0 {
+		logf("RDS cluster [%v] of run [%v] is missing RDS instances %v, adopting the cluster and adding them\n", restoreConfig.RestoreRDS, runID, missing)
+		return stepRestore, nil
+	}
+	return "", nil
+}
+
+// Decide where a standalone instance restore starts - an instance of this tool
+// from sourceRDS that is still being created is adopted and waited for
+func reconcileRDSInstance(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (string, error) {
+	instance, describeErr := describeRDSInstance(rdsClientSess, restoreConfig.RestoreRDS)
+	if describeErr != nil {
+		return "", describeErr
+	}
+	if instance == nil || aws.StringValue(instance.DBInstanceStatus) != "creating" {
+		return "", nil
+	}
+
+	runID := restoreRunID(instance.TagList, restoreConfig)
+	if runID == "" {
+		return "", nil
+	}
+	logf("RDS instance [%v] is still being created by run [%v], adopting it instead of deleting it\n", restoreConfig.RestoreRDS, runID)
+	return stepRestore, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestRestoreRDSClusterWaitsForDeleteInProgress(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.clusters["test-db-restore"].cluster.Status = aws.String("deleting")
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+	want := []string{"RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, want) {
+		t.Errorf("mutating calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRestoreRDSClusterAdoptsClusterBeingCreated(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.clusters["test-db-restore"].cluster.Status = aws.String("creating")
+
+	if err := restoreRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+	want := []string{"CreateDBInstance"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, want) {
+		t.Errorf("mutating calls = %v, want %v", calls, want)
+	}
+	if status := aws.StringValue(fake.clusters["test-db-restore"].cluster.Status); status != "available" {
+		t.Errorf("cluster status = %v, want available", status)
+	}
+}
+
+func TestRestoreRDSClusterAddsMissingInstancesToAdoptedCluster(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "creating")
+	// Still creating once the check step is done
+	fake.instances["test-db-restore-0"].pendingPolls = 10
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Instances = []InstanceSpec{{}, {}}
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	want := []string{"CreateDBInstance"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, want) {
+		t.Fatalf("mutating calls = %v, want %v", calls, want)
+	}
+	if created := aws.StringValue(fake.createInstanceInputs[0].DBInstanceIdentifier); created != "test-db-restore-1" {
+		t.Errorf("created instance = %v, want test-db-restore-1", created)
+	}
+}
+
+func TestRestoreRDSClusterAddsMissingInstancesToAvailableCluster(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	// Previous run was killed after creating the first instance
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addInstance("test-db-restore", "test-db-restore-0", "available")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Instances = []InstanceSpec{{}, {}}
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	want := []string{"CreateDBInstance"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, want) {
+		t.Fatalf("mutating calls = %v, want %v", calls, want)
+	}
+	if created := aws.StringValue(fake.createInstanceInputs[0].DBInstanceIdentifier); created != "test-db-restore-1" {
+		t.Errorf("created instance = %v, want test-db-restore-1", created)
+	}
+}
+
+func TestRestoreRDSClusterRefusesForeignClusterBeingCreated(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addCluster("test-db-restore", "creating")
+
+	err := restoreRDSCluster(context.Background(), fake, testRestoreConfig())
+
+	var safetyErr *SafetyError
+	if !errors.As(err, &safetyErr) {
+		t.Fatalf("expected SafetyError, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("sent mutating calls %v", calls)
+	}
+}
+
+func TestRestoreRDSInstanceWaitsForDeleteInProgress(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addStandaloneInstance("test-db-pg", "available")
+	fake.addRestoredStandaloneInstance("test-db-restore", "test-db-pg")
+	fake.instances["test-db-restore"].instance.DBInstanceStatus = aws.String("deleting")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.SourceRDS = "test-db-pg"
+	if err := restoreRDSInstance(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSInstance: %v", err)
+	}
+	want := []string{"RestoreDBInstanceToPointInTime"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, want) {
+		t.Errorf("mutating calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRestoreRDSInstanceAdoptsInstanceBeingCreated(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addStandaloneInstance("test-db-pg", "available")
+	fake.addRestoredStandaloneInstance("test-db-restore", "test-db-pg")
+	fake.instances["test-db-restore"].instance.DBInstanceStatus = aws.String("creating")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.SourceRDS = "test-db-pg"
+	if err := restoreRDSInstance(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSInstance: %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("adopted restore sent mutating calls: %v", calls)
+	}
+	if status := aws.StringValue(fake.instances["test-db-restore"].instance.DBInstanceStatus); status != "available" {
+		t.Errorf("instance status = %v, want available", status)
+	}
+}
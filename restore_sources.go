@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Restore sources, selected with restoreSource
+const (
+	sourcePointInTime             = "point-in-time"
+	sourceSnapshot                = "snapshot"
+	sourceLatestAutomatedSnapshot = "latest-automated-snapshot"
+	sourceLatestTaggedSnapshot    = "latest-tagged-snapshot"
+	sourceNewSnapshot             = "new-snapshot"
+
+	defaultRestoreSource = sourcePointInTime
+)
+
+// Strategy creating the restoreRDS cluster, the rest of the pipeline (cleanup,
+// waits, instances) is the same for every strategy
+type restoreStrategy struct {
+	name        string
+	description string
+	restore     func(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error
+}
+
+var restoreStrategies = []restoreStrategy{
+	{
+		name:        sourcePointInTime,
+		description: "point-in-time restore of sourceRDS at restoreDate/restoreTime or the latest restorable time",
+		restore:     restorePointInTimeRDS,
+	},
+	{
+		name:        sourceSnapshot,
+		description: "cluster snapshot named restoreSnapshotIdentifier",
+		restore:     restoreFromSnapshot(findNamedSnapshot),
+	},
+	{
+		name:        sourceLatestAutomatedSnapshot,
+		description: "newest automated snapshot of sourceRDS",
+		restore:     restoreFromSnapshot(findLatestAutomatedSnapshot),
+	},
+	{
+		name:        sourceLatestTaggedSnapshot,
+		description: "newest manual snapshot of sourceRDS tagged with restoreSnapshotTag (key=value)",
+		restore:     restoreFromSnapshot(findLatestTaggedSnapshot),
+	},
+	{
+		name:        sourceNewSnapshot,
+		description: "manual snapshot of sourceRDS taken now, deleted once restoreRDS is created from it",
+		restore:     restoreFromSnapshot(takeSourceSnapshot),
+	},
+}
+
+func findRestoreStrategy(name string) (restoreStrategy, bool) {
+	for _, strategy := range restoreStrategies {
+		if strategy.name == name {
+			return strategy, true
+		}
+	}
+	return restoreStrategy{}, false
+}
+
+func restoreStrategyNames() []string {
+	var names []string
+	for _, strategy := range restoreStrategies {
+		names = append(names, strategy.name)
+	}
+	return names
+}
+
+// Create restoreRDS with the configured strategy
+func restoreRDS(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	strategy, ok := findRestoreStrategy(restoreConfig.RestoreSource)
+	if !ok {
+		return fmt.Errorf("Unknown restoreSource [%v], expected one of %v", restoreConfig.RestoreSource, restoreStrategyNames())
+	}
+	return strategy.restore(ctx, rdsClientSess, restoreConfig)
+}
+
+// Split restoreSnapshotTag into its key and value
+func parseSnapshotTag(snapshotTag string) (string, string, bool) {
+	tag := strings.SplitN(snapshotTag, "=", 2)
+	if len(tag) != 2 || tag[0] == "" {
+		return "", "", false
+	}
+	return tag[0], tag[1], true
+}
+
+// Restore strategy restoring from the snapshot picked by findSnapshot, which
+// looks for it with the client of sourceRDS
+func restoreFromSnapshot(findSnapshot func(context.Context, rdsiface.RDSAPI, *RestoreConfig) (*rds.DBClusterSnapshot, error)) func(context.Context, rdsiface.RDSAPI, *RestoreConfig) error {
+	return func(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+		snapshot, findErr := findSnapshot(ctx, sourceClient(rdsClientSess), restoreConfig)
+		if findErr != nil {
+			return findErr
+		}
+
+		var restoreErr error
+		switch {
+		case restoreConfig.crossAccount():
+			// Deletes the snapshot once it's copied if this run took it
+			restoreErr = restoreFromSharedSnapshot(ctx, rdsClientSess, restoreConfig, snapshot)
+		case restoreConfig.crossRegion():
+			restoreErr = restoreFromCopiedSnapshot(ctx, rdsClientSess, restoreConfig, snapshot)
+			if restoreErr == nil && createdByRun(snapshot.TagList, restoreConfig) {
+				restoreErr = deleteSnapshot(sourceClient(rdsClientSess), snapshot)
+			}
+		default:
+			restoreErr = restoreClusterFromSnapshot(rdsClientSess, restoreConfig, snapshot)
+			// A snapshot taken by this run is only needed until the cluster is created from it
+			if restoreErr == nil && createdByRun(snapshot.TagList, restoreConfig) {
+				restoreErr = waitUntilRDSClusterCreated(ctx, rdsClientSess, restoreConfig)
+				if restoreErr == nil {
+					restoreErr = deleteSnapshot(rdsClientSess, snapshot)
+				}
+			}
+		}
+		if restoreErr != nil {
+			return restoreErr
+		}
+
+		pruneErr := pruneRestoreSnapshots(rdsClientSess, restoreConfig)
+		if pruneErr != nil {
+			return fmt.Errorf("Prune snapshots of earlier runs Err: %v", pruneErr)
+		}
+		return nil
+	}
+}
+
+func restoreClusterFromSnapshot(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshot *rds.DBClusterSnapshot) error {
+	snapshotName := aws.StringValue(snapshot.DBClusterSnapshotIdentifier)
+	if status := aws.StringValue(snapshot.Status); status != "available" {
+		return fmt.Errorf("Snapshot [%v] is [%v], not available", snapshotName, status)
+	}
+
+	input := &rds.RestoreDBClusterFromSnapshotInput{
+		DBClusterIdentifier: aws.String(restoreConfig.RestoreRDS),     // Required
+		SnapshotIdentifier:  snapshot.DBClusterSnapshotArn,            // Required - ARN works for shared snapshots too
+		Engine:              aws.String(string(restoreConfig.Engine)), // Required
+		Tags:                restoreTags(restoreConfig),               // Not required - ownership checked before delete
+	}
+	if input.SnapshotIdentifier == nil {
+		input.SnapshotIdentifier = snapshot.DBClusterSnapshotIdentifier
+	}
+	// Not required - provisioned by default
+	input.EngineMode, input.ScalingConfiguration = serverlessV1Scaling(restoreConfig)
+	input.ServerlessV2ScalingConfiguration = serverlessV2Scaling(restoreConfig)
+
+	// Not required - default parameter group of the engine otherwise
+	if restoreConfig.ClusterParameterGroup != "" {
+		input.DBClusterParameterGroupName = aws.String(restoreConfig.ClusterParameterGroup)
+	}
+
+	// Not required - restored at the snapshot version otherwise, a newer one upgrades it
+	if restoreConfig.EngineVersion != "" && restoreConfig.EngineVersion != aws.StringValue(snapshot.EngineVersion) {
+		input.EngineVersion = aws.String(restoreConfig.EngineVersion)
+	}
+
+	// Not required - AWS uses the default subnet group and security group otherwise
+	if restoreConfig.SubnetGroup != "" {
+		input.DBSubnetGroupName = aws.String(restoreConfig.SubnetGroup)
+	}
+	if len(restoreConfig.SecurityGroupIDs) > 0 {
+		input.VpcSecurityGroupIds = aws.StringSlice(restoreConfig.SecurityGroupIDs)
+	}
+
+	logf("Creating RDS cluster [%v] from snapshot [%v] of [%v] taken %v\n", restoreConfig.RestoreRDS, snapshotName,
+		aws.StringValue(snapshot.DBClusterIdentifier), formatRestorePoint(snapshot.SnapshotCreateTime))
+
+	_, err := rdsClientSess.RestoreDBClusterFromSnapshot(input)
+	if err != nil {
+		return fmt.Errorf("Error restoring RDS cluster [%v] from snapshot [%v]: %v", restoreConfig.RestoreRDS, snapshotName, err)
+	}
+
+	logf("Executed RDS snapshot restore for cluster [%v] from snapshot [%v]\n", restoreConfig.RestoreRDS, snapshotName)
+	return nil
+}
+
+func findNamedSnapshot(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (*rds.DBClusterSnapshot, error) {
+	resp, err := rdsClientSess.DescribeDBClusterSnapshots(&rds.DescribeDBClusterSnapshotsInput{
+		DBClusterSnapshotIdentifier: aws.String(restoreConfig.SnapshotIdentifier),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Describe Err on snapshot [%v]: %v", restoreConfig.SnapshotIdentifier, err)
+	}
+	if len(resp.DBClusterSnapshots) == 0 {
+		return nil, fmt.Errorf("Snapshot [%v] not found", restoreConfig.SnapshotIdentifier)
+	}
+	return resp.DBClusterSnapshots[0], nil
+}
+
+func findLatestAutomatedSnapshot(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (*rds.DBClusterSnapshot, error) {
+	snapshots, err := sourceSnapshots(rdsClientSess, restoreConfig, "automated")
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("No available automated snapshot of [%v] found", restoreConfig.SourceRDS)
+	}
+	return snapshots[0], nil
+}
+
+func findLatestTaggedSnapshot(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (*rds.DBClusterSnapshot, error) {
+	tagKey, tagValue, _ := parseSnapshotTag(restoreConfig.SnapshotTag)
+
+	snapshots, err := sourceSnapshots(rdsClientSess, restoreConfig, "manual")
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		for _, tag := range snapshot.TagList {
+			if aws.StringValue(tag.Key) == tagKey && aws.StringValue(tag.Value) == tagValue {
+				return snapshot, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("No available manual snapshot of [%v] tagged [%v] found", restoreConfig.SourceRDS, restoreConfig.SnapshotTag)
+}
+
+// Identifier of the snapshot of sourceRDS taken by this run
+func sourceSnapshotIdentifier(restoreConfig *RestoreConfig) string {
+	return strings.ToLower(fmt.Sprintf("%v-source-%v", restoreConfig.RestoreRDS, restoreConfig.RunID))
+}
+
+func takeSourceSnapshot(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) (*rds.DBClusterSnapshot, error) {
+	snapshotName := sourceSnapshotIdentifier(restoreConfig)
+
+	logf("Taking snapshot [%v] of RDS cluster [%v]\n", snapshotName, restoreConfig.SourceRDS)
+	_, err := rdsClientSess.CreateDBClusterSnapshot(&rds.CreateDBClusterSnapshotInput{
+		DBClusterIdentifier:         aws.String(restoreConfig.SourceRDS),
+		DBClusterSnapshotIdentifier: aws.String(snapshotName),
+		Tags:                        restoreSnapshotTags(restoreConfig),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error taking snapshot [%v] of [%v]: %v", snapshotName, restoreConfig.SourceRDS, err)
+	}
+	return waitUntilSnapshotAvailable(ctx, rdsClientSess, restoreConfig, snapshotName)
+}
+
+// Available snapshots of sourceRDS of the given type, newest first
+func sourceSnapshots(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, snapshotType string) ([]*rds.DBClusterSnapshot, error) {
+	var snapshots []*rds.DBClusterSnapshot
+	input := &rds.DescribeDBClusterSnapshotsInput{
+		DBClusterIdentifier: aws.String(restoreConfig.SourceRDS),
+		SnapshotType:        aws.String(snapshotType),
+	}
+	describeErr := rdsClientSess.DescribeDBClusterSnapshotsPages(input, func(page *rds.DescribeDBClusterSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.DBClusterSnapshots {
+			if aws.StringValue(snapshot.Status) == "available" {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+		return true
+	})
+	if describeErr != nil {
+		return nil, fmt.Errorf("Describe %v snapshots of RDS cluster [%v] err: %v", snapshotType, restoreConfig.SourceRDS, describeErr)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return aws.TimeValue(snapshots[i].SnapshotCreateTime).After(aws.TimeValue(snapshots[j].SnapshotCreateTime))
+	})
+	return snapshots, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// Source cluster with two automated snapshots and two manual ones, one of them tagged
+func fakeWithSourceSnapshots() *fakeRDS {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addClusterSnapshot("test-db", "rds:test-db-2021-08-20", time.Date(2021, 8, 20, 3, 0, 0, 0, time.UTC))
+	fake.addClusterSnapshot("test-db", "rds:test-db-2021-08-21", time.Date(2021, 8, 21, 3, 0, 0, 0, time.UTC))
+	fake.addClusterSnapshot("test-db", "test-db-golden", time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC))
+	fake.addClusterSnapshot("test-db", "test-db-adhoc", time.Date(2021, 8, 22, 0, 0, 0, 0, time.UTC))
+	fake.snapshots["rds:test-db-2021-08-20"].SnapshotType = aws.String("automated")
+	fake.snapshots["rds:test-db-2021-08-21"].SnapshotType = aws.String("automated")
+	fake.snapshots["test-db-golden"].TagList = []*rds.Tag{{Key: aws.String("purpose"), Value: aws.String("golden")}}
+	return fake
+}
+
+func TestRestoreRDSClusterFromSnapshot(t *testing.T) {
+	tests := []struct {
+		source       string
+		snapshotID   string
+		snapshotTag  string
+		wantSnapshot string
+	}{
+		{sourceSnapshot, "test-db-adhoc", "", "test-db-adhoc"},
+		{sourceLatestAutomatedSnapshot, "", "", "rds:test-db-2021-08-21"},
+		{sourceLatestTaggedSnapshot, "", "purpose=golden", "test-db-golden"},
+	}
+
+	for _, test := range tests {
+		fake := fakeWithSourceSnapshots()
+		fake.addRestoredCluster("test-db-restore", "test-db")
+
+		restoreConfig := testRestoreConfig()
+		restoreConfig.RestoreSource = test.source
+		restoreConfig.SnapshotIdentifier = test.snapshotID
+		restoreConfig.SnapshotTag = test.snapshotTag
+
+		if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+			t.Fatalf("restoreRDSCluster(context.Background(), %v): %v", test.source, err)
+		}
+
+		want := []string{"DeleteDBCluster", "RestoreDBClusterFromSnapshot", "CreateDBInstance"}
+		if got := mutatingCalls(fake); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: calls = %v, want %v", test.source, got, want)
+		}
+		if got := aws.StringValue(fake.restoreFromSnapshotInputs[0].SnapshotIdentifier); got != test.wantSnapshot {
+			t.Errorf("%v: restored from %v, want %v", test.source, got, test.wantSnapshot)
+		}
+		if status := aws.StringValue(fake.instances["test-db-restore-0"].instance.DBInstanceStatus); status != "available" {
+			t.Errorf("%v: restored instance status = %v, want available", test.source, status)
+		}
+	}
+}
+
+func TestRestoreFromMissingTaggedSnapshotKeepsTarget(t *testing.T) {
+	fake := fakeWithSourceSnapshots()
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RestoreSource = sourceLatestTaggedSnapshot
+	restoreConfig.SnapshotTag = "purpose=nightly"
+
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err == nil {
+		t.Fatalf("expected error when no snapshot matches the tag")
+	}
+	if n := fake.callCount("RestoreDBClusterFromSnapshot"); n != 0 {
+		t.Errorf("RestoreDBClusterFromSnapshot called %d times without a matching snapshot", n)
+	}
+}
+
+func TestRestoreSourceProblems(t *testing.T) {
+	_, err := restoreConfigFromEnv(envFromMap(map[string]string{
+		"awsRegion":          "us-east-1",
+		"sourceRDS":          "test-db",
+		"restoreRDS":         "test-db-restore",
+		"restoreSource":      sourceLatestTaggedSnapshot,
+		"restoreDate":        "2021-08-21",
+		"restoreSnapshotTag": "golden",
+	}))
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	want := []string{
+		"restoreDate can only be used with restoreSource point-in-time",
+		"restoreSnapshotTag [golden] is required as key=value with restoreSource latest-tagged-snapshot",
+	}
+	if !reflect.DeepEqual(configErr.Problems, want) {
+		t.Errorf("problems = %q, want %q", configErr.Problems, want)
+	}
+}
+
+func TestRestoreFromNewSnapshotDeletesIt(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RestoreSource = sourceNewSnapshot
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	want := []string{"CreateDBClusterSnapshot", "RestoreDBClusterFromSnapshot", "DeleteDBClusterSnapshot", "CreateDBInstance"}
+	if got := mutatingCalls(fake); !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if _, ok := fake.snapshots[sourceSnapshotIdentifier(restoreConfig)]; ok {
+		t.Errorf("snapshot taken by the run left behind")
+	}
+}
+
+func TestRestoreFromSnapshotPrunesSnapshotsOfEarlierRuns(t *testing.T) {
+	fake := fakeWithSourceSnapshots()
+	// Kept by an interrupted run, a snapshot someone else named alike, one of another
+	// source and one of a job whose restoreRDS starts with this one's
+	fake.addClusterSnapshot("test-db", "test-db-restore-source-previous-run", time.Date(2021, 8, 19, 0, 0, 0, 0, time.UTC))
+	fake.snapshots["test-db-restore-source-previous-run"].TagList = restoreSnapshotTags(&RestoreConfig{SourceRDS: "test-db", RestoreRDS: "test-db-restore", RunID: "previous-run"})
+	fake.addClusterSnapshot("test-db", "test-db-restore-source-manual", time.Date(2021, 8, 19, 0, 0, 0, 0, time.UTC))
+	fake.addClusterSnapshot("other-db", "test-db-restore-copy-other", time.Date(2021, 8, 19, 0, 0, 0, 0, time.UTC))
+	fake.snapshots["test-db-restore-copy-other"].TagList = restoreSnapshotTags(&RestoreConfig{SourceRDS: "other-db", RestoreRDS: "test-db-restore", RunID: "previous-run"})
+	fake.addClusterSnapshot("test-db", "test-db-restore-copy-eu-source-previous-run", time.Date(2021, 8, 19, 0, 0, 0, 0, time.UTC))
+	fake.snapshots["test-db-restore-copy-eu-source-previous-run"].TagList = restoreSnapshotTags(&RestoreConfig{SourceRDS: "test-db", RestoreRDS: "test-db-restore-copy-eu", RunID: "previous-run"})
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RestoreSource = sourceLatestAutomatedSnapshot
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	if _, ok := fake.snapshots["test-db-restore-source-previous-run"]; ok {
+		t.Errorf("snapshot of the previous run not pruned")
+	}
+	for _, kept := range []string{"test-db-restore-source-manual", "test-db-restore-copy-other", "test-db-restore-copy-eu-source-previous-run"} {
+		if _, ok := fake.snapshots[kept]; !ok {
+			t.Errorf("snapshot %v not created by an earlier run of this job was deleted", kept)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// Capacity modes of the restored cluster, selected with rdsCapacityMode
+const (
+	capacityProvisioned  = "provisioned"
+	capacityServerlessV2 = "serverless-v2"
+	capacityServerlessV1 = "serverless-v1"
+
+	defaultCapacityMode = capacityProvisioned
+)
+
+// Instance class of Aurora Serverless v2 instances
+const serverlessInstanceClass = "db.serverless"
+
+// Serverless v2 capacity is set in ACUs of 0.5 steps, v1 capacity in fixed capacity units
+const (
+	defaultServerlessV2MinCapacity = 0.5
+	defaultServerlessV2MaxCapacity = 4
+	maxServerlessV2Capacity        = 128
+
+	defaultServerlessV1MinCapacity = 2
+	defaultServerlessV1MaxCapacity = 4
+
+	// Auto-pause of Serverless v1 after 5 minutes up to a day without connections
+	defaultAutoPauseSeconds = 300
+	maxAutoPauseSeconds     = 86400
+)
+
+var serverlessV1Capacities = []float64{1, 2, 4, 8, 16, 32, 64, 128, 192, 256, 384}
+
+var capacityModes = []string{capacityProvisioned, capacityServerlessV2, capacityServerlessV1}
+
+// Fill in the capacity defaults of the selected mode
+func (c *RestoreConfig) applyCapacityDefaults() {
+	if c.CapacityMode == "" {
+		c.CapacityMode = defaultCapacityMode
+	}
+
+	switch c.CapacityMode {
+	case capacityServerlessV2:
+		if c.MinCapacity == 0 {
+			c.MinCapacity = defaultServerlessV2MinCapacity
+		}
+		if c.MaxCapacity == 0 {
+			c.MaxCapacity = defaultServerlessV2MaxCapacity
+		}
+		if c.InstanceType == "" {
+			c.InstanceType = serverlessInstanceClass
+		}
+	case capacityServerlessV1:
+		if c.MinCapacity == 0 {
+			c.MinCapacity = defaultServerlessV1MinCapacity
+		}
+		if c.MaxCapacity == 0 {
+			c.MaxCapacity = defaultServerlessV1MaxCapacity
+		}
+		if c.AutoPause && c.AutoPauseSeconds == 0 {
+			c.AutoPauseSeconds = defaultAutoPauseSeconds
+		}
+	}
+}
+
+func (c *RestoreConfig) capacityProblems() []string {
+	var problems []string
+
+	switch c.CapacityMode {
+	case capacityProvisioned:
+		if c.MinCapacity != 0 || c.MaxCapacity != 0 || c.AutoPauseSeconds != 0 {
+			problems = append(problems, "serverlessMinCapacity, serverlessMaxCapacity and serverlessAutoPauseSeconds need a serverless rdsCapacityMode")
+		}
+	case capacityServerlessV2:
+		if c.MinCapacity < defaultServerlessV2MinCapacity || c.MaxCapacity > maxServerlessV2Capacity ||
+			math.Mod(c.MinCapacity, 0.5) != 0 || math.Mod(c.MaxCapacity, 0.5) != 0 {
+			problems = append(problems, fmt.Sprintf("serverless-v2 capacity [%v-%v] must be between %v and %v ACUs in steps of 0.5",
+				c.MinCapacity, c.MaxCapacity, defaultServerlessV2MinCapacity, maxServerlessV2Capacity))
+		}
+		if c.InstanceType != serverlessInstanceClass {
+			problems = append(problems, fmt.Sprintf("rdsInstanceType [%v] can't be used with serverless-v2, its instances are %v", c.InstanceType, serverlessInstanceClass))
+		}
+		for _, instance := range c.Instances {
+			if instance.InstanceClass != "" && instance.InstanceClass != serverlessInstanceClass {
+				problems = append(problems, fmt.Sprintf("rdsInstances [%v] rdsInstanceType [%v] can't be used with serverless-v2, its instances are %v", instance.Name, instance.InstanceClass, serverlessInstanceClass))
+			}
+		}
+		if len(c.InstanceClassMapping) > 0 {
+			problems = append(problems, fmt.Sprintf("rdsInstanceClassMapping can't be used with serverless-v2, mirrored instances are %v", serverlessInstanceClass))
+		}
+		if c.AutoPauseSeconds != 0 {
+			problems = append(problems, "serverlessAutoPauseSeconds is only supported with serverless-v1")
+		}
+	case capacityServerlessV1:
+		for _, capacity := range []struct {
+			name  string
+			value float64
+		}{
+			{"serverlessMinCapacity", c.MinCapacity},
+			{"serverlessMaxCapacity", c.MaxCapacity},
+		} {
+			if !validServerlessV1Capacity(capacity.value) {
+				problems = append(problems, fmt.Sprintf("%v [%v] is not a serverless-v1 capacity, expected one of %v", capacity.name, capacity.value, serverlessV1Capacities))
+			}
+		}
+		if c.AutoPause && (c.AutoPauseSeconds < defaultAutoPauseSeconds || c.AutoPauseSeconds > maxAutoPauseSeconds) {
+			problems = append(problems, fmt.Sprintf("serverlessAutoPauseSeconds [%v] must be between %v and %v", c.AutoPauseSeconds, defaultAutoPauseSeconds, maxAutoPauseSeconds))
+		}
+		if len(c.Instances) > 0 || c.MirrorSourceTopology {
+			problems = append(problems, "serverless-v1 clusters have no instances, rdsInstances and mirrorSourceTopology can't be used with it")
+		}
+	default:
+		return []string{fmt.Sprintf("rdsCapacityMode [%v] is not supported, expected one of %v", c.CapacityMode, capacityModes)}
+	}
+
+	if c.CapacityMode != capacityProvisioned && c.MinCapacity > c.MaxCapacity {
+		problems = append(problems, fmt.Sprintf("serverlessMinCapacity [%v] is greater than serverlessMaxCapacity [%v]", c.MinCapacity, c.MaxCapacity))
+	}
+	return problems
+}
+
+func validServerlessV1Capacity(capacity float64) bool {
+	for _, valid := range serverlessV1Capacities {
+		if capacity == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// EngineMode and ScalingConfiguration of a Serverless v1 restore, nil for the other modes
+func serverlessV1Scaling(restoreConfig *RestoreConfig) (*string, *rds.ScalingConfiguration) {
+	if restoreConfig.CapacityMode != capacityServerlessV1 {
+		return nil, nil
+	}
+
+	scaling := &rds.ScalingConfiguration{
+		MinCapacity: aws.Int64(int64(restoreConfig.MinCapacity)),
+		MaxCapacity: aws.Int64(int64(restoreConfig.MaxCapacity)),
+		AutoPause:   aws.Bool(restoreConfig.AutoPause),
+	}
+	if restoreConfig.AutoPause {
+		scaling.SecondsUntilAutoPause = aws.Int64(restoreConfig.AutoPauseSeconds)
+	}
+	return aws.String("serverless"), scaling
+}
+
+// ServerlessV2ScalingConfiguration of a Serverless v2 restore, nil for the other modes
+func serverlessV2Scaling(restoreConfig *RestoreConfig) *rds.ServerlessV2ScalingConfiguration {
+	if restoreConfig.CapacityMode != capacityServerlessV2 {
+		return nil
+	}
+	return &rds.ServerlessV2ScalingConfiguration{
+		MinCapacity: aws.Float64(restoreConfig.MinCapacity),
+		MaxCapacity: aws.Float64(restoreConfig.MaxCapacity),
+	}
+}
+
+// Check the engine version of restoreRDS can run as Serverless v1, v2 is
+// checked with the orderable db.serverless instance class
+func checkServerlessEngineMode(restoreConfig *RestoreConfig, engineVersion *rds.DBEngineVersion) error {
+	if restoreConfig.CapacityMode != capacityServerlessV1 {
+		return nil
+	}
+
+	for _, mode := range engineVersion.SupportedEngineModes {
+		if aws.StringValue(mode) == "serverless" {
+			return nil
+		}
+	}
+	return fmt.Errorf("Engine version [%v %v] doesn't support serverless-v1, its engine modes are %v - set rdsEngineVersion to one that does",
+		restoreConfig.Engine, aws.StringValue(engineVersion.EngineVersion), aws.StringValueSlice(engineVersion.SupportedEngineModes))
+}
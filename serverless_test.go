@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func serverlessRestoreConfig(capacityMode string) *RestoreConfig {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.CapacityMode = capacityMode
+	restoreConfig.InstanceType = ""
+	restoreConfig.AutoPause = true
+	restoreConfig.applyCapacityDefaults()
+	return restoreConfig
+}
+
+func TestRestoreRDSClusterServerlessV2(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addInstance("test-db", "test-db-writer", "available")
+	fake.addInstance("test-db", "test-db-reader", "available")
+	fake.instances["test-db-writer"].instance.DBInstanceClass = aws.String("db.r5.2xlarge")
+
+	restoreConfig := serverlessRestoreConfig(capacityServerlessV2)
+	restoreConfig.MirrorSourceTopology = true
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	scaling := fake.restoreInputs[0].ServerlessV2ScalingConfiguration
+	if scaling == nil || aws.Float64Value(scaling.MinCapacity) != 0.5 || aws.Float64Value(scaling.MaxCapacity) != 4 {
+		t.Errorf("ServerlessV2ScalingConfiguration = %v, want 0.5-4 ACUs", scaling)
+	}
+	if fake.restoreInputs[0].EngineMode != nil {
+		t.Errorf("EngineMode = %v, want provisioned (unset)", aws.StringValue(fake.restoreInputs[0].EngineMode))
+	}
+	if len(fake.createInstanceInputs) != 2 {
+		t.Fatalf("created %d instances, want 2", len(fake.createInstanceInputs))
+	}
+	for _, input := range fake.createInstanceInputs {
+		if got := aws.StringValue(input.DBInstanceClass); got != serverlessInstanceClass {
+			t.Errorf("instance [%v] class = %v, want %v", aws.StringValue(input.DBInstanceIdentifier), got, serverlessInstanceClass)
+		}
+	}
+}
+
+func TestRestoreRDSClusterServerlessV1(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.clusters["test-db"].cluster.EngineVersion = aws.String("5.7.mysql_aurora.2.07.2")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	if err := restoreRDSCluster(context.Background(), fake, serverlessRestoreConfig(capacityServerlessV1)); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	input := fake.restoreInputs[0]
+	if got := aws.StringValue(input.EngineMode); got != "serverless" {
+		t.Errorf("EngineMode = %v, want serverless", got)
+	}
+	scaling := input.ScalingConfiguration
+	if scaling == nil || aws.Int64Value(scaling.MinCapacity) != 2 || aws.Int64Value(scaling.MaxCapacity) != 4 ||
+		!aws.BoolValue(scaling.AutoPause) || aws.Int64Value(scaling.SecondsUntilAutoPause) != 300 {
+		t.Errorf("ScalingConfiguration = %v, want 2-4 pausing after 300s", scaling)
+	}
+
+	wantCalls := []string{"DeleteDBCluster", "RestoreDBClusterToPointInTime"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("mutating calls = %v, want %v", calls, wantCalls)
+	}
+}
+
+func TestRestoreRDSClusterServerlessV1UnsupportedVersion(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	err := restoreRDSCluster(context.Background(), fake, serverlessRestoreConfig(capacityServerlessV1))
+	if err == nil || !strings.Contains(err.Error(), "doesn't support serverless-v1") {
+		t.Fatalf("expected serverless-v1 engine mode error, got %v", err)
+	}
+	if calls := mutatingCalls(fake); len(calls) != 0 {
+		t.Errorf("unsupported engine version sent mutating calls: %v", calls)
+	}
+}
+
+func TestRestoreConfigFromEnvServerless(t *testing.T) {
+	env := map[string]string{
+		"awsRegion":       "us-east-1",
+		"sourceRDS":       "test-db",
+		"restoreRDS":      "test-db-restore",
+		"rdsCapacityMode": capacityServerlessV1,
+	}
+
+	restoreConfig, err := restoreConfigFromEnv(envFromMap(env))
+	if err != nil {
+		t.Fatalf("restoreConfigFromEnv: %v", err)
+	}
+	if restoreConfig.MinCapacity != 2 || restoreConfig.MaxCapacity != 4 || !restoreConfig.AutoPause || restoreConfig.AutoPauseSeconds != 300 {
+		t.Errorf("serverless-v1 defaults = %v-%v auto-pause %v after %vs", restoreConfig.MinCapacity, restoreConfig.MaxCapacity, restoreConfig.AutoPause, restoreConfig.AutoPauseSeconds)
+	}
+
+	env["serverlessAutoPause"] = "false"
+	restoreConfig, err = restoreConfigFromEnv(envFromMap(env))
+	if err != nil {
+		t.Fatalf("restoreConfigFromEnv: %v", err)
+	}
+	if _, scaling := serverlessV1Scaling(restoreConfig); aws.BoolValue(scaling.AutoPause) || scaling.SecondsUntilAutoPause != nil {
+		t.Errorf("ScalingConfiguration = %v, want no auto-pause", scaling)
+	}
+
+	env = map[string]string{
+		"awsRegion":       "us-east-1",
+		"sourceRDS":       "test-db",
+		"restoreRDS":      "test-db-restore",
+		"rdsCapacityMode": capacityServerlessV2,
+	}
+	restoreConfig, err = restoreConfigFromEnv(envFromMap(env))
+	if err != nil {
+		t.Fatalf("restoreConfigFromEnv: %v", err)
+	}
+	if restoreConfig.InstanceType != serverlessInstanceClass || restoreConfig.MinCapacity != 0.5 || restoreConfig.MaxCapacity != 4 {
+		t.Errorf("serverless-v2 defaults = %v %v-%v", restoreConfig.InstanceType, restoreConfig.MinCapacity, restoreConfig.MaxCapacity)
+	}
+}
+
+func TestCapacityProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		problem string
+	}{
+		{"provisioned with capacity", map[string]string{"serverlessMaxCapacity": "8"}, "need a serverless rdsCapacityMode"},
+		{"unknown mode", map[string]string{"rdsCapacityMode": "serverless"}, "rdsCapacityMode [serverless] is not supported"},
+		{"v2 class", map[string]string{"rdsCapacityMode": capacityServerlessV2, "rdsInstanceType": "db.r5.large"}, "can't be used with serverless-v2"},
+		{"v2 step", map[string]string{"rdsCapacityMode": capacityServerlessV2, "serverlessMaxCapacity": "2.25"}, "in steps of 0.5"},
+		{"v2 min over max", map[string]string{"rdsCapacityMode": capacityServerlessV2, "serverlessMinCapacity": "8"}, "greater than serverlessMaxCapacity"},
+		{"v1 capacity", map[string]string{"rdsCapacityMode": capacityServerlessV1, "serverlessMaxCapacity": "3"}, "is not a serverless-v1 capacity"},
+		{"v1 auto-pause", map[string]string{"rdsCapacityMode": capacityServerlessV1, "serverlessAutoPauseSeconds": "60"}, "serverlessAutoPauseSeconds [60]"},
+		{"v1 instances", map[string]string{"rdsCapacityMode": capacityServerlessV1, "mirrorSourceTopology": "true"}, "have no instances"},
+	}
+
+	for _, test := range tests {
+		env := map[string]string{"awsRegion": "us-east-1", "sourceRDS": "test-db", "restoreRDS": "test-db-restore"}
+		for key, value := range test.env {
+			env[key] = value
+		}
+
+		_, err := restoreConfigFromEnv(envFromMap(env))
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("%v: expected problem %q, got %v", test.name, test.problem, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Placeholders available in finalSnapshotIdentifier, e.g. "{restoreRDS}-final-{timestamp}"
+var finalSnapshotPlaceholders = []string{"{restoreRDS}", "{sourceRDS}", "{runId}", "{date}", "{timestamp}"}
+
+// Run ID the template is validated with before the run ID is known
+const sampleRunID = "r0"
+
+// Render the final snapshot identifier template for a snapshot taken at the given time
+func renderFinalSnapshotIdentifier(restoreConfig *RestoreConfig, now time.Time) string {
+	now = now.UTC()
+	return strings.NewReplacer(
+		"{restoreRDS}", restoreConfig.RestoreRDS,
+		"{sourceRDS}", restoreConfig.SourceRDS,
+		"{runId}", restoreConfig.RunID,
+		"{date}", now.Format("20060102"),
+		"{timestamp}", now.Format("20060102150405"),
+	).Replace(restoreConfig.FinalSnapshotIdentifier)
+}
+
+// Regex matching every identifier the template can render to, used to find
+// earlier final snapshots of this job
+func finalSnapshotRegex(restoreConfig *RestoreConfig) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(restoreConfig.FinalSnapshotIdentifier)
+	for _, placeholder := range finalSnapshotPlaceholders {
+		pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta(placeholder), ".+")
+	}
+	return regexp.MustCompile("(?i)^" + pattern + "$")
+}
+
+// Delete the oldest final snapshots of restoreRDS beyond the retention count
+func pruneFinalSnapshots(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	if restoreConfig.FinalSnapshotIdentifier == "" || restoreConfig.FinalSnapshotRetention == 0 {
+		return nil
+	}
+
+	snapshotRegex := finalSnapshotRegex(restoreConfig)
+
+	var finalSnapshots []*rds.DBClusterSnapshot
+	input := &rds.DescribeDBClusterSnapshotsInput{
+		DBClusterIdentifier: aws.String(restoreConfig.RestoreRDS),
+		SnapshotType:        aws.String("manual"),
+	}
+	describeErr := rdsClientSess.DescribeDBClusterSnapshotsPages(input, func(page *rds.DescribeDBClusterSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.DBClusterSnapshots {
+			if snapshotRegex.MatchString(aws.StringValue(snapshot.DBClusterSnapshotIdentifier)) {
+				finalSnapshots = append(finalSnapshots, snapshot)
+			}
+		}
+		return true
+	})
+	if describeErr != nil {
+		return fmt.Errorf("Describe final snapshots of RDS cluster [%v] err: %v", restoreConfig.RestoreRDS, describeErr)
+	}
+
+	if len(finalSnapshots) <= restoreConfig.FinalSnapshotRetention {
+		logf("%d final snapshot(s) of RDS cluster [%v], retention is %d - nothing to prune\n",
+			len(finalSnapshots), restoreConfig.RestoreRDS, restoreConfig.FinalSnapshotRetention)
+		return nil
+	}
+
+	// Newest first, everything after the retention count gets deleted
+	sort.Slice(finalSnapshots, func(i, j int) bool {
+		return aws.TimeValue(finalSnapshots[i].SnapshotCreateTime).After(aws.TimeValue(finalSnapshots[j].SnapshotCreateTime))
+	})
+
+	for _, snapshot := range finalSnapshots[restoreConfig.FinalSnapshotRetention:] {
+		snapshotName := aws.StringValue(snapshot.DBClusterSnapshotIdentifier)
+		logf("Pruning final snapshot [%v] created %v\n", snapshotName, aws.TimeValue(snapshot.SnapshotCreateTime).Format(time.RFC3339))
+
+		_, deleteErr := rdsClientSess.DeleteDBClusterSnapshot(&rds.DeleteDBClusterSnapshotInput{
+			DBClusterSnapshotIdentifier: snapshot.DBClusterSnapshotIdentifier,
+		})
+		if deleteErr != nil {
+			return fmt.Errorf("Error deleting final snapshot [%v]: %v", snapshotName, deleteErr)
+		}
+	}
+	return nil
+}
+
+// Snapshots a restore creates on its way - taken of sourceRDS, shared from the
+// source account or copied into awsRegion - named {restoreRDS}-{kind}-{runId}
+// and tagged like the restored cluster plus the restoreRDS they were made for
+func restoreSnapshotTags(restoreConfig *RestoreConfig) []*rds.Tag {
+	return append(restoreTags(restoreConfig), &rds.Tag{
+		Key:   aws.String(targetTagKey),
+		Value: aws.String(restoreConfig.RestoreRDS),
+	})
+}
+
+// Whether a snapshot was made on the way to restoreRDS, by any run
+func restoreSnapshotOf(tagList []*rds.Tag, restoreConfig *RestoreConfig) bool {
+	for _, tag := range tagList {
+		if aws.StringValue(tag.Key) == targetTagKey {
+			return strings.EqualFold(aws.StringValue(tag.Value), restoreConfig.RestoreRDS)
+		}
+	}
+	return false
+}
+
+// Delete the snapshots earlier runs of this job left behind, e.g. when they
+// were interrupted, in the account and region of the target and of the source
+func pruneRestoreSnapshots(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	clients := []rdsiface.RDSAPI{rdsClientSess}
+	if sourceClientSess := sourceClient(rdsClientSess); sourceClientSess != rdsClientSess {
+		clients = append(clients, sourceClientSess)
+	}
+
+	var pruneErrs []error
+	for _, client := range clients {
+		pruneErrs = append(pruneErrs, pruneRestoreSnapshotsWith(client, restoreConfig))
+	}
+	return combineErrors(pruneErrs)
+}
+
+func pruneRestoreSnapshotsWith(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) error {
+	// Copies in another account or region don't keep the source cluster, so every manual snapshot is looked at
+	var leftSnapshots []*rds.DBClusterSnapshot
+	input := &rds.DescribeDBClusterSnapshotsInput{
+		SnapshotType: aws.String("manual"),
+	}
+	describeErr := rdsClientSess.DescribeDBClusterSnapshotsPages(input, func(page *rds.DescribeDBClusterSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.DBClusterSnapshots {
+			runID := restoreRunID(snapshot.TagList, restoreConfig)
+			if runID != "" && runID != restoreConfig.RunID && restoreSnapshotOf(snapshot.TagList, restoreConfig) {
+				leftSnapshots = append(leftSnapshots, snapshot)
+			}
+		}
+		return true
+	})
+	if describeErr != nil {
+		return fmt.Errorf("Describe snapshots left by earlier runs of [%v] err: %v", restoreConfig.RestoreRDS, describeErr)
+	}
+
+	var deleteErrs []error
+	for _, snapshot := range leftSnapshots {
+		logf("Pruning snapshot [%v] left by run [%v]\n", aws.StringValue(snapshot.DBClusterSnapshotIdentifier), restoreRunID(snapshot.TagList, restoreConfig))
+		deleteErrs = append(deleteErrs, deleteSnapshot(rdsClientSess, snapshot))
+	}
+	return combineErrors(deleteErrs)
+}
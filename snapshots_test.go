@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestRenderFinalSnapshotIdentifier(t *testing.T) {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.FinalSnapshotIdentifier = "{restoreRDS}-final-{date}-{runId}"
+
+	got := renderFinalSnapshotIdentifier(restoreConfig, time.Date(2021, 8, 21, 21, 0, 0, 0, time.UTC))
+	if want := "test-db-restore-final-20210821-test-run"; got != want {
+		t.Errorf("renderFinalSnapshotIdentifier = %v, want %v", got, want)
+	}
+	if !finalSnapshotRegex(restoreConfig).MatchString(got) {
+		t.Errorf("final snapshot regex doesn't match rendered identifier %v", got)
+	}
+	if finalSnapshotRegex(restoreConfig).MatchString("test-db-restore-manual-backup") {
+		t.Errorf("final snapshot regex matches unrelated snapshot")
+	}
+}
+
+func TestCleanupTakesFinalSnapshotAndPrunes(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	fake.addClusterSnapshot("test-db-restore", "test-db-restore-final-20210801000000", time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC))
+	fake.addClusterSnapshot("test-db-restore", "test-db-restore-final-20210802000000", time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC))
+	fake.addClusterSnapshot("test-db-restore", "test-db-restore-manual-backup", time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC))
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.FinalSnapshotIdentifier = "{restoreRDS}-final-{timestamp}"
+	restoreConfig.FinalSnapshotRetention = 2
+
+	if err := cleanupRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+
+	if _, ok := fake.snapshots["test-db-restore-final-20210801000000"]; ok {
+		t.Errorf("oldest final snapshot was not pruned")
+	}
+	if _, ok := fake.snapshots["test-db-restore-final-20210802000000"]; !ok {
+		t.Errorf("final snapshot within retention was pruned")
+	}
+	if _, ok := fake.snapshots["test-db-restore-manual-backup"]; !ok {
+		t.Errorf("unrelated manual snapshot was pruned")
+	}
+	if len(fake.snapshots) != 3 {
+		t.Errorf("got %d snapshots, want new final snapshot, previous one and the manual backup", len(fake.snapshots))
+	}
+	for _, snapshot := range fake.snapshots {
+		if aws.StringValue(snapshot.DBClusterSnapshotIdentifier) == "" {
+			t.Errorf("final snapshot taken without identifier")
+		}
+	}
+}
+
+func TestCleanupPrunesSnapshotsOfEarlierRuns(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addRestoredCluster("test-db-restore", "test-db")
+	// Copy kept by an interrupted cross-region restore
+	fake.addClusterSnapshot("", "test-db-restore-copy-previous-run", time.Date(2021, 8, 21, 0, 0, 0, 0, time.UTC))
+	fake.snapshots["test-db-restore-copy-previous-run"].TagList = restoreSnapshotTags(&RestoreConfig{SourceRDS: "test-db", RestoreRDS: "test-db-restore", RunID: "previous-run"})
+
+	if err := cleanupRDSCluster(context.Background(), fake, testRestoreConfig()); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if _, ok := fake.snapshots["test-db-restore-copy-previous-run"]; ok {
+		t.Errorf("snapshot of the previous run not pruned")
+	}
+}
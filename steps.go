@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Steps of a restore, names are stored in checkpoints
+const (
+	stepCheck               = "check"
+	stepDeleteInstances     = "delete-instances"
+	stepDeleteCluster       = "delete-cluster"
+	stepCopyParameterGroups = "copy-parameter-groups"
+	stepRestore             = "restore"
+	stepWaitCluster         = "wait-cluster"
+	stepCreateInstances     = "create-instances"
+	stepWaitInstances       = "wait-instances"
+	stepParameterOverrides  = "parameter-overrides"
+
+	// Steps of a standalone instance restore
+	stepDeleteInstance = "delete-instance"
+	stepWaitInstance   = "wait-instance"
+)
+
+// Step of a restore job. Completed steps are checkpointed, a rerun with the
+// same run ID resumes after the last one.
+type restoreStep struct {
+	name string
+	// Read-only step run on every attempt, e.g. checks resolving what later steps need
+	always bool
+	run    func(ctx context.Context) error
+	// Check AWS is still in the state the step left it in before resuming after
+	// it - nil if there is nothing to check
+	verify func() error
+}
+
+// Last step a run of a job completed, saved to checkpointDir
+type checkpoint struct {
+	RunID      string    `json:"runId"`
+	Job        string    `json:"job"`
+	RestoreRDS string    `json:"restoreRDS"`
+	Step       string    `json:"step"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Run the steps of a job in order, resuming after the step checkpointed by a
+// previous attempt of the same run once AWS is verified to still match it.
+// Without a checkpoint reconcile decides which step to resume after, if any.
+// The checkpoint is removed when every step is done.
+func runRestoreSteps(ctx context.Context, restoreConfig *RestoreConfig, steps []restoreStep, reconcile func() (string, error)) error {
+	resumeAfter := -1
+	resolved := false
+
+	for i, step := range steps {
+		// Later steps may need what the leading always steps resolve to verify AWS state
+		if !step.always && !resolved {
+			var resumeErr error
+			resumeAfter, resumeErr = resumeIndex(restoreConfig, steps)
+			if resumeErr != nil {
+				return resumeErr
+			}
+			if resumeAfter < 0 && reconcile != nil {
+				resumeAfter, resumeErr = reconcileIndex(ctx, steps, reconcile)
+				if resumeErr != nil {
+					return resumeErr
+				}
+			}
+			resolved = true
+		}
+		if i <= resumeAfter && !step.always {
+			logf("Step [%v] of [%v] already done, skipping\n", step.name, restoreConfig.RestoreRDS)
+			continue
+		}
+
+		if stepErr := beginStep(ctx, step.name); stepErr != nil {
+			return stepErr
+		}
+		if runErr := step.run(ctx); runErr != nil {
+			return runErr
+		}
+		if step.always {
+			continue
+		}
+		if saveErr := saveCheckpoint(restoreConfig, step.name); saveErr != nil {
+			return saveErr
+		}
+	}
+	return removeCheckpoint(restoreConfig)
+}
+
+// Index of the step to resume after, -1 to run every step
+func resumeIndex(restoreConfig *RestoreConfig, steps []restoreStep) (int, error) {
+	saved, loadErr := loadCheckpoint(restoreConfig)
+	if loadErr != nil || saved == nil {
+		return -1, loadErr
+	}
+	if saved.RunID != restoreConfig.RunID {
+		logf("Checkpoint of [%v] is of run [%v], not [%v], running every step\n", restoreConfig.RestoreRDS, saved.RunID, restoreConfig.RunID)
+		return -1, nil
+	}
+
+	for i, step := range steps {
+		if step.name != saved.Step {
+			continue
+		}
+		if step.verify != nil {
+			if verifyErr := step.verify(); verifyErr != nil {
+				logf("AWS doesn't match the checkpoint after step [%v] of [%v] (%v), running every step\n", saved.Step, restoreConfig.RestoreRDS, verifyErr)
+				return -1, nil
+			}
+		}
+		logf("Resuming run [%v] of [%v] after step [%v], checkpointed %v\n", saved.RunID, restoreConfig.RestoreRDS, saved.Step, saved.UpdatedAt.Format(time.RFC3339))
+		return i, nil
+	}
+
+	logf("Checkpoint of [%v] has unknown step [%v], running every step\n", restoreConfig.RestoreRDS, saved.Step)
+	return -1, nil
+}
+
+// Index of the step reconcile decided to resume after, -1 to run every step
+func reconcileIndex(ctx context.Context, steps []restoreStep, reconcile func() (string, error)) (int, error) {
+	if stepErr := beginStep(ctx, stepReconcile); stepErr != nil {
+		return -1, stepErr
+	}
+	resumeStep, reconcileErr := reconcile()
+	if reconcileErr != nil {
+		return -1, fmt.Errorf("Reconcile Err: %v", reconcileErr)
+	}
+	for i, step := range steps {
+		if step.name == resumeStep {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// One checkpoint file per restoreRDS in checkpointDir
+func checkpointPath(restoreConfig *RestoreConfig) string {
+	return filepath.Join(restoreConfig.CheckpointDir, restoreConfig.RestoreRDS+".json")
+}
+
+// Checkpoint of restoreRDS, nil if there is none or checkpoints are disabled
+func loadCheckpoint(restoreConfig *RestoreConfig) (*checkpoint, error) {
+	if restoreConfig.CheckpointDir == "" {
+		return nil, nil
+	}
+
+	content, readErr := os.ReadFile(checkpointPath(restoreConfig))
+	if os.IsNotExist(readErr) {
+		return nil, nil
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("Read checkpoint Err: %v", readErr)
+	}
+
+	saved := &checkpoint{}
+	if parseErr := json.Unmarshal(content, saved); parseErr != nil {
+		return nil, fmt.Errorf("Parse checkpoint [%v] Err: %v", checkpointPath(restoreConfig), parseErr)
+	}
+	return saved, nil
+}
+
+// Save step as the last completed step of the run, replacing the file at once
+// so that a kill mid-write leaves the previous checkpoint
+func saveCheckpoint(restoreConfig *RestoreConfig, step string) error {
+	if restoreConfig.CheckpointDir == "" {
+		return nil
+	}
+
+	content, marshalErr := json.MarshalIndent(&checkpoint{
+		RunID:      restoreConfig.RunID,
+		Job:        restoreConfig.Name,
+		RestoreRDS: restoreConfig.RestoreRDS,
+		Step:       step,
+		UpdatedAt:  time.Now().UTC(),
+	}, "", "  ")
+	if marshalErr != nil {
+		return fmt.Errorf("Save checkpoint Err: %v", marshalErr)
+	}
+
+	path := checkpointPath(restoreConfig)
+	if writeErr := os.WriteFile(path+".tmp", content, 0o644); writeErr != nil {
+		return fmt.Errorf("Save checkpoint Err: %v", writeErr)
+	}
+	if renameErr := os.Rename(path+".tmp", path); renameErr != nil {
+		return fmt.Errorf("Save checkpoint Err: %v", renameErr)
+	}
+	return nil
+}
+
+func removeCheckpoint(restoreConfig *RestoreConfig) error {
+	if restoreConfig.CheckpointDir == "" {
+		return nil
+	}
+	if removeErr := os.Remove(checkpointPath(restoreConfig)); removeErr != nil && !os.IsNotExist(removeErr) {
+		return fmt.Errorf("Remove checkpoint Err: %v", removeErr)
+	}
+	return nil
+}
+
+// The restoreRDS cluster is gone
+func verifyClusterDeleted(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) func() error {
+	return func() error {
+		cluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.RestoreRDS)
+		if describeErr != nil {
+			return describeErr
+		}
+		if cluster != nil {
+			return fmt.Errorf("RDS cluster [%v] exists in status [%v]", restoreConfig.RestoreRDS, aws.StringValue(cluster.Status))
+		}
+		return nil
+	}
+}
+
+// The restoreRDS cluster was restored by this run and is in one of statuses -
+// any status but a deletion or failure one when none are given - and so are
+// the instances
+func verifyClusterRestored(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, instances func() []InstanceSpec, statuses ...string) func() error {
+	return func() error {
+		cluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.RestoreRDS)
+		if describeErr != nil {
+			return describeErr
+		}
+		if cluster == nil {
+			return fmt.Errorf("RDS cluster [%v] not found", restoreConfig.RestoreRDS)
+		}
+		if !createdByRun(cluster.TagList, restoreConfig) {
+			return fmt.Errorf("RDS cluster [%v] wasn't restored by run [%v]", restoreConfig.RestoreRDS, restoreConfig.RunID)
+		}
+		if statusErr := checkResumableStatus(fmt.Sprintf("RDS cluster [%v]", restoreConfig.RestoreRDS), aws.StringValue(cluster.Status), clusterFailureStates, statuses); statusErr != nil {
+			return statusErr
+		}
+
+		for _, spec := range instances() {
+			instance, describeErr := describeRDSInstance(rdsClientSess, spec.Name)
+			if describeErr != nil {
+				return describeErr
+			}
+			if instance == nil || aws.StringValue(instance.DBClusterIdentifier) != restoreConfig.RestoreRDS {
+				return fmt.Errorf("RDS instance [%v] not found in RDS cluster [%v]", spec.Name, restoreConfig.RestoreRDS)
+			}
+			if statusErr := checkResumableStatus(fmt.Sprintf("RDS instance [%v]", spec.Name), aws.StringValue(instance.DBInstanceStatus), instanceFailureStates, statuses); statusErr != nil {
+				return statusErr
+			}
+		}
+		return nil
+	}
+}
+
+// The restoreRDS instance is gone
+func verifyInstanceDeleted(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) func() error {
+	return func() error {
+		instance, describeErr := describeRDSInstance(rdsClientSess, restoreConfig.RestoreRDS)
+		if describeErr != nil {
+			return describeErr
+		}
+		if instance != nil {
+			return fmt.Errorf("RDS instance [%v] exists in status [%v]", restoreConfig.RestoreRDS, aws.StringValue(instance.DBInstanceStatus))
+		}
+		return nil
+	}
+}
+
+// The restoreRDS instance was restored by this run and is in one of statuses,
+// any status but a deletion or failure one when none are given
+func verifyInstanceRestored(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, statuses ...string) func() error {
+	return func() error {
+		instance, describeErr := describeRDSInstance(rdsClientSess, restoreConfig.RestoreRDS)
+		if describeErr != nil {
+			return describeErr
+		}
+		if instance == nil {
+			return fmt.Errorf("RDS instance [%v] not found", restoreConfig.RestoreRDS)
+		}
+		if !createdByRun(instance.TagList, restoreConfig) {
+			return fmt.Errorf("RDS instance [%v] wasn't restored by run [%v]", restoreConfig.RestoreRDS, restoreConfig.RunID)
+		}
+		return checkResumableStatus(fmt.Sprintf("RDS instance [%v]", restoreConfig.RestoreRDS), aws.StringValue(instance.DBInstanceStatus), instanceFailureStates, statuses)
+	}
+}
+
+func checkResumableStatus(resource string, status string, failureStates []string, statuses []string) error {
+	if len(statuses) > 0 && !containsString(statuses, status) {
+		return fmt.Errorf("%v is [%v], expected one of %v", resource, status, statuses)
+	}
+	if status == "deleting" || containsString(failureStates, status) {
+		return fmt.Errorf("%v is [%v]", resource, status)
+	}
+	return nil
+}
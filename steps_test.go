@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func checkpointRestoreConfig(t *testing.T) *RestoreConfig {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.RunID = "run-1"
+	restoreConfig.CheckpointDir = t.TempDir()
+	return restoreConfig
+}
+
+func TestRestoreRDSClusterResumesAfterCheckpointedStep(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.createFailures["test-db-restore-0"] = "creating"
+
+	restoreConfig := checkpointRestoreConfig(t)
+	restoreConfig.WaitTimeout = 20 * time.Millisecond
+	restoreConfig.WaitPollInterval = time.Millisecond
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); !isWaitFailure(err) {
+		t.Fatalf("expected the instance wait to time out, got %v", err)
+	}
+
+	saved, err := loadCheckpoint(restoreConfig)
+	if err != nil || saved == nil || saved.Step != stepCreateInstances || saved.RunID != "run-1" {
+		t.Fatalf("checkpoint = %+v (%v), want step %v of run-1", saved, err, stepCreateInstances)
+	}
+
+	// The instance finishes creating before the rerun
+	delete(fake.createFailures, "test-db-restore-0")
+	callsBefore := len(mutatingCalls(fake))
+	restoreConfig.WaitTimeout = 0
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("resumed restoreRDSCluster: %v", err)
+	}
+
+	if calls := mutatingCalls(fake)[callsBefore:]; len(calls) != 0 {
+		t.Errorf("resumed restore sent mutating calls: %v", calls)
+	}
+	if _, statErr := os.Stat(checkpointPath(restoreConfig)); !os.IsNotExist(statErr) {
+		t.Errorf("checkpoint left behind after a completed restore: %v", statErr)
+	}
+}
+
+func TestRestoreRDSClusterRunsEveryStepForCheckpointOfOtherRun(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addRestoredCluster("test-db-restore", "test-db")
+
+	restoreConfig := checkpointRestoreConfig(t)
+	previousRun := *restoreConfig
+	previousRun.RunID = "previous-run"
+	if err := saveCheckpoint(&previousRun, stepWaitInstances); err != nil {
+		t.Fatalf("saveCheckpoint: %v", err)
+	}
+
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+	wantCalls := []string{"DeleteDBCluster", "RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("mutating calls = %v, want %v", calls, wantCalls)
+	}
+}
+
+func TestRestoreRDSClusterRunsEveryStepWhenAWSDoesNotMatchCheckpoint(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	// Checkpointed cluster was deleted since
+	restoreConfig := checkpointRestoreConfig(t)
+	if err := saveCheckpoint(restoreConfig, stepWaitCluster); err != nil {
+		t.Fatalf("saveCheckpoint: %v", err)
+	}
+
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+	wantCalls := []string{"RestoreDBClusterToPointInTime", "CreateDBInstance"}
+	if calls := mutatingCalls(fake); !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("mutating calls = %v, want %v", calls, wantCalls)
+	}
+}
+
+func TestRestoreRDSInstanceResumesAfterCheckpointedStep(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addStandaloneInstance("test-db-pg", "available")
+
+	restoreConfig := checkpointRestoreConfig(t)
+	restoreConfig.SourceRDS = "test-db-pg"
+	restoreConfig.Instances = nil
+	if err := restoreRDSInstance(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSInstance: %v", err)
+	}
+
+	// Rerun of the same run after it stopped waiting for the restored instance
+	if err := saveCheckpoint(restoreConfig, stepRestore); err != nil {
+		t.Fatalf("saveCheckpoint: %v", err)
+	}
+	callsBefore := len(mutatingCalls(fake))
+	if err := restoreRDSInstance(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("resumed restoreRDSInstance: %v", err)
+	}
+	if calls := mutatingCalls(fake)[callsBefore:]; len(calls) != 0 {
+		t.Errorf("resumed restore sent mutating calls: %v", calls)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// InstanceSpec describes one instance of the restored cluster, the first
+// instance of the topology becomes the writer
+type InstanceSpec struct {
+	// Instance identifier - defaults to <restoreRDS>-<index>
+	Name string `yaml:"name" json:"name"`
+	// Instance class - defaults to rdsInstanceType
+	InstanceClass string `yaml:"rdsInstanceType" json:"rdsInstanceType"`
+	// Optional, AWS picks one otherwise
+	AvailabilityZone string `yaml:"availabilityZone" json:"availabilityZone"`
+	// Optional failover priority 0-15, AWS defaults to 1
+	PromotionTier *int64 `yaml:"promotionTier" json:"promotionTier"`
+}
+
+// Fill in instance names and classes that aren't set, a single instance
+// <restoreRDS>-0 of rdsInstanceType if no topology is given
+func defaultTopology(restoreConfig *RestoreConfig) []InstanceSpec {
+	instances := restoreConfig.Instances
+	if len(instances) == 0 {
+		instances = []InstanceSpec{{}}
+	}
+
+	topology := make([]InstanceSpec, len(instances))
+	for i, instance := range instances {
+		if instance.Name == "" {
+			instance.Name = fmt.Sprintf("%v-%d", restoreConfig.RestoreRDS, i)
+		}
+		if instance.InstanceClass == "" {
+			instance.InstanceClass = restoreConfig.InstanceType
+		}
+		topology[i] = instance
+	}
+	return topology
+}
+
+// Problems of the configured topology, names and classes that are filled in
+// from restoreRDS and rdsInstanceType are already checked on their own
+func topologyProblems(restoreConfig *RestoreConfig) []string {
+	var problems []string
+	names := map[string]bool{}
+
+	for i, instance := range defaultTopology(restoreConfig) {
+		configured := InstanceSpec{}
+		if i < len(restoreConfig.Instances) {
+			configured = restoreConfig.Instances[i]
+		}
+
+		if configured.Name != "" && (len(instance.Name) > 63 || !rdsIdentifierRegex.MatchString(instance.Name)) {
+			problems = append(problems, fmt.Sprintf("rdsInstances name [%v] is not a valid RDS instance identifier", instance.Name))
+		}
+		if names[instance.Name] {
+			problems = append(problems, fmt.Sprintf("rdsInstances name [%v] is used more than once", instance.Name))
+		}
+		names[instance.Name] = true
+
+		if configured.InstanceClass != "" && !isInstanceClass(instance.InstanceClass) {
+			problems = append(problems, fmt.Sprintf("rdsInstances [%v] rdsInstanceType [%v] is not an RDS instance class (db.*)", instance.Name, instance.InstanceClass))
+		}
+		if instance.PromotionTier != nil && (*instance.PromotionTier < 0 || *instance.PromotionTier > 15) {
+			problems = append(problems, fmt.Sprintf("rdsInstances [%v] promotionTier [%v] must be between 0 and 15", instance.Name, *instance.PromotionTier))
+		}
+	}
+
+	if restoreConfig.MirrorSourceTopology && len(restoreConfig.Instances) > 0 {
+		problems = append(problems, "rdsInstances can't be set together with mirrorSourceTopology")
+	}
+	if !restoreConfig.MirrorSourceTopology && len(restoreConfig.InstanceClassMapping) > 0 {
+		problems = append(problems, "rdsInstanceClassMapping set without mirrorSourceTopology")
+	}
+	var sourceClasses []string
+	for source := range restoreConfig.InstanceClassMapping {
+		sourceClasses = append(sourceClasses, source)
+	}
+	sort.Strings(sourceClasses)
+	for _, source := range sourceClasses {
+		target := restoreConfig.InstanceClassMapping[source]
+		if !isInstanceClass(source) || !isInstanceClass(target) {
+			problems = append(problems, fmt.Sprintf("rdsInstanceClassMapping [%v=%v] must map an RDS instance class (db.*) to another", source, target))
+		}
+	}
+	return problems
+}
+
+// Topology equivalent to the instances of sourceRDS - the writer first, then
+// the readers with their promotion tiers and AZs, classes mapped through
+// rdsInstanceClassMapping when there's an entry for them
+func sourceTopology(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) ([]InstanceSpec, error) {
+	rdsClientSess = sourceClient(rdsClientSess)
+
+	sourceCluster, describeErr := describeRDSCluster(rdsClientSess, restoreConfig.SourceRDS)
+	if describeErr != nil {
+		return nil, describeErr
+	}
+	if sourceCluster == nil {
+		return nil, fmt.Errorf("Source RDS cluster [%v] not found, cannot mirror its topology", restoreConfig.SourceRDS)
+	}
+
+	members := append([]*rds.DBClusterMember{}, sourceCluster.DBClusterMembers...)
+	sort.SliceStable(members, func(i, j int) bool {
+		return aws.BoolValue(members[i].IsClusterWriter) && !aws.BoolValue(members[j].IsClusterWriter)
+	})
+
+	var topology []InstanceSpec
+	for i, member := range members {
+		sourceInstanceName := aws.StringValue(member.DBInstanceIdentifier)
+		resp, err := rdsClientSess.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+			DBInstanceIdentifier: member.DBInstanceIdentifier,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Describe Err on source instance [%v]: %v", sourceInstanceName, err)
+		}
+		if len(resp.DBInstances) == 0 {
+			return nil, fmt.Errorf("Source instance [%v] not found", sourceInstanceName)
+		}
+		sourceInstance := resp.DBInstances[0]
+
+		instanceClass := aws.StringValue(sourceInstance.DBInstanceClass)
+		if mappedClass, ok := restoreConfig.InstanceClassMapping[instanceClass]; ok {
+			instanceClass = mappedClass
+		}
+
+		instance := InstanceSpec{
+			Name:             fmt.Sprintf("%v-%d", restoreConfig.RestoreRDS, i),
+			InstanceClass:    instanceClass,
+			AvailabilityZone: aws.StringValue(sourceInstance.AvailabilityZone),
+			PromotionTier:    member.PromotionTier,
+		}
+		logf("Mirroring source instance [%v] (%v) as [%v] (%v)\n",
+			sourceInstanceName, aws.StringValue(sourceInstance.DBInstanceClass), instance.Name, instance.InstanceClass)
+		topology = append(topology, instance)
+	}
+
+	if len(topology) == 0 {
+		logf("Source RDS cluster [%v] has no instances, creating a single instance instead\n", restoreConfig.SourceRDS)
+		return defaultTopology(restoreConfig), nil
+	}
+	return topology, nil
+}
+
+// Instances restoreRDS is created with - the configured ones or the mirrored
+// ones of sourceRDS, all of them db.serverless for Serverless v2 and none for v1
+func restoreTopology(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig) ([]InstanceSpec, error) {
+	switch {
+	case restoreConfig.CapacityMode == capacityServerlessV1:
+		return nil, nil
+	case !restoreConfig.MirrorSourceTopology:
+		return defaultTopology(restoreConfig), nil
+	}
+
+	topology, topologyErr := sourceTopology(rdsClientSess, restoreConfig)
+	if topologyErr != nil {
+		return nil, fmt.Errorf("Mirror source topology Err: %v", topologyErr)
+	}
+	if restoreConfig.CapacityMode == capacityServerlessV2 {
+		for i := range topology {
+			topology[i].InstanceClass = serverlessInstanceClass
+		}
+	}
+	return topology, nil
+}
+
+// Create the instances of the restored cluster that don't exist yet, the
+// writer first
+func createRDSInstances(rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, topology []InstanceSpec) error {
+	// Instances of an adopted cluster may already exist
+	existing, listInstancesErr := rdsClusterInstances(rdsClientSess, restoreConfig)
+	if listInstancesErr != nil {
+		return fmt.Errorf("List RDS cluster Instances Err: %v", listInstancesErr)
+	}
+
+	var instanceErrs []error
+	for i, instance := range topology {
+		if containsString(existing, instance.Name) {
+			logf("RDS instance [%v] already exists in RDS cluster [%v], skipping create\n", instance.Name, restoreConfig.RestoreRDS)
+			continue
+		}
+
+		createInstanceErr := createRDSInstance(rdsClientSess, restoreConfig, instance)
+		if createInstanceErr != nil && i == 0 {
+			return fmt.Errorf("Create RDS writer Instance [%v] Err: %v", instance.Name, createInstanceErr)
+		}
+		if createInstanceErr != nil {
+			instanceErrs = append(instanceErrs, fmt.Errorf("Create RDS Instance [%v] Err: %v", instance.Name, createInstanceErr))
+		}
+	}
+	return combineErrors(instanceErrs)
+}
+
+// Wait until all instances of the restored cluster are created
+func waitUntilRDSInstancesCreated(ctx context.Context, rdsClientSess rdsiface.RDSAPI, restoreConfig *RestoreConfig, topology []InstanceSpec) error {
+	instanceErrs := make([]error, len(topology))
+
+	var wg sync.WaitGroup
+	for i, instance := range topology {
+		wg.Add(1)
+		go func(i int, instance InstanceSpec) {
+			defer wg.Done()
+
+			waitInstanceCreateErr := waitUntilRDSInstanceCreated(ctx, rdsClientSess, restoreConfig, instance.Name)
+			if waitInstanceCreateErr != nil {
+				instanceErrs[i] = fmt.Errorf("Wait RDS Instance [%v] create Err: %w", instance.Name, waitInstanceCreateErr)
+			}
+		}(i, instance)
+	}
+	wg.Wait()
+
+	return combineErrors(instanceErrs)
+}
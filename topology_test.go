@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestRestoreRDSClusterCreatesTopology(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Instances = []InstanceSpec{
+		{AvailabilityZone: "us-east-1a"},
+		{InstanceClass: "db.r5.large", AvailabilityZone: "us-east-1b", PromotionTier: aws.Int64(1)},
+		{Name: "test-db-restore-analytics", PromotionTier: aws.Int64(15)},
+	}
+
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	if n := len(fake.createInstanceInputs); n != 3 {
+		t.Fatalf("CreateDBInstance called %d times, want 3", n)
+	}
+	if writer := aws.StringValue(fake.createInstanceInputs[0].DBInstanceIdentifier); writer != "test-db-restore-0" {
+		t.Errorf("first created instance = %v, want writer test-db-restore-0", writer)
+	}
+
+	want := map[string][3]string{
+		"test-db-restore-0":         {"db.t3.small", "us-east-1a", ""},
+		"test-db-restore-1":         {"db.r5.large", "us-east-1b", "1"},
+		"test-db-restore-analytics": {"db.t3.small", "", "15"},
+	}
+	got := map[string][3]string{}
+	for name, i := range fake.instances {
+		if status := aws.StringValue(i.instance.DBInstanceStatus); status != "available" {
+			t.Errorf("instance %v status = %v, want available", name, status)
+		}
+		tier := ""
+		if i.instance.PromotionTier != nil {
+			tier = fmt.Sprint(aws.Int64Value(i.instance.PromotionTier))
+		}
+		got[name] = [3]string{aws.StringValue(i.instance.DBInstanceClass), aws.StringValue(i.instance.AvailabilityZone), tier}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("instances = %v, want %v", got, want)
+	}
+}
+
+func TestTopologyProblems(t *testing.T) {
+	restoreConfig := testRestoreConfig()
+	restoreConfig.Instances = []InstanceSpec{
+		{},
+		{Name: "test-db-restore-0"},
+		{Name: "Bad_Name"},
+		{InstanceClass: "r5.large", PromotionTier: aws.Int64(16)},
+	}
+
+	problems := topologyProblems(restoreConfig)
+	sort.Strings(problems)
+	want := []string{
+		"rdsInstances [test-db-restore-3] promotionTier [16] must be between 0 and 15",
+		"rdsInstances [test-db-restore-3] rdsInstanceType [r5.large] is not an RDS instance class (db.*)",
+		"rdsInstances name [Bad_Name] is not a valid RDS instance identifier",
+		"rdsInstances name [test-db-restore-0] is used more than once",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
+
+func TestRestoreRDSClusterMirrorsSourceTopology(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.addInstance("test-db", "test-db-reader", "available")
+	fake.addInstance("test-db", "test-db-writer", "available")
+	fake.instances["test-db-reader"].instance.DBInstanceClass = aws.String("db.r5.large")
+	fake.instances["test-db-reader"].instance.AvailabilityZone = aws.String("us-east-1b")
+	fake.instances["test-db-writer"].instance.DBInstanceClass = aws.String("db.r5.2xlarge")
+	fake.instances["test-db-writer"].instance.AvailabilityZone = aws.String("us-east-1a")
+	members := fake.clusters["test-db"].cluster.DBClusterMembers
+	members[0].IsClusterWriter, members[0].PromotionTier = aws.Bool(false), aws.Int64(2)
+	members[1].IsClusterWriter, members[1].PromotionTier = aws.Bool(true), aws.Int64(1)
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.MirrorSourceTopology = true
+	restoreConfig.InstanceClassMapping = map[string]string{"db.r5.2xlarge": "db.r5.large"}
+
+	if err := restoreRDSCluster(context.Background(), fake, restoreConfig); err != nil {
+		t.Fatalf("restoreRDSCluster: %v", err)
+	}
+
+	var got [][4]string
+	for _, input := range fake.createInstanceInputs {
+		got = append(got, [4]string{
+			aws.StringValue(input.DBInstanceIdentifier),
+			aws.StringValue(input.DBInstanceClass),
+			aws.StringValue(input.AvailabilityZone),
+			fmt.Sprint(aws.Int64Value(input.PromotionTier)),
+		})
+	}
+	want := [][4]string{
+		{"test-db-restore-0", "db.r5.large", "us-east-1a", "1"},
+		{"test-db-restore-1", "db.r5.large", "us-east-1b", "2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("created instances = %v, want %v", got, want)
+	}
+}
+
+func TestInstanceClassMappingFromEnv(t *testing.T) {
+	restoreConfig, err := restoreConfigFromEnv(envFromMap(map[string]string{
+		"awsRegion":               "us-east-1",
+		"sourceRDS":               "test-db",
+		"restoreRDS":              "test-db-restore",
+		"mirrorSourceTopology":    "true",
+		"rdsInstanceClassMapping": "db.r5.2xlarge=db.r5.large, db.r5.large=db.t3.medium",
+	}))
+	if err != nil {
+		t.Fatalf("restoreConfigFromEnv: %v", err)
+	}
+
+	want := map[string]string{"db.r5.2xlarge": "db.r5.large", "db.r5.large": "db.t3.medium"}
+	if !restoreConfig.MirrorSourceTopology || !reflect.DeepEqual(restoreConfig.InstanceClassMapping, want) {
+		t.Errorf("mirror = %v, mapping = %v, want true and %v", restoreConfig.MirrorSourceTopology, restoreConfig.InstanceClassMapping, want)
+	}
+
+	_, err = restoreConfigFromEnv(envFromMap(map[string]string{
+		"awsRegion":               "us-east-1",
+		"sourceRDS":               "test-db",
+		"restoreRDS":              "test-db-restore",
+		"rdsInstanceClassMapping": "db.r5.large,db.r5.xlarge=db.r5.large",
+	}))
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 2 {
+		t.Errorf("expected pair and missing mirrorSourceTopology problems, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// Defaults of the waitUntil* polls - the interval doubles while the status
+// doesn't change, up to waitMaxPollInterval, and starts over when it does
+var (
+	waitPollInterval    = 30 * time.Second
+	waitMaxPollInterval = 2 * time.Minute
+)
+
+// Overall timeouts of a wait unless waitTimeout is set
+const (
+	defaultWaitTimeout         = time.Hour
+	defaultSnapshotWaitTimeout = 2 * time.Hour
+)
+
+// Up to this fraction of the poll interval is added at random, so that
+// parallel waits don't poll the API in lockstep
+const waitJitterFraction = 0.2
+
+// Status polled for a resource that doesn't exist (anymore)
+const statusNotFound = "not-found"
+
+// Statuses a cluster, instance or snapshot doesn't leave without intervention,
+// a wait ends with a WaitFailedError on them instead of running into its timeout
+var (
+	clusterFailureStates = []string{
+		"failed", "cloning-failed", "migration-failed",
+		"inaccessible-encryption-credentials", "incompatible-parameters",
+	}
+	instanceFailureStates = []string{
+		"failed", "inaccessible-encryption-credentials", "incompatible-parameters",
+		"incompatible-network", "incompatible-option-group", "incompatible-restore",
+		"restore-error", "storage-full",
+	}
+	snapshotFailureStates = []string{"failed"}
+)
+
+// WaitTimeoutError is returned when a resource is still pending once the
+// timeout of the wait is over
+type WaitTimeoutError struct {
+	Resource string
+	// Last polled status
+	Status  string
+	Timeout time.Duration
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("%v still [%v], timed out after %v", e.Resource, e.Status, e.Timeout)
+}
+
+// WaitFailedError is returned when a resource reaches a terminal failure status
+type WaitFailedError struct {
+	Resource string
+	Status   string
+}
+
+func (e *WaitFailedError) Error() string {
+	return fmt.Sprintf("%v reached failure status [%v]", e.Resource, e.Status)
+}
+
+// A wait timed out or ended in a failure status somewhere in err
+func isWaitFailure(err error) bool {
+	var timeoutErr *WaitTimeoutError
+	var failedErr *WaitFailedError
+	return errors.As(err, &timeoutErr) || errors.As(err, &failedErr)
+}
+
+// Timeout and poll intervals of a wait
+type waitTiming struct {
+	timeout         time.Duration
+	pollInterval    time.Duration
+	maxPollInterval time.Duration
+}
+
+// Waiter polls the status of a resource until it reaches one of its success
+// or failure states, logging only when the status changes
+type waiter struct {
+	// Resource in logs and errors, e.g. "RDS cluster [qa-db]"
+	resource      string
+	successStates []string
+	failureStates []string
+	timing        waitTiming
+	// Current status of the resource, statusNotFound once it's gone
+	poll func() (string, error)
+}
+
+// Timing of the waits of a job - waitTimeout, waitPollInterval and
+// waitMaxPollInterval when set, the defaults otherwise
+func (c *RestoreConfig) waitTiming(defaultTimeout time.Duration) waitTiming {
+	timing := waitTiming{
+		timeout:         defaultTimeout,
+		pollInterval:    waitPollInterval,
+		maxPollInterval: waitMaxPollInterval,
+	}
+	if c.WaitTimeout != 0 {
+		timing.timeout = c.WaitTimeout
+	}
+	if c.WaitPollInterval != 0 {
+		timing.pollInterval = c.WaitPollInterval
+	}
+	if c.WaitMaxPollInterval != 0 {
+		timing.maxPollInterval = c.WaitMaxPollInterval
+	}
+	if timing.maxPollInterval < timing.pollInterval {
+		timing.maxPollInterval = timing.pollInterval
+	}
+	return timing
+}
+
+func (c *RestoreConfig) waitProblems() []string {
+	var problems []string
+
+	for _, setting := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"waitTimeout", c.WaitTimeout},
+		{"waitPollInterval", c.WaitPollInterval},
+		{"waitMaxPollInterval", c.WaitMaxPollInterval},
+	} {
+		if setting.value < 0 {
+			problems = append(problems, fmt.Sprintf("%v [%v] can't be negative", setting.name, setting.value))
+		}
+	}
+	if c.WaitMaxPollInterval != 0 && c.WaitMaxPollInterval < c.WaitPollInterval {
+		problems = append(problems, fmt.Sprintf("waitMaxPollInterval [%v] is shorter than waitPollInterval [%v]", c.WaitMaxPollInterval, c.WaitPollInterval))
+	}
+	return problems
+}
+
+// Poll until the resource reaches a success state. A failure state ends the
+// wait with a WaitFailedError, the timeout with a WaitTimeoutError. A wait
+// interrupted by cancelling ctx leaves the resource in-flight in the progress
+// of the job.
+func (w *waiter) wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timing.timeout)
+	defer cancel()
+
+	progress := progressOf(ctx)
+	interrupted := false
+	defer func() {
+		if !interrupted {
+			progress.settled(w.resource)
+		}
+	}()
+
+	start := time.Now()
+	interval := w.timing.pollInterval
+	status := ""
+
+	for {
+		polledStatus, pollErr := w.poll()
+		if pollErr != nil {
+			return fmt.Errorf("Wait %v err %v", w.resource, pollErr)
+		}
+
+		if polledStatus != status {
+			logf("%v status: [%v] after %v\n", w.resource, polledStatus, fmtDuration(time.Since(start)))
+			status = polledStatus
+			interval = w.timing.pollInterval
+			progress.polled(w.resource, status)
+		} else {
+			interval = nextPollInterval(interval, w.timing.maxPollInterval)
+		}
+
+		if containsString(w.successStates, status) {
+			return nil
+		}
+		if containsString(w.failureStates, status) {
+			return &WaitFailedError{Resource: w.resource, Status: status}
+		}
+
+		timer := time.NewTimer(withJitter(interval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return &WaitTimeoutError{Resource: w.resource, Status: status, Timeout: w.timing.timeout}
+			}
+			interrupted = true
+			return fmt.Errorf("Wait %v interrupted in status [%v]: %w", w.resource, status, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Double the interval, capped at max
+func nextPollInterval(interval time.Duration, max time.Duration) time.Duration {
+	interval *= 2
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+func withJitter(interval time.Duration) time.Duration {
+	jitter := int64(float64(interval) * waitJitterFraction)
+	if jitter <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(jitter))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Failure states of a wait for a resource to be created - it's gone for good
+// when it disappears on the way
+func creationFailureStates(failureStates []string) []string {
+	return append(append([]string{}, failureStates...), statusNotFound)
+}
+
+// Status of an RDS cluster, statusNotFound once it's gone
+func clusterStatus(rdsClientSess rdsiface.RDSAPI, rdsClusterName string) func() (string, error) {
+	return func() (string, error) {
+		resp, err := rdsClientSess.DescribeDBClusters(&rds.DescribeDBClustersInput{
+			DBClusterIdentifier: aws.String(rdsClusterName),
+		})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBClusterNotFoundFault {
+				return statusNotFound, nil
+			}
+			return "", err
+		}
+		if len(resp.DBClusters) == 0 {
+			return statusNotFound, nil
+		}
+		return aws.StringValue(resp.DBClusters[0].Status), nil
+	}
+}
+
+// Status of an RDS instance, statusNotFound once it's gone
+func instanceStatus(rdsClientSess rdsiface.RDSAPI, rdsInstanceName string) func() (string, error) {
+	return func() (string, error) {
+		resp, err := rdsClientSess.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+			DBInstanceIdentifier: aws.String(rdsInstanceName),
+		})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+				return statusNotFound, nil
+			}
+			return "", err
+		}
+		if len(resp.DBInstances) == 0 {
+			return statusNotFound, nil
+		}
+		return aws.StringValue(resp.DBInstances[0].DBInstanceStatus), nil
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Poll returning the given statuses in order, repeating the last one
+func statusSequence(statuses ...string) (func() (string, error), *int) {
+	polls := 0
+	return func() (string, error) {
+		status := statuses[len(statuses)-1]
+		if polls < len(statuses) {
+			status = statuses[polls]
+		}
+		polls++
+		return status, nil
+	}, &polls
+}
+
+func TestWaiterWaitsForSuccessState(t *testing.T) {
+	poll, polls := statusSequence("creating", "creating", "backing-up", "available")
+	w := &waiter{
+		resource:      "RDS cluster [test-db-restore]",
+		successStates: []string{"available"},
+		timing:        waitTiming{timeout: time.Minute},
+		poll:          poll,
+	}
+
+	if err := w.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if *polls != 4 {
+		t.Errorf("polled %d times, want 4", *polls)
+	}
+}
+
+func TestWaiterStopsAtFailureState(t *testing.T) {
+	poll, _ := statusSequence("creating", statusNotFound)
+	w := &waiter{
+		resource:      "RDS cluster [test-db-restore]",
+		successStates: []string{"available"},
+		failureStates: []string{statusNotFound},
+		timing:        waitTiming{timeout: time.Minute},
+		poll:          poll,
+	}
+
+	err := w.wait(context.Background())
+	var failedErr *WaitFailedError
+	if !errors.As(err, &failedErr) || failedErr.Status != statusNotFound {
+		t.Fatalf("expected WaitFailedError in status not-found, got %v", err)
+	}
+}
+
+func TestWaiterTimesOut(t *testing.T) {
+	poll, _ := statusSequence("creating")
+	w := &waiter{
+		resource:      "RDS cluster [test-db-restore]",
+		successStates: []string{"available"},
+		timing:        waitTiming{timeout: 20 * time.Millisecond, pollInterval: time.Millisecond, maxPollInterval: 4 * time.Millisecond},
+		poll:          poll,
+	}
+
+	err := w.wait(context.Background())
+	var timeoutErr *WaitTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Status != "creating" {
+		t.Fatalf("expected WaitTimeoutError in status creating, got %v", err)
+	}
+}
+
+func TestWaiterStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	poll, polls := statusSequence("deleting")
+	w := &waiter{
+		resource:      "RDS instance [test-db-restore-0]",
+		successStates: []string{statusNotFound},
+		timing:        waitTiming{timeout: time.Minute, pollInterval: time.Minute, maxPollInterval: time.Minute},
+		poll:          poll,
+	}
+
+	err := w.wait(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if *polls != 1 {
+		t.Errorf("polled %d times after cancel, want 1", *polls)
+	}
+}
+
+func TestWaiterPollError(t *testing.T) {
+	w := &waiter{
+		resource:      "Snapshot [test-snapshot]",
+		successStates: []string{"available"},
+		timing:        waitTiming{timeout: time.Minute},
+		poll:          func() (string, error) { return "", errors.New("throttled") },
+	}
+
+	err := w.wait(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "throttled") {
+		t.Fatalf("expected poll error, got %v", err)
+	}
+}
+
+func TestNextPollIntervalBacksOffUpToMax(t *testing.T) {
+	interval := 30 * time.Second
+	var intervals []time.Duration
+	for i := 0; i < 4; i++ {
+		interval = nextPollInterval(interval, 2*time.Minute)
+		intervals = append(intervals, interval)
+	}
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 2 * time.Minute, 2 * time.Minute}
+	for i := range want {
+		if intervals[i] != want[i] {
+			t.Errorf("intervals = %v, want %v", intervals, want)
+			break
+		}
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := withJitter(time.Minute); got < time.Minute || got >= 72*time.Second {
+			t.Fatalf("withJitter(1m) = %v, want within [1m, 1m12s)", got)
+		}
+	}
+	if got := withJitter(0); got != 0 {
+		t.Errorf("withJitter(0) = %v, want 0", got)
+	}
+}
+
+func TestRestoreConfigWaitTiming(t *testing.T) {
+	env := map[string]string{
+		"awsRegion":        "us-east-1",
+		"sourceRDS":        "test-db",
+		"restoreRDS":       "test-db-restore",
+		"waitTimeout":      "90m",
+		"waitPollInterval": "10s",
+	}
+
+	restoreConfig, err := restoreConfigFromEnv(envFromMap(env))
+	if err != nil {
+		t.Fatalf("restoreConfigFromEnv: %v", err)
+	}
+	timing := restoreConfig.waitTiming(defaultSnapshotWaitTimeout)
+	// The tests zero waitMaxPollInterval, it never drops below the poll interval
+	if timing.timeout != 90*time.Minute || timing.pollInterval != 10*time.Second || timing.maxPollInterval != 10*time.Second {
+		t.Errorf("wait timing = %+v, want 90m timeout polling every 10s", timing)
+	}
+
+	env["waitTimeout"] = "soon"
+	env["waitMaxPollInterval"] = "5s"
+	_, err = restoreConfigFromEnv(envFromMap(env))
+	if err == nil || !strings.Contains(err.Error(), "waitTimeout [soon] is not a duration") ||
+		!strings.Contains(err.Error(), "waitMaxPollInterval [5s] is shorter than waitPollInterval [10s]") {
+		t.Errorf("expected wait setting problems, got %v", err)
+	}
+}
+
+func TestRestoreRDSClusterFailsWhenInstanceReachesFailureState(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.createFailures["test-db-restore-0"] = "incompatible-parameters"
+
+	err := restoreRDSCluster(context.Background(), fake, testRestoreConfig())
+	var failedErr *WaitFailedError
+	if !errors.As(err, &failedErr) || failedErr.Status != "incompatible-parameters" {
+		t.Fatalf("expected WaitFailedError in status incompatible-parameters, got %v", err)
+	}
+	if !isWaitFailure(err) {
+		t.Errorf("isWaitFailure(%v) = false", err)
+	}
+}
+
+func TestRestoreRDSClusterFailsWhenInstanceWaitTimesOut(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("test-db", "available")
+	fake.createFailures["test-db-restore-0"] = "creating"
+
+	restoreConfig := testRestoreConfig()
+	restoreConfig.WaitTimeout = 20 * time.Millisecond
+	restoreConfig.WaitPollInterval = time.Millisecond
+	err := restoreRDSCluster(context.Background(), fake, restoreConfig)
+
+	var timeoutErr *WaitTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Resource != "RDS instance [test-db-restore-0]" {
+		t.Fatalf("expected WaitTimeoutError of the instance, got %v", err)
+	}
+}